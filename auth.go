@@ -0,0 +1,237 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// DefaultTokenExpiryDelta is the time before the expiry of a token at
+// which a BearerTokenProvider fetches a new token.
+const DefaultTokenExpiryDelta = 30 * time.Second
+
+// AuthProvider authenticates the requests to Opensearch, e.g. by setting
+// the Authorization header. It is used for all requests, including
+// sniffing, healthchecks and pings, and takes precedence over the
+// credentials passed to SetBasicAuth.
+type AuthProvider interface {
+	// Authenticate adds the credentials to the request.
+	Authenticate(ctx context.Context, req *http.Request) error
+
+	// Refresh is called when the request failed with status 401
+	// Unauthorized. It returns true if the credentials have changed
+	// since they were added to the request, and the request should be
+	// tried again once.
+	Refresh(ctx context.Context, req *http.Request) (bool, error)
+}
+
+// credentialsCache caches the value of the Authorization header until
+// it expires, or until a request fails with status 401.
+type credentialsCache struct {
+	mu     sync.Mutex
+	value  string
+	expiry time.Time // zero if the value does not expire
+	fetch  func(ctx context.Context) (value string, expiry time.Time, err error)
+}
+
+// get returns the cached value, and fetches a new one if it has expired.
+func (c *credentialsCache) get(ctx context.Context, expiryDelta time.Duration) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.value != "" && (c.expiry.IsZero() || time.Now().Add(expiryDelta).Before(c.expiry)) {
+		return c.value, nil
+	}
+	return c.fetchLocked(ctx)
+}
+
+// refresh fetches a new value, unless the value used by the failed
+// request has already been replaced. It returns true if the value used
+// by the request differs from the current one.
+func (c *credentialsCache) refresh(ctx context.Context, used string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.value != used {
+		return c.value != "", nil
+	}
+	value, err := c.fetchLocked(ctx)
+	if err != nil {
+		return false, err
+	}
+	return value != used, nil
+}
+
+func (c *credentialsCache) fetchLocked(ctx context.Context) (string, error) {
+	value, expiry, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.value, c.expiry = value, expiry
+	return value, nil
+}
+
+// -- Bearer tokens --
+
+// TokenFunc returns a token, e.g. an OIDC access token, and the time
+// when it expires. If the expiry is zero and the token is a JWT, the
+// expiry is taken from its exp claim.
+type TokenFunc func(ctx context.Context) (token string, expiry time.Time, err error)
+
+// BearerTokenProvider authenticates requests with a bearer token, e.g.
+// a JWT. The token is fetched with a TokenFunc, and cached until shortly
+// before it expires, or until a request fails with status 401.
+type BearerTokenProvider struct {
+	cache       credentialsCache
+	expiryDelta time.Duration
+}
+
+// NewBearerTokenProvider returns a new BearerTokenProvider that fetches
+// tokens with fn.
+func NewBearerTokenProvider(fn TokenFunc) *BearerTokenProvider {
+	p := &BearerTokenProvider{expiryDelta: DefaultTokenExpiryDelta}
+	p.cache.fetch = func(ctx context.Context) (string, time.Time, error) {
+		token, expiry, err := fn(ctx)
+		if err != nil {
+			return "", time.Time{}, errors.Wrap(err, "cannot fetch token")
+		}
+		if token == "" {
+			return "", time.Time{}, errors.New("cannot fetch token: token is empty")
+		}
+		if expiry.IsZero() {
+			expiry = jwtExpiry(token)
+		}
+		return "Bearer " + token, expiry, nil
+	}
+	return p
+}
+
+// ExpiryDelta sets the time before the expiry of a token at which a new
+// token is fetched. It defaults to DefaultTokenExpiryDelta.
+func (p *BearerTokenProvider) ExpiryDelta(d time.Duration) *BearerTokenProvider {
+	p.expiryDelta = d
+	return p
+}
+
+// Authenticate sets the Authorization header of the request.
+func (p *BearerTokenProvider) Authenticate(ctx context.Context, req *http.Request) error {
+	value, err := p.cache.get(ctx, p.expiryDelta)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", value)
+	return nil
+}
+
+// Refresh fetches a new token after the request failed with status 401.
+func (p *BearerTokenProvider) Refresh(ctx context.Context, req *http.Request) (bool, error) {
+	return p.cache.refresh(ctx, req.Header.Get("Authorization"))
+}
+
+// jwtExpiry returns the expiry in the exp claim of a JWT, or the zero
+// time if the token is not a JWT or has no exp claim.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp json.Number `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil || exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(exp), 0)
+}
+
+// -- API keys --
+
+// APIKeyProvider authenticates requests with a static API key in the
+// Authorization header, e.g. "ApiKey <key>".
+type APIKeyProvider struct {
+	value string
+}
+
+// NewAPIKeyProvider returns a new APIKeyProvider for the given key,
+// which is sent as is, i.e. it must be encoded already if the cluster
+// expects it so.
+func NewAPIKeyProvider(key string) *APIKeyProvider {
+	return &APIKeyProvider{value: "ApiKey " + key}
+}
+
+// Authenticate sets the Authorization header of the request.
+func (p *APIKeyProvider) Authenticate(ctx context.Context, req *http.Request) error {
+	req.Header.Set("Authorization", p.value)
+	return nil
+}
+
+// Refresh returns false, as a static API key cannot be refreshed.
+func (p *APIKeyProvider) Refresh(ctx context.Context, req *http.Request) (bool, error) {
+	return false, nil
+}
+
+// -- Rotating basic auth --
+
+// BasicAuthFunc returns the username and password for HTTP Basic Auth,
+// e.g. from a secret store.
+type BasicAuthFunc func(ctx context.Context) (username, password string, err error)
+
+// BasicAuthProvider authenticates requests with HTTP Basic Auth, using
+// credentials that may rotate. The credentials are fetched with a
+// BasicAuthFunc, and cached until a request fails with status 401, or
+// until the refresh interval has passed.
+type BasicAuthProvider struct {
+	cache credentialsCache
+}
+
+// NewBasicAuthProvider returns a new BasicAuthProvider that fetches
+// the credentials with fn. If refreshInterval is positive, the
+// credentials are fetched again after that interval.
+func NewBasicAuthProvider(fn BasicAuthFunc, refreshInterval time.Duration) *BasicAuthProvider {
+	p := &BasicAuthProvider{}
+	p.cache.fetch = func(ctx context.Context) (string, time.Time, error) {
+		username, password, err := fn(ctx)
+		if err != nil {
+			return "", time.Time{}, errors.Wrap(err, "cannot fetch basic auth credentials")
+		}
+		var expiry time.Time
+		if refreshInterval > 0 {
+			expiry = time.Now().Add(refreshInterval)
+		}
+		value := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+		return value, expiry, nil
+	}
+	return p
+}
+
+// Authenticate sets the Authorization header of the request.
+func (p *BasicAuthProvider) Authenticate(ctx context.Context, req *http.Request) error {
+	value, err := p.cache.get(ctx, 0)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", value)
+	return nil
+}
+
+// Refresh fetches the credentials again after the request failed with
+// status 401.
+func (p *BasicAuthProvider) Refresh(ctx context.Context, req *http.Request) (bool, error) {
+	return p.cache.refresh(ctx, req.Header.Get("Authorization"))
+}
@@ -0,0 +1,214 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func authorization(t *testing.T, p AuthProvider) string {
+	t.Helper()
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:9200/", nil)
+	if err := p.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestBearerTokenProviderCachesToken(t *testing.T) {
+	var calls atomic.Int64
+	expiry := time.Now().Add(time.Hour)
+	p := NewBearerTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		n := calls.Add(1)
+		return fmt.Sprintf("token-%d", n), expiry, nil
+	})
+	for i := 0; i < 3; i++ {
+		if want, have := "Bearer token-1", authorization(t, p); want != have {
+			t.Fatalf("want %q, have %q", want, have)
+		}
+	}
+	if want, have := int64(1), calls.Load(); want != have {
+		t.Fatalf("expected %d calls, have %d", want, have)
+	}
+
+	// Tokens are fetched again shortly before they expire
+	expiry = time.Now().Add(10 * time.Second)
+	p.ExpiryDelta(time.Hour + time.Minute)
+	if want, have := "Bearer token-2", authorization(t, p); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if want, have := "Bearer token-3", authorization(t, p); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+}
+
+func TestBearerTokenProviderRefresh(t *testing.T) {
+	var calls atomic.Int64
+	p := NewBearerTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		n := calls.Add(1)
+		if n > 2 {
+			n = 2
+		}
+		return fmt.Sprintf("token-%d", n), time.Time{}, nil
+	})
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:9200/", nil)
+	if err := p.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := p.Refresh(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed {
+		t.Fatal("expected token to be refreshed")
+	}
+	// Another failed request with the old token must not fetch again
+	refreshed, err = p.Refresh(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed {
+		t.Fatal("expected token to be refreshed")
+	}
+	if want, have := int64(2), calls.Load(); want != have {
+		t.Fatalf("expected %d calls, have %d", want, have)
+	}
+
+	// A token that does not change is not worth another attempt
+	if err := p.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err = p.Refresh(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed {
+		t.Fatal("expected token not to be refreshed")
+	}
+}
+
+func TestJWTExpiry(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	header := encode(`{"alg":"RS256","typ":"JWT"}`)
+
+	token := header + "." + encode(`{"sub":"search","exp":1700000000}`) + ".signature"
+	if want, have := time.Unix(1700000000, 0), jwtExpiry(token); !want.Equal(have) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	for _, token := range []string{
+		"opaque-token",
+		header + "." + encode(`{"sub":"search"}`) + ".signature",
+		header + ".!!!.signature",
+	} {
+		if have := jwtExpiry(token); !have.IsZero() {
+			t.Errorf("%q: expected zero expiry, have %v", token, have)
+		}
+	}
+}
+
+func TestAPIKeyProvider(t *testing.T) {
+	p := NewAPIKeyProvider("a2V5OnNlY3JldA==")
+	if want, have := "ApiKey a2V5OnNlY3JldA==", authorization(t, p); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	refreshed, err := p.Refresh(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed {
+		t.Fatal("expected API key not to be refreshed")
+	}
+}
+
+func TestBasicAuthProviderRotates(t *testing.T) {
+	password := "first"
+	p := NewBasicAuthProvider(func(ctx context.Context) (string, string, error) {
+		return "user", password, nil
+	}, 0)
+	req, _ := http.NewRequest("GET", "http://127.0.0.1:9200/", nil)
+	if err := p.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if username, pwd, ok := req.BasicAuth(); !ok || username != "user" || pwd != "first" {
+		t.Fatalf("unexpected credentials %q:%q", username, pwd)
+	}
+
+	password = "second"
+	refreshed, err := p.Refresh(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !refreshed {
+		t.Fatal("expected credentials to be refreshed")
+	}
+	if err := p.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if username, pwd, ok := req.BasicAuth(); !ok || username != "user" || pwd != "second" {
+		t.Fatalf("unexpected credentials %q:%q", username, pwd)
+	}
+}
+
+func TestClientRefreshesCredentialsOnUnauthorized(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"type":"security_exception","reason":"token expired"},"status":401}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	tokens := []string{"expired", "fresh"}
+	var calls atomic.Int64
+	provider := NewBearerTokenProvider(func(ctx context.Context) (string, time.Time, error) {
+		n := int(calls.Add(1))
+		if n > len(tokens) {
+			n = len(tokens)
+		}
+		return tokens[n-1], time.Time{}, nil
+	})
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetAuthProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := http.StatusOK, res.StatusCode; want != have {
+		t.Fatalf("expected status %d, have %d", want, have)
+	}
+	if want, have := int64(2), requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+
+	// Credentials that are rejected after a refresh are not refreshed again
+	tokens = []string{"revoked"}
+	calls.Store(0)
+	provider.cache.value = "Bearer revoked"
+	requests.Store(0)
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	if !IsUnauthorized(err) {
+		t.Fatalf("expected unauthorized error, have %v", err)
+	}
+	if want, have := int64(1), requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package aws
+
+import (
+	"context"
+	"os"
+
+	"emperror.dev/errors"
+)
+
+// ErrMissingCredentials is returned when no AWS credentials are found.
+var ErrMissingCredentials = errors.New("aws: missing credentials")
+
+// Credentials are the AWS credentials used to sign a request.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional, for temporary credentials
+}
+
+// CredentialsProvider returns the AWS credentials to sign a request with.
+// It is called for every request, so implementations that fetch
+// credentials remotely should cache them.
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// CredentialsProviderFunc is an adapter to allow the use of ordinary
+// functions as a CredentialsProvider, e.g. to wrap the credentials
+// provider of the AWS SDK.
+type CredentialsProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Retrieve calls f.
+func (f CredentialsProviderFunc) Retrieve(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// StaticCredentialsProvider always returns the same credentials.
+type StaticCredentialsProvider struct {
+	credentials Credentials
+}
+
+// NewStaticCredentialsProvider creates a new StaticCredentialsProvider.
+// The session token is optional.
+func NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		credentials: Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+		},
+	}
+}
+
+// Retrieve returns the static credentials.
+func (p *StaticCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if p.credentials.AccessKeyID == "" || p.credentials.SecretAccessKey == "" {
+		return Credentials{}, ErrMissingCredentials
+	}
+	return p.credentials, nil
+}
+
+// EnvCredentialsProvider reads the credentials from the environment
+// variables AWS_ACCESS_KEY_ID (or AWS_ACCESS_KEY), AWS_SECRET_ACCESS_KEY
+// (or AWS_SECRET_KEY) and AWS_SESSION_TOKEN on every call.
+type EnvCredentialsProvider struct{}
+
+// NewEnvCredentialsProvider creates a new EnvCredentialsProvider.
+func NewEnvCredentialsProvider() *EnvCredentialsProvider {
+	return &EnvCredentialsProvider{}
+}
+
+// Retrieve returns the credentials found in the environment.
+func (p *EnvCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     getenv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: getenv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, ErrMissingCredentials
+	}
+	return creds, nil
+}
+
+// getenv returns the value of the first environment variable that is set.
+func getenv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
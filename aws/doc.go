@@ -0,0 +1,19 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+/*
+Package aws signs requests to Amazon OpenSearch Service and Amazon
+OpenSearch Serverless with AWS Signature Version 4.
+
+Use NewTransport or NewV4SigningClient together with
+opensearch.SetHttpClient or opensearch.SetTransport:
+
+	client, err := opensearch.NewClient(
+	  opensearch.SetURL("https://search-xxx.eu-west-1.es.amazonaws.com"),
+	  opensearch.SetSniff(false),
+	  opensearch.SetHealthcheck(false),
+	  opensearch.SetHttpClient(aws.NewV4SigningClient(aws.NewEnvCredentialsProvider(), "eu-west-1")),
+	)
+*/
+package aws
@@ -0,0 +1,251 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package aws
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// ServiceOpenSearch is the signing name of Amazon OpenSearch Service.
+	ServiceOpenSearch = "es"
+
+	// ServiceOpenSearchServerless is the signing name of Amazon OpenSearch
+	// Serverless.
+	ServiceOpenSearchServerless = "aoss"
+
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	shortDateFormat  = "20060102"
+
+	// EmptyPayloadHash is the SHA-256 hash of an empty request body.
+	EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// unsignedHeaders are never signed, as they may be changed by proxies
+// or the HTTP client on the way to AWS.
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// Signer signs HTTP requests with AWS Signature Version 4.
+//
+// See https://docs.aws.amazon.com/IAM/latest/UserGuide/reference_aws-signing.html
+// for details.
+type Signer struct {
+	credentials CredentialsProvider
+	region      string
+	service     string
+}
+
+// NewSigner creates a new Signer for the given region and service,
+// e.g. ServiceOpenSearch or ServiceOpenSearchServerless.
+func NewSigner(credentials CredentialsProvider, region, service string) *Signer {
+	return &Signer{
+		credentials: credentials,
+		region:      region,
+		service:     service,
+	}
+}
+
+// Sign signs the request in place, setting the X-Amz-Date, the optional
+// X-Amz-Security-Token and the Authorization headers. The payload hash
+// is the hex-encoded SHA-256 hash of the request body exactly as it is
+// sent, e.g. after gzip compression (see HashPayload).
+//
+// All headers present on the request, except for a few that are
+// commonly rewritten in transit, are signed.
+func (s *Signer) Sign(ctx context.Context, req *http.Request, payloadHash string, signTime time.Time) error {
+	if s.credentials == nil {
+		return ErrMissingCredentials
+	}
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return ErrMissingCredentials
+	}
+
+	signTime = signTime.UTC()
+	amzDate := signTime.Format(amzDateFormat)
+	shortDate := signTime.Format(shortDateFormat)
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+
+	canonicalHeaders, signedHeaders := s.canonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQueryString(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{shortDate, s.region, s.service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), shortDate)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm,
+		creds.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+	return nil
+}
+
+// HashPayload returns the hex-encoded SHA-256 hash of the payload.
+func HashPayload(payload []byte) string {
+	if len(payload) == 0 {
+		return EmptyPayloadHash
+	}
+	return hashHex(payload)
+}
+
+// canonicalHeaders returns the canonical headers and the list of
+// signed headers of the request.
+func (s *Signer) canonicalHeaders(req *http.Request) (string, string) {
+	headers := map[string][]string{
+		"host": {canonicalHost(req)},
+	}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if unsignedHeaders[name] || name == "host" {
+			continue
+		}
+		headers[name] = append(headers[name], values...)
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf strings.Builder
+	for _, name := range names {
+		values := make([]string, len(headers[name]))
+		for i, v := range headers[name] {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		buf.WriteString(name)
+		buf.WriteByte(':')
+		buf.WriteString(strings.Join(values, ","))
+		buf.WriteByte('\n')
+	}
+	return buf.String(), strings.Join(names, ";")
+}
+
+// canonicalHost returns the host of the request without default ports.
+func canonicalHost(req *http.Request) string {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		if (port == "443" && req.URL.Scheme == "https") || (port == "80" && req.URL.Scheme == "http") {
+			return h
+		}
+	}
+	return host
+}
+
+// canonicalURI returns the URI-encoded path. As required for all services
+// other than S3, the already escaped path is encoded a second time.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return escape(path, false)
+}
+
+// canonicalQueryString returns the query parameters, sorted by key and
+// value, with keys and values URI-encoded.
+func canonicalQueryString(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, escape(key, true)+"="+escape(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escape URI-encodes s as described by AWS: all characters except the
+// unreserved characters of RFC 3986 are percent-encoded, and '/' is
+// only encoded if encodeSlash is true.
+func escape(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(hexDigits[c>>4])
+			buf.WriteByte(hexDigits[c&15])
+		}
+	}
+	return buf.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
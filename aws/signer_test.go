@@ -0,0 +1,134 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package aws
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Signing vectors are taken from the AWS Signature Version 4 test suite
+// and the examples in the AWS documentation.
+func TestSignerVectors(t *testing.T) {
+	creds := NewStaticCredentialsProvider("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "")
+	signTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name          string
+		Service       string
+		Method        string
+		URL           string
+		Headers       map[string]string
+		Authorization string
+	}{
+		{
+			Name:          "get-vanilla",
+			Service:       "service",
+			Method:        "GET",
+			URL:           "https://example.amazonaws.com/",
+			Authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			Name:          "post-vanilla",
+			Service:       "service",
+			Method:        "POST",
+			URL:           "https://example.amazonaws.com/",
+			Authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			Name:          "get-vanilla-query-order-key-case",
+			Service:       "service",
+			Method:        "GET",
+			URL:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			Authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			Name:    "iam-list-users",
+			Service: "iam",
+			Method:  "GET",
+			URL:     "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08",
+			Headers: map[string]string{
+				"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
+			},
+			Authorization: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.Method, tt.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.Headers {
+				req.Header.Set(k, v)
+			}
+			signer := NewSigner(creds, "us-east-1", tt.Service)
+			if err := signer.Sign(context.Background(), req, EmptyPayloadHash, signTime); err != nil {
+				t.Fatal(err)
+			}
+			if want, have := tt.Authorization, req.Header.Get("Authorization"); want != have {
+				t.Fatalf("expected Authorization\n%s\ngot:\n%s", want, have)
+			}
+			if want, have := "20150830T123600Z", req.Header.Get("X-Amz-Date"); want != have {
+				t.Fatalf("expected X-Amz-Date %q; got: %q", want, have)
+			}
+		})
+	}
+}
+
+func TestSignerWithSessionToken(t *testing.T) {
+	creds := NewStaticCredentialsProvider("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "session-token")
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewSigner(creds, "us-east-1", ServiceOpenSearch)
+	if err := signer.Sign(context.Background(), req, EmptyPayloadHash, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "session-token", req.Header.Get("X-Amz-Security-Token"); want != have {
+		t.Fatalf("expected X-Amz-Security-Token %q; got: %q", want, have)
+	}
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Fatalf("expected session token to be signed; got: %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestSignerWithMissingCredentials(t *testing.T) {
+	req, err := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := NewSigner(NewStaticCredentialsProvider("", "", ""), "us-east-1", ServiceOpenSearch)
+	if err := signer.Sign(context.Background(), req, EmptyPayloadHash, time.Now()); err != ErrMissingCredentials {
+		t.Fatalf("expected %v; got: %v", ErrMissingCredentials, err)
+	}
+}
+
+func TestSignerCanonicalURI(t *testing.T) {
+	tests := []struct {
+		URL      string
+		Expected string
+	}{
+		{"https://example.com", "/"},
+		{"https://example.com/", "/"},
+		{"https://example.com/index/_search", "/index/_search"},
+		{"https://example.com/index1%2Cindex2/_search", "/index1%252Cindex2/_search"},
+		{"https://example.com/my%20index/_doc/1", "/my%2520index/_doc/1"},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", tt.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := tt.Expected, canonicalURI(req.URL); want != have {
+			t.Errorf("%s: expected %q; got: %q", tt.URL, want, have)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package aws
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Transport signs all requests with AWS Signature Version 4 before
+// passing them to the next http.RoundTripper.
+type Transport struct {
+	rt      http.RoundTripper
+	signer  *Signer
+	service string
+	now     func() time.Time
+}
+
+// Option signature for specifying options, e.g. WithRoundTripper.
+type Option func(t *Transport)
+
+// WithRoundTripper specifies the http.RoundTripper to call
+// next after this transport. If it is nil (default), the
+// transport will use http.DefaultTransport.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(t *Transport) {
+		t.rt = rt
+	}
+}
+
+// WithService specifies the AWS service to sign requests for.
+// It is ServiceOpenSearch by default; use ServiceOpenSearchServerless
+// for Amazon OpenSearch Serverless collections.
+func WithService(service string) Option {
+	return func(t *Transport) {
+		t.service = service
+	}
+}
+
+// NewTransport creates a new Transport that signs requests for the
+// given region with the credentials returned by the provider.
+func NewTransport(credentials CredentialsProvider, region string, opts ...Option) *Transport {
+	t := &Transport{
+		service: ServiceOpenSearch,
+		now:     time.Now,
+	}
+	for _, o := range opts {
+		o(t)
+	}
+	t.signer = NewSigner(credentials, region, t.service)
+	return t
+}
+
+// NewV4SigningClient returns an http.Client that signs all requests
+// with the given credentials for the given region.
+func NewV4SigningClient(credentials CredentialsProvider, region string, opts ...Option) *http.Client {
+	return &http.Client{
+		Transport: NewTransport(credentials, region, opts...),
+	}
+}
+
+// RoundTrip signs the request and passes it to the next http.RoundTripper.
+//
+// The request body is read to compute its hash, and is sent exactly as
+// passed, e.g. gzip-compressed if compression is enabled on the client.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Do not modify the request of the caller
+	signed := req.Clone(req.Context())
+
+	var payload []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		payload, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		signed.Body = io.NopCloser(bytes.NewReader(payload))
+		signed.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(payload)), nil
+		}
+		signed.ContentLength = int64(len(payload))
+	}
+
+	// Amazon OpenSearch Serverless requires the payload hash as a header
+	payloadHash := HashPayload(payload)
+	signed.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	if err := t.signer.Sign(req.Context(), signed, payloadHash, t.now()); err != nil {
+		return nil, err
+	}
+
+	if t.rt != nil {
+		return t.rt.RoundTrip(signed)
+	}
+	return http.DefaultTransport.RoundTrip(signed)
+}
@@ -0,0 +1,114 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package aws
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransport(t *testing.T) {
+	var (
+		gotBody    []byte
+		gotHeaders http.Header
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	// Send a gzip-compressed body, as the client does with SetGzip(true)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"query":{"match_all":{}}}`))
+	gz.Close()
+	payload := buf.Bytes()
+
+	client := NewV4SigningClient(
+		NewStaticCredentialsProvider("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", ""),
+		"eu-west-1",
+		WithService(ServiceOpenSearchServerless),
+	)
+	req, err := http.NewRequest("POST", ts.URL+"/tweets/_search", io.NopCloser(bytes.NewReader(payload)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if !bytes.Equal(payload, gotBody) {
+		t.Fatal("expected body to be sent unmodified")
+	}
+	if want, have := HashPayload(gotBody), gotHeaders.Get("X-Amz-Content-Sha256"); want != have {
+		t.Fatalf("expected X-Amz-Content-Sha256 %q; got: %q", want, have)
+	}
+	auth := gotHeaders.Get("Authorization")
+	if !strings.Contains(auth, "/eu-west-1/aoss/aws4_request") {
+		t.Fatalf("expected Authorization to be scoped to aoss; got: %s", auth)
+	}
+	if !strings.Contains(auth, "SignedHeaders=content-encoding;content-type;host;x-amz-content-sha256;x-amz-date,") {
+		t.Fatalf("expected headers to be signed; got: %s", auth)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Fatal("expected request of the caller to be left unmodified")
+	}
+}
+
+func TestTransportSignatureMatches(t *testing.T) {
+	creds := NewStaticCredentialsProvider("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "")
+	signTime := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	var got *http.Request
+	tr := NewTransport(creds, "us-east-1", WithRoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})))
+	tr.now = func() time.Time { return signTime }
+
+	req, err := http.NewRequest("PUT", "https://search-domain.eu-west-1.es.amazonaws.com/tweets/_doc/1", strings.NewReader(`{"user":"olivere"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign the same request again and compare
+	want, err := http.NewRequest("PUT", "https://search-domain.eu-west-1.es.amazonaws.com/tweets/_doc/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadHash := HashPayload([]byte(`{"user":"olivere"}`))
+	want.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := NewSigner(creds, "us-east-1", ServiceOpenSearch).Sign(want.Context(), want, payloadHash, signTime); err != nil {
+		t.Fatal(err)
+	}
+	if w, h := want.Header.Get("Authorization"), got.Header.Get("Authorization"); w != h {
+		t.Fatalf("expected Authorization\n%s\ngot:\n%s", w, h)
+	}
+	if want, have := int64(len(`{"user":"olivere"}`)), got.ContentLength; want != have {
+		t.Fatalf("expected ContentLength %d; got: %d", want, have)
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -7,7 +7,10 @@ package opensearch
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -18,6 +21,10 @@ var (
 	// a response item needs to be retried.
 	ErrBulkItemRetry = errors.New("opensearch: uncommitted bulk response items")
 
+	// ErrBulkProcessorClosed is returned when adding a request to a
+	// BulkProcessor that is not running.
+	ErrBulkProcessorClosed = errors.New("opensearch: bulk processor is closed")
+
 	defaultRetryItemStatusCodes = []int{408, 429, 503, 507}
 )
 
@@ -44,14 +51,17 @@ type BulkProcessorService struct {
 	c                    *Client
 	beforeFn             BulkBeforeFunc
 	afterFn              BulkAfterFunc
-	name                 string        // name of processor
-	numWorkers           int           // # of workers (>= 1)
-	bulkActions          int           // # of requests after which to commit
-	bulkSize             int           // # of bytes after which to commit
-	flushInterval        time.Duration // periodic flush interval
-	wantStats            bool          // indicates whether to gather statistics
-	backoff              Backoff       // a custom Backoff to use for errors
-	retryItemStatusCodes []int         // array of status codes for bulk response line items that may be retried
+	name                 string              // name of processor
+	numWorkers           int                 // # of workers (>= 1)
+	bulkActions          int                 // # of requests after which to commit
+	bulkSize             int                 // # of bytes after which to commit
+	flushInterval        time.Duration       // periodic flush interval
+	wantStats            bool                // indicates whether to gather statistics
+	backoff              Backoff             // a custom Backoff to use for errors
+	retryItemStatusCodes []int               // array of status codes for bulk response line items that may be retried
+	deadLetterSink       DeadLetterSink      // receives requests that cannot be committed
+	adaptive             *BulkAdaptivePolicy // adapt bulk actions and workers to the cluster
+	queueSize            int                 // capacity of the queue of added requests
 }
 
 // NewBulkProcessorService creates a new BulkProcessorService.
@@ -148,6 +158,36 @@ func (s *BulkProcessorService) RetryItemStatusCodes(retryItemStatusCodes ...int)
 	return s
 }
 
+// DeadLetterSink sets the sink for requests that cannot be committed,
+// i.e. items that failed with a status that is not retried, and requests
+// that are still failing after the Backoff has been exhausted. These are
+// given up and removed from the processor after being written to the
+// sink. Without a sink, they are only reported to the After callback,
+// and requests of a failed commit are kept to be committed again.
+func (s *BulkProcessorService) DeadLetterSink(sink DeadLetterSink) *BulkProcessorService {
+	s.deadLetterSink = sink
+	return s
+}
+
+// QueueSize sets the capacity of the queue of requests that have been
+// added, but not yet taken by a worker. When the queue is full, Add and
+// AddContext block and TryAdd fails, until the workers catch up. It
+// defaults to 0, i.e. requests are handed over to the workers directly.
+func (s *BulkProcessorService) QueueSize(size int) *BulkProcessorService {
+	s.queueSize = size
+	return s
+}
+
+// Adaptive enables the adaptive mode, in which the number of actions per
+// bulk request and the number of concurrent commits adapt to the latency
+// and rejections of the cluster, see BulkAdaptivePolicy. BulkActions and
+// Workers are used as defaults for the policy, and Workers also bounds the
+// number of concurrent commits.
+func (s *BulkProcessorService) Adaptive(policy *BulkAdaptivePolicy) *BulkProcessorService {
+	s.adaptive = policy
+	return s
+}
+
 // Do creates a new BulkProcessor and starts it.
 // Consider the BulkProcessor as a running instance that accepts bulk requests
 // and commits them to Opensearch, spreading the work across one or more
@@ -180,7 +220,10 @@ func (s *BulkProcessorService) Do(ctx context.Context) (*BulkProcessor, error) {
 		s.flushInterval,
 		s.wantStats,
 		s.backoff,
-		retryItemStatusCodes)
+		retryItemStatusCodes,
+		s.deadLetterSink,
+		s.adaptive,
+		s.queueSize)
 
 	err := p.Start(ctx)
 	if err != nil {
@@ -203,7 +246,14 @@ type BulkProcessorStats struct {
 	Succeeded int64 // # of requests that ES reported as successful
 	Failed    int64 // # of requests that ES reported as failed
 
-	Workers []*BulkProcessorWorkerStats // stats for each worker
+	DeadLettered int64 // # of requests written to the DeadLetterSink
+
+	QueueDepth    int   // # of requests in the queue, waiting for a worker
+	QueueCapacity int   // capacity of the queue, see QueueSize
+	QueueFull     int64 // # of requests rejected by TryAdd because the queue was full
+
+	Workers  []*BulkProcessorWorkerStats // stats for each worker
+	Adaptive *BulkProcessorAdaptiveStats // decisions of the adaptive mode, nil if disabled
 }
 
 // BulkProcessorWorkerStats represents per-worker statistics.
@@ -233,6 +283,11 @@ func (st *BulkProcessorStats) dup() *BulkProcessorStats {
 	dst.Deleted = st.Deleted
 	dst.Succeeded = st.Succeeded
 	dst.Failed = st.Failed
+	dst.DeadLettered = st.DeadLettered
+	dst.QueueDepth = st.QueueDepth
+	dst.QueueCapacity = st.QueueCapacity
+	dst.QueueFull = st.QueueFull
+	dst.Adaptive = st.Adaptive.dup()
 	for _, src := range st.Workers {
 		dst.Workers = append(dst.Workers, src.dup())
 	}
@@ -270,10 +325,20 @@ type BulkProcessor struct {
 	wantStats            bool
 	retryItemStatusCodes map[int]struct{}
 	backoff              Backoff
+	deadLetterSink       DeadLetterSink
+	adaptivePolicy       *BulkAdaptivePolicy
+	adaptive             *bulkAdaptiveController
+	queueSize            int
 
 	startedMu sync.Mutex // guards the following block
 	started   bool
 
+	// open is true while requests may be sent to requestsC. AddContext
+	// and TryAdd hold a read lock while sending, Close a write lock
+	// while closing the channel.
+	openMu sync.RWMutex
+	open   bool
+
 	statsMu sync.Mutex // guards the following block
 	stats   *BulkProcessorStats
 
@@ -292,6 +357,9 @@ func newBulkProcessor(
 	wantStats bool,
 	backoff Backoff,
 	retryItemStatusCodes map[int]struct{},
+	deadLetterSink DeadLetterSink,
+	adaptivePolicy *BulkAdaptivePolicy,
+	queueSize int,
 ) *BulkProcessor {
 	return &BulkProcessor{
 		c:                    client,
@@ -305,6 +373,9 @@ func newBulkProcessor(
 		wantStats:            wantStats,
 		retryItemStatusCodes: retryItemStatusCodes,
 		backoff:              backoff,
+		deadLetterSink:       deadLetterSink,
+		adaptivePolicy:       adaptivePolicy,
+		queueSize:            queueSize,
 	}
 }
 
@@ -323,9 +394,29 @@ func (p *BulkProcessor) Start(ctx context.Context) error {
 		p.numWorkers = 1
 	}
 
-	p.requestsC = make(chan BulkableRequest)
-	p.executionId = 0
+	// In adaptive mode, the controller limits the number of concurrent
+	// commits of the workers
+	var adaptive *bulkAdaptiveController
+	if p.adaptivePolicy != nil {
+		adaptive = newBulkAdaptiveController(*p.adaptivePolicy, p.bulkActions, p.numWorkers)
+	}
+
+	if p.queueSize < 0 {
+		p.queueSize = 0
+	}
+
+	// Stats may be called concurrently
+	p.statsMu.Lock()
+	p.adaptive = adaptive
+	p.requestsC = make(chan BulkableRequest, p.queueSize)
 	p.stats = newBulkProcessorStats(p.numWorkers)
+	p.statsMu.Unlock()
+
+	p.openMu.Lock()
+	p.open = true
+	p.openMu.Unlock()
+
+	p.executionId = 0
 	p.stopReconnC = make(chan struct{})
 
 	// Create and start up workers.
@@ -380,7 +471,10 @@ func (p *BulkProcessor) Close() error {
 	}
 
 	// Stop all workers.
+	p.openMu.Lock()
+	p.open = false
 	close(p.requestsC)
+	p.openMu.Unlock()
 	p.workerWg.Wait()
 
 	p.started = false
@@ -394,16 +488,63 @@ func (p *BulkProcessor) Close() error {
 func (p *BulkProcessor) Stats() BulkProcessorStats {
 	p.statsMu.Lock()
 	defer p.statsMu.Unlock()
-	return *p.stats.dup()
+	stats := p.stats.dup()
+	stats.QueueDepth = len(p.requestsC)
+	stats.QueueCapacity = cap(p.requestsC)
+	if p.adaptive != nil {
+		stats.Adaptive = p.adaptive.snapshot()
+	}
+	return *stats
 }
 
 // Add adds a single request to commit by the BulkProcessorService.
+// It blocks while the queue is full, see QueueSize.
 //
 // The caller is responsible for setting the index and type on the request.
 func (p *BulkProcessor) Add(request BulkableRequest) {
 	p.requestsC <- request
 }
 
+// AddContext adds a single request like Add, but returns the error of
+// the context if it is done before the request has been queued, and
+// ErrBulkProcessorClosed if the processor is not running.
+func (p *BulkProcessor) AddContext(ctx context.Context, request BulkableRequest) error {
+	p.openMu.RLock()
+	defer p.openMu.RUnlock()
+	if !p.open {
+		return ErrBulkProcessorClosed
+	}
+	select {
+	case p.requestsC <- request:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryAdd adds a single request like Add, but returns false instead of
+// blocking if the queue is full, e.g. to pause consuming from a source
+// until the workers catch up. It also returns false if the processor is
+// not running.
+func (p *BulkProcessor) TryAdd(request BulkableRequest) bool {
+	p.openMu.RLock()
+	defer p.openMu.RUnlock()
+	if !p.open {
+		return false
+	}
+	select {
+	case p.requestsC <- request:
+		return true
+	default:
+		p.statsMu.Lock()
+		if p.wantStats {
+			p.stats.QueueFull++
+		}
+		p.statsMu.Unlock()
+		return false
+	}
+}
+
 // Flush manually asks all workers to commit their outstanding requests.
 // It returns only when all workers acknowledge completion.
 func (p *BulkProcessor) Flush() error {
@@ -486,6 +627,8 @@ func (w *bulkWorker) work(ctx context.Context) {
 					if w.commitRequired() {
 						err = w.commit(ctx)
 					}
+				} else {
+					failBulkRequest(req, err)
 				}
 			} else {
 				// Channel closed: Stop.
@@ -493,6 +636,12 @@ func (w *bulkWorker) work(ctx context.Context) {
 				if w.service.NumberOfActions() > 0 {
 					err = w.commit(ctx)
 				}
+				if err != nil {
+					// There is no later commit for the pending requests
+					for _, req := range w.service.requests {
+						failBulkRequest(req, err)
+					}
+				}
 			}
 
 		case <-w.flushC:
@@ -503,7 +652,7 @@ func (w *bulkWorker) work(ctx context.Context) {
 			w.flushAckC <- struct{}{}
 		}
 		if err != nil {
-			w.p.c.errorf("opensearch: bulk processor %q was unable to perform work: %v", w.p.name, err)
+			w.p.c.log.Error("opensearch: bulk processor was unable to perform work", slog.String("processor", w.p.name), slog.Any("error", err))
 			if !stop {
 				waitForActive := func() {
 					// Add back pressure to prevent Add calls from filling up the request queue
@@ -526,28 +675,64 @@ func (w *bulkWorker) work(ctx context.Context) {
 func (w *bulkWorker) commit(ctx context.Context) error {
 	var res *BulkResponse
 
+	// Dead letters are only collected if there is a sink for them:
+	// failed items are given up immediately, retried items only if
+	// the last attempt fails. Requests are only dropped, and their
+	// results resolved, once the dead letters have been written
+	collect := w.p.deadLetterSink != nil
+	var failed []*DeadLetter
+	var failedItems []bulkFailedItem
+	var retried []*BulkResponseItem
+
+	// Requests added with AddWithResult are resolved with their items
+	tracked := hasTrackedBulkRequests(w.service.requests)
+
+	// In adaptive mode, wait for the turn of the worker, and learn
+	// from the latency and the rejected items of the commit
+	adaptive := w.p.adaptive
+	var observed bulkCommitResult
+	if adaptive != nil {
+		adaptive.acquire()
+		defer adaptive.release()
+		observed.actions = w.service.NumberOfActions()
+	}
+
 	// commitFunc will commit bulk requests and, on failure, be retried
 	// via exponential backoff
 	commitFunc := func() error {
 		var err error
 		// Save requests because they will be reset in service.Do
 		reqs := w.service.requests
+		retried = retried[:0]
+		start := time.Now()
 		res, err = w.service.Do(ctx)
+		observed.latency = time.Since(start)
+		observed.attempts++
 		if err == nil {
 			// Overall bulk request was OK.  But each bulk response item also has a status
-			if len(w.p.retryItemStatusCodes) > 0 {
+			check := res.Errors && (len(w.p.retryItemStatusCodes) > 0 || collect || adaptive != nil)
+			if (check || tracked) && res.Items != nil {
 				// Check res.Items since some might be soft failures
-				if res.Items != nil && res.Errors {
-					// res.Items will be 1 to 1 with reqs in same order
-					for i, item := range res.Items {
-						for _, result := range item {
-							if _, found := w.p.retryItemStatusCodes[result.Status]; found {
-								w.service.Add(reqs[i])
-								if err == nil {
-									err = ErrBulkItemRetry
-								}
+				// res.Items will be 1 to 1 with reqs in same order
+				for i, item := range res.Items {
+					for _, result := range item {
+						if result.Status == http.StatusTooManyRequests {
+							observed.rejected++
+						}
+						if _, found := w.p.retryItemStatusCodes[result.Status]; found {
+							w.service.Add(reqs[i])
+							retried = append(retried, result)
+							if err == nil {
+								err = ErrBulkItemRetry
 							}
+							continue
+						}
+						if collect && result.Error != nil {
+							failed = append(failed, newItemDeadLetter(unwrapBulkRequest(reqs[i]), result))
+							failedItems = append(failedItems, bulkFailedItem{req: reqs[i], item: result})
+							continue
 						}
+						resolveBulkRequest(reqs[i], result)
 					}
 				}
 			}
@@ -556,7 +741,7 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 	}
 	// notifyFunc will be called if retry fails
 	notifyFunc := func(err error) {
-		w.p.c.errorf("opensearch: bulk processor %q failed but may retry: %v", w.p.name, err)
+		w.p.c.log.Error("opensearch: bulk processor failed but may retry", slog.String("processor", w.p.name), slog.Any("error", err))
 	}
 
 	id := atomic.AddInt64(&w.p.executionId, 1)
@@ -568,8 +753,9 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 	}
 	w.p.statsMu.Unlock()
 
-	// Save requests because they will be reset in commitFunc
-	reqs := w.service.requests
+	// Save requests because they will be reset in commitFunc, and pass
+	// them to the callbacks as they have been added
+	reqs := unwrapBulkRequests(w.service.requests)
 
 	// Invoke before callback
 	if w.p.beforeFn != nil {
@@ -578,10 +764,53 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 
 	// Commit bulk requests
 	err := RetryNotify(commitFunc, w.p.backoff, notifyFunc)
-	w.updateStats(res)
+	var pending []BulkableRequest
 	if err != nil {
-		w.p.c.errorf("opensearch: bulk processor %q failed: %v", w.p.name, err)
+		w.p.c.log.Error("opensearch: bulk processor failed", slog.String("processor", w.p.name), slog.Any("error", err))
+		if collect {
+			// Give up the requests that are still pending
+			pending = w.service.requests
+			for i, req := range pending {
+				if errors.Is(err, ErrBulkItemRetry) {
+					// The pending requests are the retried ones, in the same order
+					failed = append(failed, newItemDeadLetter(unwrapBulkRequest(req), retried[i]))
+				} else {
+					failed = append(failed, newErrorDeadLetter(unwrapBulkRequest(req), err))
+				}
+			}
+		}
 	}
+	if len(failed) > 0 {
+		if werr := w.writeDeadLetters(ctx, failed); werr != nil {
+			// Keep the pending requests, as without a sink, and the failed
+			// items, so that their dead letters are written with the next
+			// commit
+			err = errors.Join(err, werr)
+			for _, fi := range failedItems {
+				w.service.Add(fi.req)
+			}
+			failedItems = nil
+		} else {
+			for i, req := range pending {
+				if errors.Is(err, ErrBulkItemRetry) {
+					resolveBulkRequest(req, retried[i])
+				} else {
+					failBulkRequest(req, err)
+				}
+			}
+			w.service.Reset()
+		}
+	}
+	// Failed items whose dead letters have been written are not
+	// committed again
+	for _, fi := range failedItems {
+		resolveBulkRequest(fi.req, fi.item)
+	}
+	if adaptive != nil {
+		observed.err = err
+		adaptive.observe(observed)
+	}
+	w.updateStats(res)
 
 	// Invoke after callback
 	if w.p.afterFn != nil {
@@ -591,6 +820,24 @@ func (w *bulkWorker) commit(ctx context.Context) error {
 	return err
 }
 
+// writeDeadLetters writes the requests that cannot be committed to the
+// DeadLetterSink.
+func (w *bulkWorker) writeDeadLetters(ctx context.Context, letters []*DeadLetter) error {
+	if err := w.p.deadLetterSink.Write(ctx, letters); err != nil {
+		w.p.c.log.Error("opensearch: bulk processor cannot write dead letters",
+			slog.String("processor", w.p.name),
+			slog.Int("requests", len(letters)),
+			slog.Any("error", err))
+		return fmt.Errorf("opensearch: cannot write dead letters: %w", err)
+	}
+	w.p.statsMu.Lock()
+	if w.p.wantStats {
+		w.p.stats.DeadLettered += int64(len(letters))
+	}
+	w.p.statsMu.Unlock()
+	return nil
+}
+
 func (w *bulkWorker) waitForActiveConnection(ready chan<- struct{}) {
 	defer close(ready)
 
@@ -599,14 +846,14 @@ func (w *bulkWorker) waitForActiveConnection(ready chan<- struct{}) {
 
 	client := w.p.c
 	stopReconnC := w.p.stopReconnC
-	w.p.c.errorf("opensearch: bulk processor %q is waiting for an active connection", w.p.name)
+	w.p.c.log.Error("opensearch: bulk processor is waiting for an active connection", slog.String("processor", w.p.name))
 
 	// loop until a health check finds at least 1 active connection or the reconnection channel is closed
 	for {
 		select {
 		case _, ok := <-stopReconnC:
 			if !ok {
-				w.p.c.errorf("opensearch: bulk processor %q active connection check interrupted", w.p.name)
+				w.p.c.log.Error("opensearch: bulk processor active connection check interrupted", slog.String("processor", w.p.name))
 				return
 			}
 		case <-t.C:
@@ -646,7 +893,11 @@ func (w *bulkWorker) updateStats(res *BulkResponse) {
 // or the estimated size in bytes is larger than specified in the
 // BulkProcessorService.
 func (w *bulkWorker) commitRequired() bool {
-	if w.bulkActions >= 0 && w.service.NumberOfActions() >= w.bulkActions {
+	bulkActions := w.bulkActions
+	if w.p.adaptive != nil {
+		bulkActions = w.p.adaptive.bulkActions()
+	}
+	if bulkActions >= 0 && w.service.NumberOfActions() >= bulkActions {
 		return true
 	}
 	if w.bulkSize >= 0 && w.service.EstimatedSizeInBytes() >= int64(w.bulkSize) {
@@ -0,0 +1,225 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBulkAdaptiveMinActions is the default lower bound of the
+	// number of actions per bulk request in adaptive mode.
+	DefaultBulkAdaptiveMinActions = 10
+	// DefaultBulkAdaptiveMaxActions is the default upper bound of the
+	// number of actions per bulk request in adaptive mode.
+	DefaultBulkAdaptiveMaxActions = 10000
+	// DefaultBulkAdaptiveTargetLatency is the default latency of a bulk
+	// request up to which adaptive mode grows the bulk requests.
+	DefaultBulkAdaptiveTargetLatency = time.Second
+)
+
+// BulkAdaptivePolicy enables the adaptive mode of BulkProcessor: Instead
+// of committing a fixed number of actions with a fixed number of workers,
+// the processor adapts both to the cluster, within the given bounds.
+//
+// After each commit, the processor shrinks the bulk requests by half and
+// removes a worker if items were rejected with status 429, e.g. with an
+// es_rejected_execution_exception, or if the commit had to be retried.
+// It shrinks the bulk requests by a quarter if the commit took longer
+// than TargetLatency. Otherwise, it grows the bulk requests by 10%, and
+// adds a worker once they have reached MaxBulkActions.
+//
+// BulkSize still applies, i.e. bulk requests are committed when they
+// exceed BulkSize, even if they have fewer actions.
+type BulkAdaptivePolicy struct {
+	// MinBulkActions and MaxBulkActions bound the number of actions per
+	// bulk request. They default to DefaultBulkAdaptiveMinActions and
+	// DefaultBulkAdaptiveMaxActions. The processor starts with
+	// BulkActions, if within the bounds.
+	MinBulkActions int
+	MaxBulkActions int
+
+	// MinWorkers and MaxWorkers bound the number of concurrent commits.
+	// MinWorkers defaults to 1, and MaxWorkers to Workers. The processor
+	// starts with MinWorkers. As each worker buffers its own bulk request,
+	// MaxWorkers is at most Workers, so that no more than Workers times
+	// MaxBulkActions requests are buffered.
+	MinWorkers int
+	MaxWorkers int
+
+	// TargetLatency is the latency of a commit up to which the bulk
+	// requests grow. It defaults to DefaultBulkAdaptiveTargetLatency.
+	TargetLatency time.Duration
+}
+
+// BulkProcessorAdaptiveStats are the decisions of the adaptive mode of
+// a BulkProcessor, see BulkAdaptivePolicy.
+type BulkProcessorAdaptiveStats struct {
+	BulkActions int // current # of actions per bulk request
+	Workers     int // current # of concurrent commits
+
+	Grown    int64 // # of times the bulk requests or workers have grown
+	Shrunk   int64 // # of times the bulk requests or workers have shrunk
+	Rejected int64 // # of items rejected with status 429
+	Retried  int64 // # of commits that had to be retried
+
+	LastDecision string        // "grow", "shrink" or "hold"
+	LastReason   string        // reason of the last decision
+	LastLatency  time.Duration // latency of the last commit
+}
+
+func (st *BulkProcessorAdaptiveStats) dup() *BulkProcessorAdaptiveStats {
+	if st == nil {
+		return nil
+	}
+	dst := *st
+	return &dst
+}
+
+// bulkCommitResult is what the adaptive mode learns from a commit.
+type bulkCommitResult struct {
+	actions  int           // # of actions in the commit
+	latency  time.Duration // latency of the last attempt
+	attempts int           // # of attempts
+	rejected int           // # of items rejected with status 429
+	err      error
+}
+
+// bulkAdaptiveController adapts the number of actions per bulk request
+// and the number of concurrent commits of a BulkProcessor.
+type bulkAdaptiveController struct {
+	policy BulkAdaptivePolicy
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	inFlight int // # of commits in flight
+	stats    BulkProcessorAdaptiveStats
+}
+
+// newBulkAdaptiveController returns a new controller for the policy,
+// with the settings of the processor as defaults.
+func newBulkAdaptiveController(policy BulkAdaptivePolicy, bulkActions, numWorkers int) *bulkAdaptiveController {
+	if policy.MinBulkActions <= 0 {
+		policy.MinBulkActions = DefaultBulkAdaptiveMinActions
+	}
+	if policy.MaxBulkActions <= 0 {
+		policy.MaxBulkActions = DefaultBulkAdaptiveMaxActions
+	}
+	if policy.MaxBulkActions < policy.MinBulkActions {
+		policy.MaxBulkActions = policy.MinBulkActions
+	}
+	if policy.MinWorkers <= 0 {
+		policy.MinWorkers = 1
+	}
+	if policy.MaxWorkers <= 0 || policy.MaxWorkers > numWorkers {
+		policy.MaxWorkers = numWorkers
+	}
+	if policy.MinWorkers > policy.MaxWorkers {
+		policy.MinWorkers = policy.MaxWorkers
+	}
+	if policy.TargetLatency <= 0 {
+		policy.TargetLatency = DefaultBulkAdaptiveTargetLatency
+	}
+
+	a := &bulkAdaptiveController{policy: policy}
+	a.cond = sync.NewCond(&a.mu)
+	a.stats.BulkActions = clampInt(bulkActions, policy.MinBulkActions, policy.MaxBulkActions)
+	a.stats.Workers = policy.MinWorkers
+	return a
+}
+
+// bulkActions returns the current number of actions per bulk request.
+func (a *bulkAdaptiveController) bulkActions() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats.BulkActions
+}
+
+// acquire waits until the worker may commit.
+func (a *bulkAdaptiveController) acquire() {
+	a.mu.Lock()
+	for a.inFlight >= a.stats.Workers {
+		a.cond.Wait()
+	}
+	a.inFlight++
+	a.mu.Unlock()
+}
+
+// release is called after the commit of a worker.
+func (a *bulkAdaptiveController) release() {
+	a.mu.Lock()
+	a.inFlight--
+	a.mu.Unlock()
+	a.cond.Broadcast()
+}
+
+// observe adapts the settings to the result of a commit.
+func (a *bulkAdaptiveController) observe(res bulkCommitResult) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := &a.stats
+	st.LastLatency = res.latency
+	st.Rejected += int64(res.rejected)
+	if res.attempts > 1 {
+		st.Retried++
+	}
+	bulkActions, workers := st.BulkActions, st.Workers
+	switch {
+	case res.rejected > 0 || res.attempts > 1 || res.err != nil:
+		bulkActions, workers = bulkActions/2, workers-1
+		switch {
+		case res.rejected > 0:
+			st.LastReason = "items rejected"
+		case res.err != nil:
+			st.LastReason = "commit failed"
+		default:
+			st.LastReason = "commit retried"
+		}
+	case res.latency > a.policy.TargetLatency:
+		bulkActions -= bulkActions / 4
+		st.LastReason = "latency above target"
+	case res.actions < bulkActions:
+		// The bulk request has been committed before it was full, e.g.
+		// by Flush or because of BulkSize: nothing to learn
+		st.LastDecision, st.LastReason = "hold", "bulk request not full"
+		return
+	case bulkActions < a.policy.MaxBulkActions:
+		bulkActions += max(1, bulkActions/10)
+		st.LastReason = "latency below target"
+	default:
+		workers++
+		st.LastReason = "latency below target"
+	}
+	bulkActions = clampInt(bulkActions, a.policy.MinBulkActions, a.policy.MaxBulkActions)
+	workers = clampInt(workers, a.policy.MinWorkers, a.policy.MaxWorkers)
+
+	switch {
+	case bulkActions > st.BulkActions || workers > st.Workers:
+		st.LastDecision = "grow"
+		st.Grown++
+	case bulkActions < st.BulkActions || workers < st.Workers:
+		st.LastDecision = "shrink"
+		st.Shrunk++
+	default:
+		st.LastDecision = "hold"
+	}
+	if workers > st.Workers {
+		defer a.cond.Broadcast()
+	}
+	st.BulkActions, st.Workers = bulkActions, workers
+}
+
+// snapshot returns the current stats.
+func (a *bulkAdaptiveController) snapshot() *BulkProcessorAdaptiveStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats.dup()
+}
+
+func clampInt(v, lo, hi int) int {
+	return min(max(v, lo), hi)
+}
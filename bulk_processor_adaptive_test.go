@@ -0,0 +1,191 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBulkAdaptiveControllerDefaults(t *testing.T) {
+	a := newBulkAdaptiveController(BulkAdaptivePolicy{}, 1000, 4)
+	if want, have := DefaultBulkAdaptiveMinActions, a.policy.MinBulkActions; want != have {
+		t.Errorf("want MinBulkActions=%d, have %d", want, have)
+	}
+	if want, have := DefaultBulkAdaptiveMaxActions, a.policy.MaxBulkActions; want != have {
+		t.Errorf("want MaxBulkActions=%d, have %d", want, have)
+	}
+	if want, have := 1, a.policy.MinWorkers; want != have {
+		t.Errorf("want MinWorkers=%d, have %d", want, have)
+	}
+	if want, have := 4, a.policy.MaxWorkers; want != have {
+		t.Errorf("want MaxWorkers=%d, have %d", want, have)
+	}
+	if want, have := DefaultBulkAdaptiveTargetLatency, a.policy.TargetLatency; want != have {
+		t.Errorf("want TargetLatency=%v, have %v", want, have)
+	}
+	if want, have := 1000, a.bulkActions(); want != have {
+		t.Errorf("want %d bulk actions, have %d", want, have)
+	}
+
+	// BulkActions is clamped to the bounds
+	a = newBulkAdaptiveController(BulkAdaptivePolicy{MinBulkActions: 50, MaxBulkActions: 500}, -1, 1)
+	if want, have := 50, a.bulkActions(); want != have {
+		t.Errorf("want %d bulk actions, have %d", want, have)
+	}
+
+	// MaxWorkers is bounded by the number of workers
+	a = newBulkAdaptiveController(BulkAdaptivePolicy{MinWorkers: 4, MaxWorkers: 8}, 1000, 2)
+	if want, have := 2, a.policy.MaxWorkers; want != have {
+		t.Errorf("want MaxWorkers=%d, have %d", want, have)
+	}
+	if want, have := 2, a.policy.MinWorkers; want != have {
+		t.Errorf("want MinWorkers=%d, have %d", want, have)
+	}
+}
+
+func TestBulkAdaptiveControllerObserve(t *testing.T) {
+	a := newBulkAdaptiveController(BulkAdaptivePolicy{
+		MinBulkActions: 10,
+		MaxBulkActions: 120,
+		MinWorkers:     1,
+		MaxWorkers:     3,
+		TargetLatency:  100 * time.Millisecond,
+	}, 100, 3)
+
+	tests := []struct {
+		Result      bulkCommitResult
+		BulkActions int
+		Workers     int
+		Decision    string
+		Reason      string
+	}{
+		// Fast commits grow the bulk requests up to the maximum, then the workers
+		{bulkCommitResult{actions: 100, latency: 10 * time.Millisecond, attempts: 1}, 110, 1, "grow", "latency below target"},
+		{bulkCommitResult{actions: 110, latency: 10 * time.Millisecond, attempts: 1}, 120, 1, "grow", "latency below target"},
+		{bulkCommitResult{actions: 120, latency: 10 * time.Millisecond, attempts: 1}, 120, 2, "grow", "latency below target"},
+		{bulkCommitResult{actions: 120, latency: 10 * time.Millisecond, attempts: 1}, 120, 3, "grow", "latency below target"},
+		{bulkCommitResult{actions: 120, latency: 10 * time.Millisecond, attempts: 1}, 120, 3, "hold", "latency below target"},
+		// Bulk requests that are not full tell nothing
+		{bulkCommitResult{actions: 5, latency: 10 * time.Millisecond, attempts: 1}, 120, 3, "hold", "bulk request not full"},
+		// Slow commits shrink the bulk requests
+		{bulkCommitResult{actions: 120, latency: 200 * time.Millisecond, attempts: 1}, 90, 3, "shrink", "latency above target"},
+		// Rejections and retries shrink bulk requests and workers
+		{bulkCommitResult{actions: 90, latency: 10 * time.Millisecond, attempts: 1, rejected: 5}, 45, 2, "shrink", "items rejected"},
+		{bulkCommitResult{actions: 45, latency: 10 * time.Millisecond, attempts: 2}, 22, 1, "shrink", "commit retried"},
+		{bulkCommitResult{actions: 22, latency: 10 * time.Millisecond, attempts: 3, err: errors.New("failed")}, 11, 1, "shrink", "commit failed"},
+		{bulkCommitResult{actions: 11, latency: 10 * time.Millisecond, attempts: 1, rejected: 1}, 10, 1, "shrink", "items rejected"},
+		{bulkCommitResult{actions: 10, latency: 10 * time.Millisecond, attempts: 1, rejected: 1}, 10, 1, "hold", "items rejected"},
+	}
+	for i, tt := range tests {
+		a.observe(tt.Result)
+		st := a.snapshot()
+		if st.BulkActions != tt.BulkActions || st.Workers != tt.Workers || st.LastDecision != tt.Decision || st.LastReason != tt.Reason {
+			t.Fatalf("#%d: want %d actions, %d workers, %s (%s); have %d actions, %d workers, %s (%s)",
+				i, tt.BulkActions, tt.Workers, tt.Decision, tt.Reason, st.BulkActions, st.Workers, st.LastDecision, st.LastReason)
+		}
+	}
+	st := a.snapshot()
+	if want, have := int64(4), st.Grown; want != have {
+		t.Errorf("want Grown=%d, have %d", want, have)
+	}
+	if want, have := int64(5), st.Shrunk; want != have {
+		t.Errorf("want Shrunk=%d, have %d", want, have)
+	}
+	if want, have := int64(7), st.Rejected; want != have {
+		t.Errorf("want Rejected=%d, have %d", want, have)
+	}
+	if want, have := int64(2), st.Retried; want != have {
+		t.Errorf("want Retried=%d, have %d", want, have)
+	}
+}
+
+func TestBulkAdaptiveControllerLimitsWorkers(t *testing.T) {
+	a := newBulkAdaptiveController(BulkAdaptivePolicy{MinWorkers: 1, MaxWorkers: 2}, 100, 2)
+	a.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		a.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected second commit to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// Growing the workers lets the second commit proceed
+	a.mu.Lock()
+	a.stats.BulkActions = DefaultBulkAdaptiveMaxActions
+	a.mu.Unlock()
+	a.observe(bulkCommitResult{actions: DefaultBulkAdaptiveMaxActions, latency: time.Millisecond, attempts: 1})
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("expected second commit to proceed")
+	}
+	a.release()
+	a.release()
+}
+
+func TestBulkProcessorAdaptive(t *testing.T) {
+	server := &bulkTestServer{}
+	client := newBulkTestClient(t, server)
+
+	p, err := client.BulkProcessor().
+		BulkActions(10).
+		BulkSize(-1).
+		Workers(2).
+		Backoff(StopBackoff{}).
+		RetryItemStatusCodes().
+		Adaptive(&BulkAdaptivePolicy{MinBulkActions: 5, MaxBulkActions: 20, MaxWorkers: 4}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		p.Add(newTestBulkIndexRequest(fmt.Sprint(i)))
+	}
+	p.Flush()
+	st := p.Stats().Adaptive
+	if st == nil {
+		t.Fatal("expected adaptive stats")
+	}
+	if want, have := 20, st.BulkActions; want != have {
+		t.Fatalf("want %d bulk actions, have %d", want, have)
+	}
+	if want, have := 2, st.Workers; want != have {
+		t.Fatalf("want %d workers, have %d", want, have)
+	}
+	if want, have := 2, len(p.Stats().Workers); want != have {
+		t.Fatalf("want %d worker stats, have %d", want, have)
+	}
+
+	// Rejections shrink the bulk requests
+	for i := 0; i < 20; i++ {
+		p.Add(newTestBulkIndexRequest(fmt.Sprintf("busy%d", i)))
+	}
+	p.Flush()
+	st = p.Stats().Adaptive
+	if want, have := "shrink", st.LastDecision; want != have {
+		t.Fatalf("want decision %q, have %q", want, have)
+	}
+	if st.BulkActions >= 20 {
+		t.Fatalf("expected bulk actions to shrink, have %d", st.BulkActions)
+	}
+	if want, have := int64(20), st.Rejected; want != have {
+		t.Fatalf("want %d rejected items, have %d", want, have)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 500, len(server.indexed()); want != have {
+		t.Fatalf("want %d indexed documents, have %d", want, have)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadLetter is a bulk request that BulkProcessor could not commit, either
+// because the item failed with a status that is not retried, or because
+// the Backoff was exhausted.
+type DeadLetter struct {
+	// Request is the failed request.
+	Request BulkableRequest
+	// Status is the status of the failed item, or of the failed bulk
+	// request. It is 0 if the bulk request failed without a response,
+	// e.g. because the cluster was unreachable.
+	Status int
+	// Reason describes why the request failed.
+	Reason string
+	// Error are the error details reported by Opensearch, if any.
+	Error *ErrorDetails
+	// Time is when the request has been given up.
+	Time time.Time
+}
+
+// deadLetterJSON is the JSON representation of a DeadLetter, with the
+// request as the lines of its bulk body.
+type deadLetterJSON struct {
+	Time    time.Time     `json:"time"`
+	Status  int           `json:"status,omitempty"`
+	Reason  string        `json:"reason"`
+	Error   *ErrorDetails `json:"error,omitempty"`
+	Request []string      `json:"request"`
+}
+
+// MarshalJSON encodes the dead letter, including the lines of the
+// request in the bulk body.
+func (d *DeadLetter) MarshalJSON() ([]byte, error) {
+	v := deadLetterJSON{
+		Time:   d.Time,
+		Status: d.Status,
+		Reason: d.Reason,
+		Error:  d.Error,
+	}
+	if d.Request != nil {
+		lines, err := d.Request.Source()
+		if err != nil {
+			return nil, err
+		}
+		v.Request = lines
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes a dead letter encoded with MarshalJSON. The
+// request can be added to a BulkProcessor or BulkService as is.
+func (d *DeadLetter) UnmarshalJSON(data []byte) error {
+	var v deadLetterJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*d = DeadLetter{
+		Time:   v.Time,
+		Status: v.Status,
+		Reason: v.Reason,
+		Error:  v.Error,
+	}
+	if len(v.Request) > 0 {
+		d.Request = bulkRawRequest(v.Request)
+	}
+	return nil
+}
+
+// bulkRawRequest is a bulk request given by the lines of its bulk body,
+// e.g. as read from a dead letter file.
+type bulkRawRequest []string
+
+// Source returns the lines of the bulk body.
+func (r bulkRawRequest) Source() ([]string, error) {
+	return r, nil
+}
+
+// String returns the lines of the bulk body.
+func (r bulkRawRequest) String() string {
+	return strings.Join(r, "\n")
+}
+
+// DeadLetterSink receives the requests that BulkProcessor could not
+// commit. Without a DeadLetterSink, these requests are only passed to the
+// After callback. Write is called by the workers of the processor and
+// must be safe for concurrent use. If Write fails, the requests that are
+// still pending and the items that failed are kept by the processor, as
+// without a sink, and the error is passed to the After callback.
+type DeadLetterSink interface {
+	Write(ctx context.Context, letters []*DeadLetter) error
+}
+
+// -- JSONL file --
+
+// JSONLDeadLetterSink writes dead letters as JSON lines, one per request,
+// e.g. to a file. Use ReplayDeadLetters to add them to a BulkProcessor
+// again.
+type JSONLDeadLetterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLDeadLetterSink returns a new JSONLDeadLetterSink that writes
+// to w.
+func NewJSONLDeadLetterSink(w io.Writer) *JSONLDeadLetterSink {
+	return &JSONLDeadLetterSink{w: w}
+}
+
+// NewJSONLDeadLetterFile returns a new JSONLDeadLetterSink that appends
+// to the file with the given name, which is created if necessary. Close
+// the sink after the BulkProcessor is closed.
+func NewJSONLDeadLetterFile(name string) (*JSONLDeadLetterSink, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONLDeadLetterSink{w: f, closer: f}, nil
+}
+
+// Write writes the dead letters, one line each.
+func (s *JSONLDeadLetterSink) Write(ctx context.Context, letters []*DeadLetter) error {
+	var buf []byte
+	for _, letter := range letters {
+		line, err := json.Marshal(letter)
+		if err != nil {
+			return err
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.w.Write(buf)
+	return err
+}
+
+// Close closes the file of a sink created with NewJSONLDeadLetterFile.
+func (s *JSONLDeadLetterSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// -- Channel --
+
+// ChannelDeadLetterSink sends dead letters to a channel. Write blocks
+// until the dead letters have been received, or the context of the
+// BulkProcessor is done.
+type ChannelDeadLetterSink struct {
+	c chan<- *DeadLetter
+}
+
+// NewChannelDeadLetterSink returns a new ChannelDeadLetterSink that
+// sends to c.
+func NewChannelDeadLetterSink(c chan<- *DeadLetter) *ChannelDeadLetterSink {
+	return &ChannelDeadLetterSink{c: c}
+}
+
+// Write sends the dead letters to the channel.
+func (s *ChannelDeadLetterSink) Write(ctx context.Context, letters []*DeadLetter) error {
+	for _, letter := range letters {
+		select {
+		case s.c <- letter:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// -- Replay --
+
+// ReplayDeadLetters reads dead letters written by a JSONLDeadLetterSink
+// from r, and adds their requests to the BulkProcessor. It returns the
+// number of requests added.
+func ReplayDeadLetters(ctx context.Context, r io.Reader, p *BulkProcessor) (int, error) {
+	var n int
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		letter := new(DeadLetter)
+		if err := dec.Decode(letter); errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, fmt.Errorf("opensearch: cannot read dead letter %d: %w", n+1, err)
+		}
+		if letter.Request == nil {
+			continue
+		}
+		if err := p.AddContext(ctx, letter.Request); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// ReplayDeadLetterFile adds the requests in the dead letter file with the
+// given name, see NewJSONLDeadLetterFile, to the BulkProcessor. It
+// returns the number of requests added.
+func ReplayDeadLetterFile(ctx context.Context, name string, p *BulkProcessor) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return ReplayDeadLetters(ctx, f, p)
+}
+
+// -- Collecting dead letters in the worker --
+
+// newItemDeadLetter returns a dead letter for a request that failed with the
+// given item of the bulk response.
+func newItemDeadLetter(req BulkableRequest, item *BulkResponseItem) *DeadLetter {
+	letter := &DeadLetter{
+		Request: req,
+		Status:  item.Status,
+		Error:   item.Error,
+		Time:    time.Now().UTC(),
+	}
+	if item.Error != nil {
+		letter.Reason = item.Error.Reason
+	}
+	if letter.Reason == "" {
+		letter.Reason = fmt.Sprintf("bulk item failed with status %d", item.Status)
+	}
+	return letter
+}
+
+// newErrorDeadLetter returns a dead letter for a request that failed
+// because the bulk request failed with err.
+func newErrorDeadLetter(req BulkableRequest, err error) *DeadLetter {
+	letter := &DeadLetter{
+		Request: req,
+		Reason:  err.Error(),
+		Time:    time.Now().UTC(),
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		letter.Status = e.Status
+		letter.Error = e.Details
+	}
+	return letter
+}
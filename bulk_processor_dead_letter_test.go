@@ -0,0 +1,391 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// bulkTestServer answers bulk requests with index actions. The status of
+// each item depends on the prefix of its id: "bad" fails with 400, "busy"
+// with 429, all other items succeed. If unavailable is set, the bulk
+// request fails as a whole.
+type bulkTestServer struct {
+	unavailable atomic.Bool
+	commits     atomic.Int64
+
+	mu  sync.Mutex
+	ids []string // ids of the items indexed successfully
+}
+
+func (s *bulkTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.unavailable.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"type":"unavailable_shards_exception","reason":"no shards"},"status":503}`))
+		return
+	}
+	s.commits.Add(1)
+
+	type item map[string]*BulkResponseItem
+	var items []item
+	var hasErrors bool
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 1<<20), 1<<20)
+	for scanner.Scan() {
+		var action map[string]struct {
+			Id string `json:"_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		scanner.Scan() // document
+		id := action["index"].Id
+		res := &BulkResponseItem{Index: "test", Id: id, Status: http.StatusCreated, Result: "created"}
+		switch {
+		case strings.HasPrefix(id, "bad"):
+			res.Status, res.Result = http.StatusBadRequest, ""
+			res.Error = &ErrorDetails{Type: "mapper_parsing_exception", Reason: "failed to parse field [age]"}
+		case strings.HasPrefix(id, "busy"):
+			res.Status, res.Result = http.StatusTooManyRequests, ""
+			res.Error = &ErrorDetails{Type: "es_rejected_execution_exception", Reason: "rejected execution"}
+		default:
+			s.mu.Lock()
+			s.ids = append(s.ids, id)
+			s.mu.Unlock()
+		}
+		hasErrors = hasErrors || res.Error != nil
+		items = append(items, item{"index": res})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"took": 1, "errors": hasErrors, "items": items})
+}
+
+func (s *bulkTestServer) indexed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ids...)
+}
+
+func newBulkTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func newTestBulkIndexRequest(id string) *BulkIndexRequest {
+	return NewBulkIndexRequest().Index("test").Id(id).Doc(map[string]interface{}{"id": id})
+}
+
+func TestBulkProcessorDeadLetterItems(t *testing.T) {
+	server := &bulkTestServer{}
+	client := newBulkTestClient(t, server)
+
+	letters := make(chan *DeadLetter, 10)
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(NewSimpleBackoff(1, 1, 1)).
+		DeadLetterSink(NewChannelDeadLetterSink(letters)).
+		Stats(true).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"1", "bad1", "2", "busy1"} {
+		p.Add(newTestBulkIndexRequest(id))
+	}
+	if err := p.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(letters)
+
+	// The failed item is given up at once, the busy one after two retries
+	if want, have := int64(3), server.commits.Load(); want != have {
+		t.Fatalf("want %d commits, have %d", want, have)
+	}
+	var have []*DeadLetter
+	for letter := range letters {
+		have = append(have, letter)
+	}
+	if want := 2; len(have) != want {
+		t.Fatalf("want %d dead letters, have %d", want, len(have))
+	}
+	if want, have := http.StatusBadRequest, have[0].Status; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "failed to parse field [age]", have[0].Reason; want != have {
+		t.Fatalf("want reason %q, have %q", want, have)
+	}
+	if want, have := "mapper_parsing_exception", have[0].Error.Type; want != have {
+		t.Fatalf("want error type %q, have %q", want, have)
+	}
+	if want, have := http.StatusTooManyRequests, have[1].Status; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+	if want, have := "busy1", have[1].Request.(*BulkIndexRequest).id; want != have {
+		t.Fatalf("want request %q, have %q", want, have)
+	}
+	if want, have := int64(2), p.Stats().DeadLettered; want != have {
+		t.Fatalf("want %d dead lettered requests, have %d", want, have)
+	}
+}
+
+func TestBulkProcessorDeadLetterFileAndReplay(t *testing.T) {
+	server := &bulkTestServer{}
+	server.unavailable.Store(true)
+	client := newBulkTestClient(t, server)
+
+	name := filepath.Join(t.TempDir(), "dlq.jsonl")
+	sink, err := NewJSONLDeadLetterFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var afterErr error
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(StopBackoff{}).
+		DeadLetterSink(sink).
+		After(func(executionId int64, requests []BulkableRequest, response *BulkResponse, err error) {
+			afterErr = err
+		}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		p.Add(newTestBulkIndexRequest(fmt.Sprint(i)))
+	}
+	p.Flush()
+	if !IsStatusCode(afterErr, http.StatusServiceUnavailable) {
+		t.Fatalf("expected status 503 in After, have %v", afterErr)
+	}
+
+	// The requests have been given up, so the next commit is empty
+	server.unavailable.Store(false)
+	p.Flush()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, len(server.indexed()); want != have {
+		t.Fatalf("want %d indexed documents, have %d", want, have)
+	}
+
+	// Replay the dead letters
+	p, err = client.BulkProcessor().BulkActions(-1).BulkSize(-1).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := ReplayDeadLetterFile(context.Background(), name, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, n; want != have {
+		t.Fatalf("want %d replayed requests, have %d", want, have)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "0,1,2", strings.Join(server.indexed(), ","); want != have {
+		t.Fatalf("want indexed documents %q, have %q", want, have)
+	}
+}
+
+// failingDeadLetterSink fails to write dead letters.
+type failingDeadLetterSink struct{}
+
+func (failingDeadLetterSink) Write(ctx context.Context, letters []*DeadLetter) error {
+	return errors.New("disk full")
+}
+
+func TestBulkProcessorDeadLetterSinkFailure(t *testing.T) {
+	server := &bulkTestServer{}
+	server.unavailable.Store(true)
+	client := newBulkTestClient(t, server)
+
+	var afterErr error
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(StopBackoff{}).
+		DeadLetterSink(failingDeadLetterSink{}).
+		After(func(executionId int64, requests []BulkableRequest, response *BulkResponse, err error) {
+			afterErr = err
+		}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.AddWithResult(context.Background(), newTestBulkIndexRequest("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Flush()
+	if afterErr == nil || !strings.Contains(afterErr.Error(), "disk full") {
+		t.Fatalf("expected sink error in After, have %v", afterErr)
+	}
+	select {
+	case <-res.Done():
+		t.Fatal("expected result to be pending")
+	default:
+	}
+
+	// The request has been kept, so the next commit indexes it
+	server.unavailable.Store(false)
+	p.Flush()
+	if _, err := res.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1", strings.Join(server.indexed(), ","); want != have {
+		t.Fatalf("want indexed documents %q, have %q", want, have)
+	}
+}
+
+// flakyDeadLetterSink fails to write dead letters while fail is set.
+type flakyDeadLetterSink struct {
+	fail atomic.Bool
+	DeadLetterSink
+}
+
+func (s *flakyDeadLetterSink) Write(ctx context.Context, letters []*DeadLetter) error {
+	if s.fail.Load() {
+		return errors.New("disk full")
+	}
+	return s.DeadLetterSink.Write(ctx, letters)
+}
+
+func TestBulkProcessorDeadLetterSinkFailureKeepsItems(t *testing.T) {
+	server := &bulkTestServer{}
+	client := newBulkTestClient(t, server)
+
+	letters := make(chan *DeadLetter, 10)
+	sink := &flakyDeadLetterSink{DeadLetterSink: NewChannelDeadLetterSink(letters)}
+	sink.fail.Store(true)
+
+	var afterErr error
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(StopBackoff{}).
+		DeadLetterSink(sink).
+		After(func(executionId int64, requests []BulkableRequest, response *BulkResponse, err error) {
+			afterErr = err
+		}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.AddWithResult(context.Background(), newTestBulkIndexRequest("bad1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Add(newTestBulkIndexRequest("1"))
+	p.Flush()
+	if afterErr == nil || !strings.Contains(afterErr.Error(), "disk full") {
+		t.Fatalf("expected sink error in After, have %v", afterErr)
+	}
+	select {
+	case <-res.Done():
+		t.Fatal("expected result to be pending")
+	default:
+	}
+	if want, have := int64(0), p.Stats().DeadLettered; want != have {
+		t.Fatalf("want %d dead letters, have %d", want, have)
+	}
+
+	// The failed item has been kept, so the next commit dead-letters it
+	sink.fail.Store(false)
+	p.Flush()
+	if _, err := res.Wait(context.Background()); !IsStatusCode(err, http.StatusBadRequest) {
+		t.Fatalf("expected status 400, have %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(letters); want != have {
+		t.Fatalf("want %d dead letters, have %d", want, have)
+	}
+	if want, have := http.StatusBadRequest, (<-letters).Status; want != have {
+		t.Fatalf("want dead letter with status %d, have %d", want, have)
+	}
+	if want, have := "1", strings.Join(server.indexed(), ","); want != have {
+		t.Fatalf("want indexed documents %q, have %q", want, have)
+	}
+}
+
+func TestDeadLetterJSON(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLDeadLetterSink(&buf)
+	at := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+	err := sink.Write(context.Background(), []*DeadLetter{
+		{
+			Request: newTestBulkIndexRequest("1"),
+			Status:  400,
+			Reason:  "failed to parse",
+			Error:   &ErrorDetails{Type: "mapper_parsing_exception", Reason: "failed to parse"},
+			Time:    at,
+		},
+		{
+			Request: NewBulkDeleteRequest().Index("test").Id("2"),
+			Reason:  "connection refused",
+			Time:    at,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, strings.Count(buf.String(), "\n"); want != have {
+		t.Fatalf("want %d lines, have %d: %s", want, have, buf.String())
+	}
+
+	dec := json.NewDecoder(&buf)
+	for i, want := range [][]string{
+		{`{"index":{"_index":"test","_id":"1"}}`, `{"id":"1"}`},
+		{`{"delete":{"_index":"test","_id":"2"}}`},
+	} {
+		var letter DeadLetter
+		if err := dec.Decode(&letter); err != nil {
+			t.Fatal(err)
+		}
+		have, err := letter.Request.Source()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(want, "\n") != strings.Join(have, "\n") {
+			t.Fatalf("#%d: want request %v, have %v", i, want, have)
+		}
+		if !letter.Time.Equal(at) {
+			t.Fatalf("#%d: want time %v, have %v", i, at, letter.Time)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBulkProcessorQueue(t *testing.T) {
+	server := &bulkTestServer{}
+	release := make(chan struct{})
+	client := newBulkTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		server.ServeHTTP(w, r)
+	}))
+
+	p, err := client.BulkProcessor().
+		Workers(1).
+		BulkActions(1).
+		BulkSize(-1).
+		QueueSize(2).
+		Stats(true).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker takes the first request and waits for the cluster,
+	// the next two requests fill the queue
+	for _, id := range []string{"1", "2", "3"} {
+		if err := p.AddContext(context.Background(), newTestBulkIndexRequest(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.TryAdd(newTestBulkIndexRequest("4")) {
+		t.Fatal("expected TryAdd to fail with a full queue")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := p.AddContext(ctx, newTestBulkIndexRequest("4")); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	stats := p.Stats()
+	if want, have := 2, stats.QueueDepth; want != have {
+		t.Fatalf("want queue depth %d, have %d", want, have)
+	}
+	if want, have := 2, stats.QueueCapacity; want != have {
+		t.Fatalf("want queue capacity %d, have %d", want, have)
+	}
+	if want, have := int64(1), stats.QueueFull; want != have {
+		t.Fatalf("want %d rejected requests, have %d", want, have)
+	}
+
+	// Once the cluster catches up, there is room again
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for !p.TryAdd(newTestBulkIndexRequest("4")) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected TryAdd to succeed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 4, len(server.indexed()); want != have {
+		t.Fatalf("want %d indexed documents, have %d", want, have)
+	}
+}
+
+func TestBulkProcessorStatsWhileRestarting(t *testing.T) {
+	client := newBulkTestClient(t, &bulkTestServer{})
+	p, err := client.BulkProcessor().
+		QueueSize(10).
+		Adaptive(&BulkAdaptivePolicy{}).
+		Stats(true).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			p.Stop()
+			p.Start(context.Background())
+		}
+	}()
+	for {
+		select {
+		case <-done:
+			if want, have := 10, p.Stats().QueueCapacity; want != have {
+				t.Fatalf("want queue capacity %d, have %d", want, have)
+			}
+			p.Close()
+			return
+		default:
+			p.Stats()
+		}
+	}
+}
+
+func TestBulkProcessorAddAfterClose(t *testing.T) {
+	client := newBulkTestClient(t, &bulkTestServer{})
+	p, err := client.BulkProcessor().QueueSize(1).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want, have := ErrBulkProcessorClosed, p.AddContext(context.Background(), newTestBulkIndexRequest("1")); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if p.TryAdd(newTestBulkIndexRequest("2")) {
+		t.Fatal("expected TryAdd to fail on a closed processor")
+	}
+	if _, err := p.AddWithResult(context.Background(), newTestBulkIndexRequest("3")); err != ErrBulkProcessorClosed {
+		t.Fatalf("want %v, have %v", ErrBulkProcessorClosed, err)
+	}
+
+	// Requests are accepted again after a restart
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !p.TryAdd(newTestBulkIndexRequest("4")) {
+		t.Fatal("expected TryAdd to succeed after a restart")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"sync"
+)
+
+// BulkResult is the result of a single request added to a BulkProcessor
+// with AddWithResult. It is resolved once the request has been committed
+// successfully, or has failed for good, i.e. after all retries.
+type BulkResult struct {
+	done chan struct{}
+	once sync.Once
+	item *BulkResponseItem
+	err  error
+}
+
+func newBulkResult() *BulkResult {
+	return &BulkResult{done: make(chan struct{})}
+}
+
+// Done returns a channel that is closed when the result is resolved.
+func (r *BulkResult) Done() <-chan struct{} {
+	return r.done
+}
+
+// Wait waits until the result is resolved, or the context is done. It
+// returns the item of the bulk response for the request, if any, and an
+// error if the request failed. If the item failed, the error is an *Error
+// with the status and details of the item.
+func (r *BulkResult) Wait(ctx context.Context) (*BulkResponseItem, error) {
+	select {
+	case <-r.done:
+		return r.item, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolve sets the result. Only the first call has an effect.
+func (r *BulkResult) resolve(item *BulkResponseItem, err error) {
+	r.once.Do(func() {
+		r.item, r.err = item, err
+		close(r.done)
+	})
+}
+
+// AddWithResult adds a single request like AddContext, and returns its
+// BulkResult, e.g. to find out whether a specific document has been
+// indexed before committing an offset upstream.
+//
+// The result is resolved with the item of the bulk response once the
+// item succeeds, or fails with a status that is not retried. Items that
+// are retried, see RetryItemStatusCodes, and requests of a failed bulk
+// request stay pending as long as the processor keeps them: With a
+// DeadLetterSink, they are resolved with the error when the Backoff is
+// exhausted. Without one, they are committed again with the next bulk
+// request, and only resolved with an error if the processor is stopped
+// before.
+func (p *BulkProcessor) AddWithResult(ctx context.Context, request BulkableRequest) (*BulkResult, error) {
+	tracked := &bulkTrackedRequest{BulkableRequest: request, result: newBulkResult()}
+	if err := p.AddContext(ctx, tracked); err != nil {
+		return nil, err
+	}
+	return tracked.result, nil
+}
+
+// bulkTrackedRequest is a request added with AddWithResult.
+type bulkTrackedRequest struct {
+	BulkableRequest
+	result *BulkResult
+}
+
+// bulkFailedItem is a request whose item failed with a status that is
+// not retried.
+type bulkFailedItem struct {
+	req  BulkableRequest
+	item *BulkResponseItem
+}
+
+// hasTrackedBulkRequests reports whether any of the requests has been
+// added with AddWithResult.
+func hasTrackedBulkRequests(reqs []BulkableRequest) bool {
+	for _, req := range reqs {
+		if _, ok := req.(*bulkTrackedRequest); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapBulkRequest returns the request as added by the caller.
+func unwrapBulkRequest(req BulkableRequest) BulkableRequest {
+	if tracked, ok := req.(*bulkTrackedRequest); ok {
+		return tracked.BulkableRequest
+	}
+	return req
+}
+
+// unwrapBulkRequests returns the requests as added by the caller.
+func unwrapBulkRequests(reqs []BulkableRequest) []BulkableRequest {
+	var unwrapped []BulkableRequest
+	for i, req := range reqs {
+		if tracked, ok := req.(*bulkTrackedRequest); ok {
+			if unwrapped == nil {
+				unwrapped = make([]BulkableRequest, len(reqs))
+				copy(unwrapped, reqs[:i])
+			}
+			unwrapped[i] = tracked.BulkableRequest
+		} else if unwrapped != nil {
+			unwrapped[i] = req
+		}
+	}
+	if unwrapped == nil {
+		return reqs
+	}
+	return unwrapped
+}
+
+// resolveBulkRequest resolves the result of a tracked request with the
+// item of the bulk response.
+func resolveBulkRequest(req BulkableRequest, item *BulkResponseItem) {
+	tracked, ok := req.(*bulkTrackedRequest)
+	if !ok {
+		return
+	}
+	if item.Error != nil {
+		tracked.result.resolve(item, &Error{Status: item.Status, Details: item.Error})
+		return
+	}
+	tracked.result.resolve(item, nil)
+}
+
+// failBulkRequest resolves the result of a tracked request with an error.
+func failBulkRequest(req BulkableRequest, err error) {
+	if tracked, ok := req.(*bulkTrackedRequest); ok {
+		tracked.result.resolve(nil, err)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBulkProcessorAddWithResult(t *testing.T) {
+	server := &bulkTestServer{}
+	client := newBulkTestClient(t, server)
+
+	var afterReqs []BulkableRequest
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(NewSimpleBackoff(1, 1)).
+		DeadLetterSink(NewChannelDeadLetterSink(make(chan *DeadLetter, 10))).
+		After(func(executionId int64, requests []BulkableRequest, response *BulkResponse, err error) {
+			if afterReqs == nil {
+				afterReqs = requests
+			}
+		}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	results := make(map[string]*BulkResult)
+	for _, id := range []string{"1", "bad1", "busy1"} {
+		res, err := p.AddWithResult(ctx, newTestBulkIndexRequest(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[id] = res
+	}
+	p.Add(newTestBulkIndexRequest("2"))
+	p.Flush()
+
+	item, err := results["1"].Wait(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "1", item.Id; want != have {
+		t.Fatalf("want id %q, have %q", want, have)
+	}
+	if want, have := http.StatusCreated, item.Status; want != have {
+		t.Fatalf("want status %d, have %d", want, have)
+	}
+
+	item, err = results["bad1"].Wait(ctx)
+	if !IsStatusCode(err, http.StatusBadRequest) {
+		t.Fatalf("expected status 400, have %v", err)
+	}
+	if want, have := "bad1", item.Id; want != have {
+		t.Fatalf("want id %q, have %q", want, have)
+	}
+
+	// The busy item is resolved when the backoff is exhausted
+	_, err = results["busy1"].Wait(ctx)
+	if !IsStatusCode(err, http.StatusTooManyRequests) {
+		t.Fatalf("expected status 429, have %v", err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Callbacks see the requests as they have been added
+	if want, have := 4, len(afterReqs); want != have {
+		t.Fatalf("want %d requests in After, have %d", want, have)
+	}
+	for i, req := range afterReqs {
+		if _, ok := req.(*BulkIndexRequest); !ok {
+			t.Fatalf("#%d: want *BulkIndexRequest, have %T", i, req)
+		}
+	}
+}
+
+func TestBulkProcessorAddWithResultFailsOnStop(t *testing.T) {
+	server := &bulkTestServer{}
+	server.unavailable.Store(true)
+	client := newBulkTestClient(t, server)
+
+	p, err := client.BulkProcessor().
+		BulkActions(-1).
+		BulkSize(-1).
+		Backoff(StopBackoff{}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := p.AddWithResult(context.Background(), newTestBulkIndexRequest("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a dead letter sink, the request stays pending after a failure
+	p.Flush()
+	select {
+	case <-res.Done():
+		t.Fatal("expected result to be pending")
+	default:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := res.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want %v, have %v", context.DeadlineExceeded, err)
+	}
+
+	// ... and fails if the processor is stopped before it is committed
+	p.Close()
+	if _, err := res.Wait(context.Background()); !IsStatusCode(err, http.StatusServiceUnavailable) {
+		t.Fatalf("expected status 503, have %v", err)
+	}
+}
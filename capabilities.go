@@ -0,0 +1,231 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/hashicorp/go-version"
+)
+
+// Capability is a feature of Opensearch, e.g. an endpoint or a parameter,
+// that is only available in some versions.
+type Capability struct {
+	// Name describes the feature, e.g. "synced flush (_flush/synced)".
+	Name string
+	// Since is the first version that supports the feature, or empty if
+	// all versions support it up to Until.
+	Since string
+	// Until is the first version that no longer supports the feature, or
+	// empty if it is still supported.
+	Until string
+
+	since, until *version.Version // parsed Since and Until, if known
+}
+
+// newCapability returns a capability with its bounds parsed once.
+func newCapability(name, since, until string) Capability {
+	c := Capability{Name: name, Since: since, Until: until}
+	if since != "" {
+		c.since = version.Must(version.NewVersion(since))
+	}
+	if until != "" {
+		c.until = version.Must(version.NewVersion(until))
+	}
+	return c
+}
+
+var (
+	// CapabilityMappingTypes are custom mapping types, i.e. a _type other
+	// than _doc. They have been removed in Opensearch 2.0.
+	CapabilityMappingTypes = newCapability("mapping types (_type)", "", "2.0.0")
+	// CapabilityIncludeTypeName is the include_type_name parameter of the
+	// mapping APIs, which has been removed in Opensearch 2.0.
+	CapabilityIncludeTypeName = newCapability("include_type_name parameter", "", "2.0.0")
+	// CapabilitySyncedFlush is the Synced Flush API, which has been
+	// removed in Opensearch 2.0.
+	CapabilitySyncedFlush = newCapability("synced flush (_flush/synced)", "", "2.0.0")
+	// CapabilityFreeze are the Freeze and Unfreeze Index APIs, which are
+	// not available in any version of Opensearch.
+	CapabilityFreeze = newCapability("freezing indices (_freeze and _unfreeze)", "", "1.0.0")
+	// CapabilityPointInTime are the Point in Time APIs, which have been
+	// added in Opensearch 2.4.
+	CapabilityPointInTime = newCapability("point in time (_search/point_in_time)", "2.4.0", "")
+)
+
+// SupportedBy returns true if the given version of Opensearch supports
+// the feature. If Since or Until is not a valid version, support is
+// unknown and SupportedBy returns true, i.e. Opensearch decides.
+func (c Capability) SupportedBy(v *version.Version) bool {
+	since, ok := parseCapabilityBound(c.since, c.Since)
+	if !ok {
+		return true
+	}
+	until, ok := parseCapabilityBound(c.until, c.Until)
+	if !ok {
+		return true
+	}
+	if since != nil && v.LessThan(since) {
+		return false
+	}
+	if until != nil && !v.LessThan(until) {
+		return false
+	}
+	return true
+}
+
+// parseCapabilityBound returns the parsed bound of a capability, or parses
+// it if the capability has not been created with newCapability. It returns
+// false if the bound is not a valid version.
+func parseCapabilityBound(parsed *version.Version, bound string) (*version.Version, bool) {
+	if parsed != nil || bound == "" {
+		return parsed, true
+	}
+	v, err := version.NewVersion(bound)
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// UnsupportedError is returned by services that use a feature which is
+// not supported by the version of the connected cluster. The request is
+// not sent to Opensearch in that case.
+type UnsupportedError struct {
+	Capability Capability
+	Version    string // version of the cluster
+}
+
+// Error returns a description of the error.
+func (e *UnsupportedError) Error() string {
+	switch {
+	case e.Capability.Since != "":
+		return fmt.Sprintf("opensearch: %s is not supported by Opensearch %s (requires %s or later)", e.Capability.Name, e.Version, e.Capability.Since)
+	case e.Capability.Until != "":
+		return fmt.Sprintf("opensearch: %s is not supported by Opensearch %s (removed in %s)", e.Capability.Name, e.Version, e.Capability.Until)
+	}
+	return fmt.Sprintf("opensearch: %s is not supported by Opensearch %s", e.Capability.Name, e.Version)
+}
+
+// SetClusterVersion sets the version of the cluster, e.g. "2.11.0", so
+// that the client does not need to detect it.
+func SetClusterVersion(v string) ClientOptionFunc {
+	return func(c *Client) error {
+		parsed, err := version.NewVersion(v)
+		if err != nil {
+			return errors.Wrapf(err, "invalid cluster version %q", v)
+		}
+		c.clusterVersion = parsed
+		return nil
+	}
+}
+
+// ClusterVersion returns the version of the cluster, e.g. "2.11.0". It is
+// detected when the client is created with healthchecks enabled, or on
+// the first call, and cached afterwards. A failure to detect it, e.g. if
+// the user may not call GET /, is cached for a minute.
+func (c *Client) ClusterVersion(ctx context.Context) (string, error) {
+	v, err := c.clusterVersionOf(ctx)
+	if err != nil {
+		return "", err
+	}
+	return v.Original(), nil
+}
+
+// Supports returns true if the connected cluster supports the feature.
+func (c *Client) Supports(ctx context.Context, capability Capability) (bool, error) {
+	v, err := c.clusterVersionOf(ctx)
+	if err != nil {
+		return false, err
+	}
+	return capability.SupportedBy(v), nil
+}
+
+// clusterVersionRetryInterval is how long a failure to detect the version
+// of the cluster is cached, e.g. if the user may not call GET /.
+const clusterVersionRetryInterval = time.Minute
+
+// clusterVersionOf returns the cached version of the cluster, or detects it.
+func (c *Client) clusterVersionOf(ctx context.Context) (*version.Version, error) {
+	c.mu.RLock()
+	v := c.clusterVersion
+	lastErr, lastErrAt := c.clusterVersionErr, c.clusterVersionErrAt
+	c.mu.RUnlock()
+	if v != nil {
+		return v, nil
+	}
+	if lastErr != nil && time.Since(lastErrAt) < clusterVersionRetryInterval {
+		return nil, lastErr
+	}
+
+	v, err := c.detectClusterVersion(ctx)
+	if err != nil {
+		if !IsContextErr(err) {
+			c.mu.Lock()
+			c.clusterVersionErr, c.clusterVersionErrAt = err, time.Now()
+			c.mu.Unlock()
+		}
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.clusterVersion = v
+	c.clusterVersionErr = nil
+	c.mu.Unlock()
+	return v, nil
+}
+
+// detectClusterVersion asks the cluster for its version.
+func (c *Client) detectClusterVersion(ctx context.Context) (*version.Version, error) {
+	res, err := c.PerformRequest(ctx, PerformRequestOptions{
+		Method: "GET",
+		Path:   "/",
+	})
+	if err != nil {
+		return nil, err
+	}
+	ret := new(PingResult)
+	if err := c.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	v, err := version.NewVersion(ret.Version.Number)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid cluster version %q", ret.Version.Number)
+	}
+	return v, nil
+}
+
+// checkCapability returns an UnsupportedError if the connected cluster
+// does not support the feature. If the version of the cluster cannot be
+// detected, the request is sent anyway and Opensearch decides.
+func (c *Client) checkCapability(ctx context.Context, capability Capability) error {
+	v, err := c.clusterVersionOf(ctx)
+	if err != nil {
+		if IsContextErr(err) {
+			return err
+		}
+		c.log.DebugContext(ctx, "opensearch: cannot detect cluster version", slog.Any("error", err))
+		return nil
+	}
+	if !capability.SupportedBy(v) {
+		return &UnsupportedError{Capability: capability, Version: v.Original()}
+	}
+	return nil
+}
+
+// checkMappingTypes returns an UnsupportedError if any of the types is a
+// custom mapping type and the connected cluster does not support them.
+func (c *Client) checkMappingTypes(ctx context.Context, types ...string) error {
+	for _, typ := range types {
+		if typ != "" && typ != "_doc" {
+			return c.checkCapability(ctx, CapabilityMappingTypes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func TestCapabilitySupportedBy(t *testing.T) {
+	tests := []struct {
+		Capability Capability
+		Version    string
+		Want       bool
+	}{
+		{CapabilityMappingTypes, "1.3.9", true},
+		{CapabilityMappingTypes, "2.0.0", false},
+		{CapabilityMappingTypes, "2.11.0", false},
+		{CapabilitySyncedFlush, "1.0.0", true},
+		{CapabilitySyncedFlush, "2.18.0", false},
+		{CapabilityFreeze, "1.0.0", false},
+		{CapabilityFreeze, "2.11.0", false},
+		{CapabilityPointInTime, "2.3.0", false},
+		{CapabilityPointInTime, "2.4.0", true},
+		{CapabilityPointInTime, "3.0.0", true},
+	}
+	for _, tt := range tests {
+		v := version.Must(version.NewVersion(tt.Version))
+		if want, have := tt.Want, tt.Capability.SupportedBy(v); want != have {
+			t.Errorf("%s on %s: want %v, have %v", tt.Capability.Name, tt.Version, want, have)
+		}
+	}
+}
+
+func TestCapabilitySupportedByInvalidBounds(t *testing.T) {
+	v := version.Must(version.NewVersion("2.11.0"))
+	for _, c := range []Capability{
+		{Name: "invalid since", Since: "not-a-version"},
+		{Name: "invalid until", Until: "2.x"},
+	} {
+		if !c.SupportedBy(v) {
+			t.Errorf("%s: expected support to be unknown, i.e. true", c.Name)
+		}
+	}
+	if (Capability{Name: "custom", Since: "3.0.0"}).SupportedBy(v) {
+		t.Error("custom: expected not to be supported")
+	}
+}
+
+func TestUnsupportedError(t *testing.T) {
+	err := error(&UnsupportedError{Capability: CapabilitySyncedFlush, Version: "2.11.0"})
+	if want, have := "opensearch: synced flush (_flush/synced) is not supported by Opensearch 2.11.0 (removed in 2.0.0)", err.Error(); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if !IsUnsupported(err) {
+		t.Fatal("expected IsUnsupported = true")
+	}
+	if IsUnsupported(ErrNoClient) {
+		t.Fatal("expected IsUnsupported = false")
+	}
+}
+
+// versionServer returns a server that reports the given version, and
+// the number of requests other than for detecting the version.
+func versionServer(t *testing.T, number string) (*httptest.Server, *atomic.Int64) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			if r.Method == "GET" {
+				w.Write([]byte(`{"name":"node-0","cluster_name":"test","version":{"distribution":"opensearch","number":"` + number + `"}}`))
+			}
+			return
+		}
+		requests.Add(1)
+		w.Write([]byte(`{"_index":"tweets","_id":"1","result":"created","_shards":{"total":1,"successful":1,"failed":0}}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &requests
+}
+
+func TestClientDetectsClusterVersion(t *testing.T) {
+	ts, _ := versionServer(t, "2.11.0")
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	client.mu.RLock()
+	detected := client.clusterVersion
+	client.mu.RUnlock()
+	if detected == nil {
+		t.Fatal("expected cluster version to be detected at dial time")
+	}
+	v, err := client.ClusterVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "2.11.0", v; want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	ok, err := client.Supports(context.Background(), CapabilityPointInTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("expected point in time to be supported")
+	}
+}
+
+func TestClientFailsFastOnUnsupportedCapability(t *testing.T) {
+	ts, requests := versionServer(t, "2.11.0")
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The version is detected on first use
+	_, err = client.Index().Index("tweets").Type("tweet").Id("1").BodyString(`{}`).Do(context.Background())
+	if !IsUnsupported(err) {
+		t.Fatalf("expected unsupported error, have %v", err)
+	}
+	_, err = client.SyncedFlush("tweets").Do(context.Background())
+	if !IsUnsupported(err) {
+		t.Fatalf("expected unsupported error, have %v", err)
+	}
+	_, err = client.PutMapping().Index("tweets").IncludeTypeName(false).BodyString(`{}`).Do(context.Background())
+	if !IsUnsupported(err) {
+		t.Fatalf("expected unsupported error, have %v", err)
+	}
+	if want, have := int64(0), requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+
+	// Requests without custom types are sent as usual
+	_, err = client.Index().Index("tweets").Id("1").BodyString(`{}`).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+}
+
+func TestClientWithClusterVersion(t *testing.T) {
+	ts, requests := versionServer(t, "2.11.0")
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetClusterVersion("1.3.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Index().Index("tweets").Type("tweet").Id("1").BodyString(`{}`).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+	_, err = client.OpenPointInTime("tweets").KeepAlive("1m").Do(context.Background())
+	if !IsUnsupported(err) {
+		t.Fatalf("expected unsupported error, have %v", err)
+	}
+
+	if _, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false), SetClusterVersion("latest")); err == nil {
+		t.Fatal("expected error for invalid version")
+	}
+}
+
+func TestClientCachesClusterVersionFailure(t *testing.T) {
+	var detections atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/" {
+			detections.Add(1)
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"type":"security_exception","reason":"no permissions for [cluster:monitor/main]"},"status":403}`))
+			return
+		}
+		w.Write([]byte(`{"_index":"tweets","_id":"1","result":"created","_shards":{"total":1,"successful":1,"failed":0}}`))
+	}))
+	defer ts.Close()
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests are sent anyway, but the version is only detected once
+	for i := 0; i < 3; i++ {
+		_, err = client.Index().Index("tweets").Type("tweet").Id("1").BodyString(`{}`).Do(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := int64(1), detections.Load(); want != have {
+		t.Fatalf("expected %d detections, have %d", want, have)
+	}
+	if _, err := client.ClusterVersion(context.Background()); !IsStatusCode(err, http.StatusForbidden) {
+		t.Fatalf("expected status 403, have %v", err)
+	}
+}
@@ -7,8 +7,10 @@ package opensearch
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
@@ -18,9 +20,11 @@ import (
 	"time"
 
 	"emperror.dev/errors"
+	"github.com/hashicorp/go-version"
 	"github.com/sirupsen/logrus"
 
 	"github.com/disaster37/opensearch/v2/config"
+	"github.com/disaster37/opensearch/v2/internal/operation"
 )
 
 const (
@@ -53,6 +57,15 @@ const (
 	// two health checks of the nodes in the cluster.
 	DefaultHealthcheckInterval = 60 * time.Second
 
+	// DefaultResurrectTimeoutInitial is the time a node is kept dead after
+	// a failure before a single request probes whether it is back. The
+	// timeout doubles with every consecutive failure of the node.
+	DefaultResurrectTimeoutInitial = 60 * time.Second
+
+	// DefaultResurrectTimeoutMax is the maximum time a node is kept dead
+	// before it is probed again.
+	DefaultResurrectTimeoutMax = 30 * time.Minute
+
 	// DefaultSnifferEnabled specifies if the sniffer is enabled by default.
 	DefaultSnifferEnabled = true
 
@@ -99,9 +112,6 @@ var (
 
 	// noRetries is a retrier that does not retry.
 	noRetries = NewStopRetrier()
-
-	// noDeprecationLog is a no-op for logging deprecations.
-	noDeprecationLog = func(*http.Request, *http.Response) {}
 )
 
 // Doer is an interface to perform HTTP requests.
@@ -120,34 +130,42 @@ type Client struct {
 
 	connsMu sync.RWMutex // connsMu guards the next block
 	conns   []*conn      // all connections
-	cindex  int          // index into conns
 
 	mu                        sync.RWMutex // guards the next block
 	urls                      []string     // set of URLs passed initially to the client
 	running                   bool         // true if the client's background processes are running
-	log                       *logrus.Logger
-	deprecationlog            func(*http.Request, *http.Response)
-	scheme                    string          // http or https
-	healthcheckEnabled        bool            // healthchecks enabled or disabled
-	healthcheckTimeoutStartup time.Duration   // time the healthcheck waits for a response from Opensearch on startup
-	healthcheckTimeout        time.Duration   // time the healthcheck waits for a response from Opensearch
-	healthcheckInterval       time.Duration   // interval between healthchecks
-	healthcheckStop           chan bool       // notify healthchecker to stop, and notify back
-	snifferEnabled            bool            // sniffer enabled or disabled
-	snifferTimeoutStartup     time.Duration   // time the sniffer waits for a response from nodes info API on startup
-	snifferTimeout            time.Duration   // time the sniffer waits for a response from nodes info API
-	snifferInterval           time.Duration   // interval between sniffing
-	snifferCallback           SnifferCallback // callback to modify the sniffing decision
-	snifferStop               chan bool       // notify sniffer to stop, and notify back
-	decoder                   Decoder         // used to decode data sent from Opensearch
-	basicAuthUsername         string          // username for HTTP Basic Auth
-	basicAuthPassword         string          // password for HTTP Basic Auth
-	sendGetBodyAs             string          // override for when sending a GET with a body
-	gzipEnabled               bool            // gzip compression enabled or disabled (default)
-	requiredPlugins           []string        // list of required plugins
-	retrier                   Retrier         // strategy for retries
-	retryStatusCodes          []int           // HTTP status codes where to retry automatically (with retrier)
-	headers                   http.Header     // a list of default headers to add to each request
+	log                       *slog.Logger
+	deprecationHandler        DeprecationHandler
+	scheme                    string           // http or https
+	healthcheckEnabled        bool             // healthchecks enabled or disabled
+	healthcheckTimeoutStartup time.Duration    // time the healthcheck waits for a response from Opensearch on startup
+	healthcheckTimeout        time.Duration    // time the healthcheck waits for a response from Opensearch
+	healthcheckInterval       time.Duration    // interval between healthchecks
+	healthcheckStop           chan bool        // notify healthchecker to stop, and notify back
+	resurrectTimeoutInitial   time.Duration    // time a node is kept dead after its first failure
+	resurrectTimeoutMax       time.Duration    // maximum time a node is kept dead
+	snifferEnabled            bool             // sniffer enabled or disabled
+	snifferTimeoutStartup     time.Duration    // time the sniffer waits for a response from nodes info API on startup
+	snifferTimeout            time.Duration    // time the sniffer waits for a response from nodes info API
+	snifferInterval           time.Duration    // interval between sniffing
+	snifferCallback           SnifferCallback  // callback to modify the sniffing decision
+	snifferStop               chan bool        // notify sniffer to stop, and notify back
+	decoder                   Decoder          // used to decode data sent from Opensearch
+	basicAuthUsername         string           // username for HTTP Basic Auth
+	basicAuthPassword         string           // password for HTTP Basic Auth
+	authProvider              AuthProvider     // authenticates requests instead of HTTP Basic Auth
+	clusterVersion            *version.Version // version of the cluster, nil if not detected yet
+	clusterVersionErr         error            // last error detecting the version of the cluster
+	clusterVersionErrAt       time.Time        // time of clusterVersionErr
+	sendGetBodyAs             string           // override for when sending a GET with a body
+	gzipEnabled               bool             // gzip compression enabled or disabled (default)
+	requiredPlugins           []string         // list of required plugins
+	retrier                   Retrier          // strategy for retries
+	retryStatusCodes          []int            // HTTP status codes where to retry automatically (with retrier)
+	retryBudget               RetryBudget      // limits the total time and attempts spent on a request
+	headers                   http.Header      // a list of default headers to add to each request
+	selector                  Selector         // strategy to pick the connection for the next request
+	middleware                []Middleware     // middleware to wrap PerformRequest with
 }
 
 // NewClient creates a new client to work with Opensearch.
@@ -231,15 +249,17 @@ func NewClientFromConfig(cfg *config.Config) (*Client, error) {
 func NewSimpleClient(options ...ClientOptionFunc) (*Client, error) {
 	c := &Client{
 		c:                         http.DefaultClient,
-		log:                       logrus.StandardLogger(),
+		log:                       newLogrusLogger(logrus.StandardLogger()),
 		conns:                     make([]*conn, 0),
-		cindex:                    -1,
+		selector:                  NewRoundRobinSelector(),
 		scheme:                    DefaultScheme,
 		decoder:                   &DefaultDecoder{},
 		healthcheckEnabled:        false,
 		healthcheckTimeoutStartup: off,
 		healthcheckTimeout:        off,
 		healthcheckInterval:       off,
+		resurrectTimeoutInitial:   DefaultResurrectTimeoutInitial,
+		resurrectTimeoutMax:       DefaultResurrectTimeoutMax,
 		healthcheckStop:           make(chan bool),
 		snifferEnabled:            false,
 		snifferTimeoutStartup:     off,
@@ -251,7 +271,6 @@ func NewSimpleClient(options ...ClientOptionFunc) (*Client, error) {
 		gzipEnabled:               DefaultGzipEnabled,
 		retrier:                   noRetries, // no retries by default
 		retryStatusCodes:          nil,       // no automatic retries for specific HTTP status codes
-		deprecationlog:            noDeprecationLog,
 	}
 
 	// Run the options on it
@@ -318,15 +337,17 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 	// Set up the client
 	c := &Client{
 		c:                         http.DefaultClient,
-		log:                       logrus.StandardLogger(),
+		log:                       newLogrusLogger(logrus.StandardLogger()),
 		conns:                     make([]*conn, 0),
-		cindex:                    -1,
+		selector:                  NewRoundRobinSelector(),
 		scheme:                    DefaultScheme,
 		decoder:                   &DefaultDecoder{},
 		healthcheckEnabled:        DefaultHealthcheckEnabled,
 		healthcheckTimeoutStartup: DefaultHealthcheckTimeoutStartup,
 		healthcheckTimeout:        DefaultHealthcheckTimeout,
 		healthcheckInterval:       DefaultHealthcheckInterval,
+		resurrectTimeoutInitial:   DefaultResurrectTimeoutInitial,
+		resurrectTimeoutMax:       DefaultResurrectTimeoutMax,
 		healthcheckStop:           make(chan bool),
 		snifferEnabled:            DefaultSnifferEnabled,
 		snifferTimeoutStartup:     DefaultSnifferTimeoutStartup,
@@ -338,7 +359,6 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 		gzipEnabled:               DefaultGzipEnabled,
 		retrier:                   noRetries, // no retries by default
 		retryStatusCodes:          nil,       // no automatic retries for specific HTTP status codes
-		deprecationlog:            noDeprecationLog,
 	}
 
 	// Run the options on it
@@ -395,6 +415,14 @@ func DialContext(ctx context.Context, options ...ClientOptionFunc) (*Client, err
 		return nil, err
 	}
 
+	// Detect the version of the cluster, so that services fail fast when
+	// using features it does not support
+	if c.healthcheckEnabled {
+		if _, err := c.clusterVersionOf(ctx); err != nil {
+			c.log.InfoContext(ctx, "opensearch: cannot detect cluster version", slog.Any("error", err))
+		}
+	}
+
 	// Check the required plugins
 	for _, plugin := range c.requiredPlugins {
 		found, err := c.HasPlugin(plugin)
@@ -445,9 +473,60 @@ func configToOptions(cfg *config.Config) ([]ClientOptionFunc, error) {
 		if cfg.Sniff != nil {
 			options = append(options, SetSniff(*cfg.Sniff))
 		}
+		if cfg.SniffTimeoutStartup > 0 {
+			options = append(options, SetSnifferTimeoutStartup(cfg.SniffTimeoutStartup))
+		}
+		if cfg.SniffTimeout > 0 {
+			options = append(options, SetSnifferTimeout(cfg.SniffTimeout))
+		}
+		if cfg.SniffInterval > 0 {
+			options = append(options, SetSnifferInterval(cfg.SniffInterval))
+		}
 		if cfg.Healthcheck != nil {
 			options = append(options, SetHealthcheck(*cfg.Healthcheck))
 		}
+		if cfg.HealthcheckTimeoutStartup > 0 {
+			options = append(options, SetHealthcheckTimeoutStartup(cfg.HealthcheckTimeoutStartup))
+		}
+		if cfg.HealthcheckTimeout > 0 {
+			options = append(options, SetHealthcheckTimeout(cfg.HealthcheckTimeout))
+		}
+		if cfg.HealthcheckInterval > 0 {
+			options = append(options, SetHealthcheckInterval(cfg.HealthcheckInterval))
+		}
+		if cfg.Gzip != nil {
+			options = append(options, SetGzip(*cfg.Gzip))
+		}
+
+		if token := cfg.Token; token != "" {
+			options = append(options, SetAuthProvider(NewBearerTokenProvider(func(context.Context) (string, time.Time, error) {
+				return token, time.Time{}, nil
+			})))
+		}
+		if len(cfg.Headers) > 0 {
+			headers := make(http.Header)
+			for name, value := range cfg.Headers {
+				headers.Set(name, value)
+			}
+			options = append(options, SetHeaders(headers))
+		}
+
+		switch {
+		case cfg.BackoffInitial > 0:
+			backoffMax := cfg.BackoffMax
+			if backoffMax <= 0 {
+				backoffMax = 10 * cfg.BackoffInitial
+			}
+			options = append(options, SetRetrier(NewBackoffRetrier(NewExponentialBackoff(cfg.BackoffInitial, backoffMax))))
+			if cfg.MaxRetries != nil {
+				options = append(options, SetRetryBudget(RetryBudget{MaxAttempts: *cfg.MaxRetries + 1}))
+			}
+		case cfg.MaxRetries != nil:
+			options = append(options, SetMaxRetries(*cfg.MaxRetries))
+		}
+		if len(cfg.RetryStatusCodes) > 0 {
+			options = append(options, SetRetryStatusCodes(cfg.RetryStatusCodes...))
+		}
 
 		if cfg.Logger != nil {
 			options = append(options, SetLogger(cfg.Logger))
@@ -455,6 +534,25 @@ func configToOptions(cfg *config.Config) ([]ClientOptionFunc, error) {
 
 		if cfg.Transport != nil {
 			options = append(options, SetTransport(cfg.Transport))
+		} else if cfg.HasTLS() || cfg.Timeout > 0 {
+			var transport *http.Transport
+			if cfg.HasTLS() {
+				tlsConfig, err := NewTLSConfig(TLSOptions{
+					CAFile:             cfg.TLSCAFile,
+					CertFile:           cfg.TLSCertFile,
+					KeyFile:            cfg.TLSKeyFile,
+					ServerName:         cfg.TLSServerName,
+					MinVersion:         cfg.TLSMinVersion,
+					InsecureSkipVerify: cfg.TLSInsecureSkipVerify,
+				})
+				if err != nil {
+					return nil, err
+				}
+				transport = newTLSTransport(tlsConfig)
+			} else {
+				transport = http.DefaultTransport.(*http.Transport).Clone()
+			}
+			options = append(options, SetHttpClient(&http.Client{Transport: transport, Timeout: cfg.Timeout}))
 		}
 
 	}
@@ -474,11 +572,26 @@ func SetTransport(transport http.RoundTripper) ClientOptionFunc {
 
 // SetLogger can be used to specify logrus.Logger to use. Default to logrus.StandardLogger
 func SetLogger(log *logrus.Logger) ClientOptionFunc {
+	return func(c *Client) error {
+		if log != nil {
+			c.log = newLogrusLogger(log)
+		} else {
+			c.log = newLogrusLogger(logrus.StandardLogger())
+		}
+		return nil
+	}
+}
+
+// SetSlogLogger specifies the slog.Logger to use instead of logrus.
+// Records carry attributes such as the method, the redacted URL, the
+// status code, the duration and the node of a request. HTTP requests
+// and responses are dumped at LevelTrace. Passing nil disables logging.
+func SetSlogLogger(log *slog.Logger) ClientOptionFunc {
 	return func(c *Client) error {
 		if log != nil {
 			c.log = log
 		} else {
-			c.log = logrus.StandardLogger()
+			c.log = nopLogger
 		}
 		return nil
 	}
@@ -497,6 +610,20 @@ func SetHttpClient(httpClient Doer) ClientOptionFunc {
 	}
 }
 
+// SetTLSConfig sets up the HTTP client to connect to Opensearch with
+// the given TLS configuration, e.g. one returned by NewTLSConfig.
+// It replaces the HTTP client, so it overrides SetHttpClient and
+// SetTransport when passed after them, and vice versa.
+func SetTLSConfig(cfg *tls.Config) ClientOptionFunc {
+	return func(c *Client) error {
+		if cfg == nil {
+			return nil
+		}
+		c.c = &http.Client{Transport: newTLSTransport(cfg)}
+		return nil
+	}
+}
+
 // SetBasicAuth can be used to specify the HTTP Basic Auth credentials to
 // use when making HTTP requests to Opensearch.
 func SetBasicAuth(username, password string) ClientOptionFunc {
@@ -507,6 +634,16 @@ func SetBasicAuth(username, password string) ClientOptionFunc {
 	}
 }
 
+// SetAuthProvider specifies the AuthProvider that authenticates the
+// requests to Opensearch, e.g. with short-lived bearer tokens that are
+// refreshed when they expire. It takes precedence over SetBasicAuth.
+func SetAuthProvider(provider AuthProvider) ClientOptionFunc {
+	return func(c *Client) error {
+		c.authProvider = provider
+		return nil
+	}
+}
+
 // SetURL defines the URL endpoints of the Opensearch nodes. Notice that
 // when sniffing is enabled, these URLs are used to initially sniff the
 // cluster on startup.
@@ -637,6 +774,22 @@ func SetHealthcheckInterval(interval time.Duration) ClientOptionFunc {
 	}
 }
 
+// SetResurrectTimeout sets the time a node is kept dead after a failure,
+// before a single request probes whether it is back. The timeout starts
+// at initial and doubles with every consecutive failure of the node, up
+// to max. A successful healthcheck ends the timeout early. The defaults
+// are 60 seconds and 30 minutes.
+func SetResurrectTimeout(initial, max time.Duration) ClientOptionFunc {
+	return func(c *Client) error {
+		if initial <= 0 || max < initial {
+			return errors.New("initial resurrect timeout must be positive and must not exceed max")
+		}
+		c.resurrectTimeoutInitial = initial
+		c.resurrectTimeoutMax = max
+		return nil
+	}
+}
+
 // SetMaxRetries sets the maximum number of retries before giving up when
 // performing a HTTP request to Opensearch.
 //
@@ -726,6 +879,50 @@ func SetRetryStatusCodes(statusCodes ...int) ClientOptionFunc {
 	}
 }
 
+// SetSelector specifies the strategy to pick the connection for the next
+// request. RoundRobinSelector is used by default.
+func SetSelector(selector Selector) ClientOptionFunc {
+	return func(c *Client) error {
+		if selector == nil {
+			selector = NewRoundRobinSelector()
+		}
+		c.selector = selector
+		return nil
+	}
+}
+
+// SetMiddleware adds middleware to wrap each request with, e.g. to audit
+// or to authorize requests. Middleware is called in the order passed,
+// with the first middleware being the outermost. Passing SetMiddleware
+// multiple times adds to the list of middleware.
+func SetMiddleware(middleware ...Middleware) ClientOptionFunc {
+	return func(c *Client) error {
+		for _, m := range middleware {
+			if m != nil {
+				c.middleware = append(c.middleware, m)
+			}
+		}
+		return nil
+	}
+}
+
+// SetRetryBudget limits the total time and number of attempts spent on
+// a single request, including all retries. The budget can be overridden
+// per request with WithRetryBudget or PerformRequestOptions.RetryBudget.
+// There is no limit by default.
+func SetRetryBudget(budget RetryBudget) ClientOptionFunc {
+	return func(c *Client) error {
+		if budget.MaxElapsedTime < 0 {
+			return errors.New("MaxElapsedTime must be greater than or equal to 0")
+		}
+		if budget.MaxAttempts < 0 {
+			return errors.New("MaxAttempts must be greater than or equal to 0")
+		}
+		c.retryBudget = budget
+		return nil
+	}
+}
+
 // SetHeaders adds a list of default HTTP headers that will be added to
 // each requests executed by PerformRequest.
 func SetHeaders(headers http.Header) ClientOptionFunc {
@@ -783,7 +980,7 @@ func (c *Client) Start() {
 	c.running = true
 	c.mu.Unlock()
 
-	c.infof("opensearch: client started")
+	c.log.Info("opensearch: client started")
 }
 
 // Stop stops the background processes that the client is running,
@@ -813,45 +1010,25 @@ func (c *Client) Stop() {
 	c.running = false
 	c.mu.Unlock()
 
-	c.infof("opensearch: client stopped")
-}
-
-// errorf logs to the error log.
-func (c *Client) errorf(format string, args ...interface{}) {
-	c.log.Errorf(format, args...)
-}
-
-// infof logs informational messages.
-func (c *Client) infof(format string, args ...interface{}) {
-	c.log.Infof(format, args...)
-}
-
-// debugf logs to the debug log.
-func (c *Client) debugf(format string, args ...interface{}) {
-	c.log.Debugf(format, args...)
-}
-
-// tracef logs to the trace log.
-func (c *Client) tracef(format string, args ...interface{}) {
-	c.log.Tracef(format, args...)
+	c.log.Info("opensearch: client stopped")
 }
 
 // dumpRequest dumps the given HTTP request to the trace log.
-func (c *Client) dumpRequest(r *http.Request) {
-	if c.log.IsLevelEnabled(logrus.TraceLevel) {
+func (c *Client) dumpRequest(ctx context.Context, r *http.Request) {
+	if c.log.Enabled(ctx, LevelTrace) {
 		out, err := httputil.DumpRequestOut(r, true)
 		if err == nil {
-			c.tracef("%s\n", string(out))
+			c.log.Log(ctx, LevelTrace, "opensearch: request", slog.String("request", string(out)))
 		}
 	}
 }
 
 // dumpResponse dumps the given HTTP response to the trace log.
-func (c *Client) dumpResponse(resp *http.Response) {
-	if c.log.IsLevelEnabled(logrus.TraceLevel) {
+func (c *Client) dumpResponse(ctx context.Context, resp *http.Response) {
+	if c.log.Enabled(ctx, LevelTrace) {
 		out, err := httputil.DumpResponse(resp, true)
 		if err == nil {
-			c.tracef("%s\n", string(out))
+			c.log.Log(ctx, LevelTrace, "opensearch: response", slog.String("response", string(out)))
 		}
 	}
 }
@@ -963,10 +1140,16 @@ func (c *Client) sniffNode(ctx context.Context, url string) []*conn {
 	}
 
 	c.mu.RLock()
-	if c.basicAuthUsername != "" || c.basicAuthPassword != "" {
+	authProvider := c.authProvider
+	if authProvider == nil && (c.basicAuthUsername != "" || c.basicAuthPassword != "") {
 		req.SetBasicAuth(c.basicAuthUsername, c.basicAuthPassword)
 	}
 	c.mu.RUnlock()
+	if authProvider != nil {
+		if err := authProvider.Authenticate(ctx, (*http.Request)(req)); err != nil {
+			return nodes
+		}
+	}
 
 	if req.Header.Get("User-Agent") == "" {
 		req.Header.Add("User-Agent", "opensearch/"+Version+" ("+runtime.GOOS+"-"+runtime.GOARCH+")")
@@ -1039,13 +1222,12 @@ func (c *Client) updateConns(conns []*conn) {
 		}
 		if !found {
 			// New connection didn't exist, so add it to our list of new conns.
-			c.infof("opensearch: %s joined the cluster", conn.URL())
+			c.log.Info("opensearch: node joined the cluster", slog.String("node", conn.URL()))
 			newConns = append(newConns, conn)
 		}
 	}
 
 	c.conns = newConns
-	c.cindex = -1
 	c.connsMu.Unlock()
 }
 
@@ -1085,6 +1267,7 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	c.mu.RUnlock()
 
 	c.connsMu.RLock()
@@ -1105,7 +1288,12 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 				errc <- err
 				return
 			}
-			if basicAuth {
+			if authProvider != nil {
+				if err := authProvider.Authenticate(ctx, (*http.Request)(req)); err != nil {
+					errc <- err
+					return
+				}
+			} else if basicAuth {
 				req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
 			}
 			if len(headers) > 0 {
@@ -1131,19 +1319,20 @@ func (c *Client) healthcheck(parentCtx context.Context, timeout time.Duration, f
 		// Wait for the Goroutine (or its timeout)
 		select {
 		case <-ctx.Done(): // timeout
-			c.errorf("opensearch: %s is dead", conn.URL())
+			c.log.Error("opensearch: node is dead", slog.String("node", conn.URL()), slog.Any("error", ctx.Err()))
 			conn.MarkAsDead()
 		case err := <-errc:
 			if err != nil {
-				c.errorf("opensearch: %s is dead", conn.URL())
+				c.log.Error("opensearch: node is dead", slog.String("node", conn.URL()), slog.Any("error", err))
 				conn.MarkAsDead()
 				break
 			}
 			if status >= 200 && status < 300 {
-				conn.MarkAsAlive()
+				// A dead node is probed before it is used again
+				conn.resurrect()
 			} else {
 				conn.MarkAsDead()
-				c.errorf("opensearch: %s is dead [status=%d]", conn.URL(), status)
+				c.log.Error("opensearch: node is dead", slog.String("node", conn.URL()), slog.Int("status", status))
 			}
 		}
 	}
@@ -1158,6 +1347,7 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	c.mu.Unlock()
 
 	// If we don't get a connection after "timeout", we bail.
@@ -1170,7 +1360,12 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 			if err != nil {
 				return err
 			}
-			if basicAuth {
+			if authProvider != nil {
+				if err := authProvider.Authenticate(parentCtx, req); err != nil {
+					lastErr = err
+					continue
+				}
+			} else if basicAuth {
 				req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
 			}
 			if len(headers) > 0 {
@@ -1212,38 +1407,67 @@ func (c *Client) startupHealthcheck(parentCtx context.Context, timeout time.Dura
 }
 
 // next returns the next available connection, or ErrNoClient.
+// The connection is picked from all living connections by the Selector,
+// unless a half-open connection waits for a probe.
 func (c *Client) next() (*conn, error) {
-	// We do round-robin here.
-	// TODO(oe) This should be a pluggable strategy, like the Selector in the official clients.
+	return c.nextUnused(nil)
+}
+
+// nextUnused is like next, but if the request is hedged, it skips the
+// connections used by the other attempt, unless there is no other one.
+func (c *Client) nextUnused(hs *hedgeState) (*conn, error) {
+	c.mu.RLock()
+	selector := c.selector
+	resurrectTimeoutInitial := c.resurrectTimeoutInitial
+	resurrectTimeoutMax := c.resurrectTimeoutMax
+	c.mu.RUnlock()
+
 	c.connsMu.Lock()
 	defer c.connsMu.Unlock()
 
-	i := 0
-	numConns := len(c.conns)
-	for {
-		i++
-		if i > numConns {
-			break // we visited all conns: they all seem to be dead
+	now := time.Now().UTC()
+	alive := make([]Connection, 0, len(c.conns))
+	var unused []Connection
+	for _, conn := range c.conns {
+		conn.resurrectIfDue(now, resurrectTimeoutInitial, resurrectTimeoutMax)
+		if conn.isAlive() {
+			alive = append(alive, conn)
+			if hs != nil && !hs.used(conn) {
+				unused = append(unused, conn)
+			}
 		}
-		c.cindex++
-		if c.cindex >= numConns {
-			c.cindex = 0
+	}
+
+	if len(unused) > 0 && len(unused) < len(alive) {
+		// The other attempt of a hedged request uses a healthy node
+		alive = unused
+	} else {
+		// A single request probes a half-open connection
+		for _, conn := range c.conns {
+			if conn.tryProbe() {
+				c.log.Info("opensearch: probing dead node", slog.String("node", conn.URL()))
+				return conn, nil
+			}
 		}
-		conn := c.conns[c.cindex]
-		if !conn.IsDead() {
-			return conn, nil
+	}
+
+	if len(alive) > 0 {
+		selected, err := selector.Select(alive)
+		if err != nil {
+			return nil, err
+		}
+		conn, ok := selected.(*conn)
+		if !ok {
+			return nil, errors.Wrap(ErrNoClient, "selector returned an unknown connection")
 		}
+		return conn, nil
 	}
 
-	// We have a deadlock here: All nodes are marked as dead.
-	// If sniffing is disabled, connections will never be marked alive again.
-	// So we are marking them as alive--if sniffing is disabled.
-	// They'll then be picked up in the next call to PerformRequest.
+	// All nodes are marked as dead. Even if sniffing is disabled, they
+	// are probed again once their resurrect timeout has passed, see
+	// resurrectIfDue above, so there is no deadlock.
 	if !c.snifferEnabled {
-		c.errorf("opensearch: all %d nodes marked as dead; resurrecting them to prevent deadlock", len(c.conns))
-		for _, conn := range c.conns {
-			conn.MarkAsAlive()
-		}
+		c.log.Error("opensearch: all nodes marked as dead; waiting for their resurrect timeout", slog.Int("nodes", len(c.conns)))
 	}
 
 	// We tried hard, but there is no node available
@@ -1279,6 +1503,9 @@ type PerformRequestOptions struct {
 	Headers          http.Header
 	MaxResponseSize  int64
 	Stream           bool
+	RetryBudget      *RetryBudget
+	Hedge            *HedgePolicy // hedge the request, see HedgePolicy
+	CancelTasks      bool         // cancel the tasks of the request when ctx is done
 }
 
 // PerformRequest does a HTTP request to Opensearch.
@@ -1290,7 +1517,33 @@ type PerformRequestOptions struct {
 //
 // If Stream is set, the returned BodyReader field must be closed, even
 // if PerformRequest returns an error.
+//
+// The request passes through the middleware registered with SetMiddleware.
+// If Hedge is set, the middleware sees a single request, even if it is
+// sent to two nodes.
+//
+// If CancelTasks is set and ctx is done before the response arrives, the
+// tasks that the request has started in the cluster are cancelled in the
+// background.
 func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+	c.mu.RLock()
+	middleware := c.middleware
+	c.mu.RUnlock()
+	perform := c.performRequest
+	if opt.Hedge != nil && !opt.Stream {
+		perform = c.performHedged
+	}
+	if len(middleware) > 0 {
+		perform = chainMiddleware(perform, middleware)
+	}
+	if opt.CancelTasks {
+		return c.performCancellable(ctx, opt, perform)
+	}
+	return perform(ctx, opt)
+}
+
+// performRequest does the HTTP request to Opensearch, see PerformRequest.
+func (c *Client) performRequest(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
 	start := time.Now().UTC()
 
 	c.mu.RLock()
@@ -1298,6 +1551,7 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 	basicAuth := c.basicAuthUsername != "" || c.basicAuthPassword != ""
 	basicAuthUsername := c.basicAuthUsername
 	basicAuthPassword := c.basicAuthPassword
+	authProvider := c.authProvider
 	sendGetBodyAs := c.sendGetBodyAs
 	gzipEnabled := c.gzipEnabled
 	healthcheckEnabled := c.healthcheckEnabled
@@ -1309,7 +1563,15 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 	if opt.RetryStatusCodes != nil {
 		retryStatusCodes = opt.RetryStatusCodes
 	}
+	retryInfo := &RetryInfo{Budget: c.retryBudget, Start: start}
+	if budget, ok := retryBudgetFromContext(ctx); ok {
+		retryInfo.Budget = budget
+	}
+	if opt.RetryBudget != nil {
+		retryInfo.Budget = *opt.RetryBudget
+	}
 	defaultHeaders := c.headers
+	deprecationHandler := c.deprecationHandler
 	c.mu.RUnlock()
 
 	// retry returns true if statusCode indicates the request is to be retried
@@ -1327,7 +1589,8 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 	var req *Request
 	var resp *Response
 	var retried bool
-	var n int
+	var authRefreshed bool
+	hs := hedgeStateFromContext(ctx)
 
 	// Change method if sendGetBodyAs is specified.
 	if opt.Method == "GET" && opt.Body != nil && sendGetBodyAs != "GET" {
@@ -1341,18 +1604,18 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		}
 
 		// Get a connection
-		conn, err = c.next()
+		conn, err = c.nextUnused(hs)
 		if errors.Is(err, ErrNoClient) {
-			n++
 			if !retried {
 				// Force a healtcheck as all connections seem to be dead.
 				c.healthcheck(ctx, timeout, false)
 				if healthcheckEnabled {
+					retryInfo.Attempts++
 					retried = true
 					continue
 				}
 			}
-			wait, ok, rerr := retrier.Retry(ctx, n, nil, nil, err)
+			wait, ok, rerr := c.shouldRetry(ctx, retrier, retryInfo, nil, nil, err)
 			if rerr != nil {
 				return nil, rerr
 			}
@@ -1360,20 +1623,38 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 				return nil, err
 			}
 			retried = true
-			time.Sleep(wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
 			continue // try again
 		}
 		if err != nil {
-			c.errorf("opensearch: cannot get connection from pool")
+			c.log.ErrorContext(ctx, "opensearch: cannot get connection from pool", slog.Any("error", err))
 			return nil, err
 		}
+		if hs != nil {
+			hs.use(conn)
+		}
 
 		req, err = NewRequest(opt.Method, conn.URL()+pathWithParams)
 		if err != nil {
-			c.errorf("opensearch: cannot create request for %s %s: %v", strings.ToUpper(opt.Method), conn.URL()+pathWithParams, err)
+			c.log.ErrorContext(ctx, "opensearch: cannot create request",
+				slog.String("method", strings.ToUpper(opt.Method)),
+				slog.String("node", conn.URL()),
+				slog.String("path", opt.Path),
+				slog.Any("error", err))
 			return nil, err
 		}
-		if basicAuth {
+		if authProvider != nil {
+			if err := authProvider.Authenticate(ctx, (*http.Request)(req)); err != nil {
+				c.log.ErrorContext(ctx, "opensearch: cannot authenticate request",
+					slog.String("method", strings.ToUpper(opt.Method)),
+					slog.String("node", conn.URL()),
+					slog.String("path", opt.Path),
+					slog.Any("error", err))
+				return nil, err
+			}
+		} else if basicAuth {
 			req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
 		}
 		if opt.ContentType != "" {
@@ -1391,6 +1672,7 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 				}
 			}
 		}
+		setContextHeaders(ctx, req.Header, opt.Headers)
 		if req.Header.Get("User-Agent") == "" {
 			req.Header.Set("User-Agent", "opensearch/"+Version+" ("+runtime.GOOS+"-"+runtime.GOARCH+")")
 		}
@@ -1399,49 +1681,97 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		if opt.Body != nil {
 			err = req.SetBody(opt.Body, gzipEnabled)
 			if err != nil {
-				c.errorf("opensearch: couldn't set body %+v for request: %v", opt.Body, err)
+				c.log.ErrorContext(ctx, "opensearch: cannot set request body",
+					slog.String("method", strings.ToUpper(opt.Method)),
+					slog.String("url", req.URL.Redacted()),
+					slog.Any("error", err))
 				return nil, err
 			}
 		}
 
 		// Tracing
-		c.dumpRequest((*http.Request)(req))
+		c.dumpRequest(ctx, (*http.Request)(req))
 
 		// Get response
+		conn.beginRequest()
+		reqStart := time.Now()
 		res, err := c.c.Do((*http.Request)(req).WithContext(ctx))
+		if err == nil {
+			conn.endRequest(time.Since(reqStart), res.StatusCode, retry(res.StatusCode), nil)
+		} else {
+			conn.endRequest(time.Since(reqStart), 0, false, err)
+		}
 		if IsContextErr(err) {
 			// Proceed, but don't mark the node as dead
+			conn.abortProbe()
 			return nil, err
 		}
+		// A probe of a half-open node fails if the node does not answer or
+		// answers with a server error, and the node stays dead for longer
+		probe := conn.isHalfOpen()
+		switch {
+		case err == nil && !retry(res.StatusCode):
+			if probe {
+				c.log.InfoContext(ctx, "opensearch: node is alive again", slog.String("node", conn.URL()))
+			}
+			conn.MarkAsHealthy()
+		case probe:
+			c.log.ErrorContext(ctx, "opensearch: node is still dead", slog.String("node", conn.URL()))
+			conn.MarkAsDead()
+		}
 		if err != nil {
-			n++
-			wait, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
+			wait, ok, rerr := c.shouldRetry(ctx, retrier, retryInfo, (*http.Request)(req), res, err)
 			if rerr != nil {
-				c.errorf("opensearch: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				c.log.ErrorContext(ctx, "opensearch: node is dead", slog.String("node", conn.URL()), slog.Any("error", rerr))
+				if !probe {
+					conn.MarkAsDead()
+				}
 				return nil, rerr
 			}
 			if !ok {
-				c.errorf("opensearch: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				c.log.ErrorContext(ctx, "opensearch: node is dead", slog.String("node", conn.URL()), slog.Any("error", err))
+				if !probe {
+					conn.MarkAsDead()
+				}
 				return nil, err
 			}
 			retried = true
-			time.Sleep(wait)
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
 			continue // try again
 		}
 		if retry(res.StatusCode) {
-			n++
-			wait, ok, rerr := retrier.Retry(ctx, n, (*http.Request)(req), res, err)
+			wait, ok, rerr := c.shouldRetry(ctx, retrier, retryInfo, (*http.Request)(req), res, err)
 			if rerr != nil {
-				c.errorf("opensearch: %s is dead", conn.URL())
-				conn.MarkAsDead()
+				c.log.ErrorContext(ctx, "opensearch: node is dead", slog.String("node", conn.URL()), slog.Any("error", rerr))
+				if !probe {
+					conn.MarkAsDead()
+				}
 				return nil, rerr
 			}
 			if ok {
 				// retry
 				retried = true
-				time.Sleep(wait)
+				res.Body.Close()
+				if err := sleepContext(ctx, wait); err != nil {
+					return nil, err
+				}
+				continue // try again
+			}
+		}
+
+		// Refresh expired credentials, and try again once
+		if res.StatusCode == http.StatusUnauthorized && authProvider != nil && !authRefreshed {
+			authRefreshed = true
+			refreshed, err := authProvider.Refresh(ctx, (*http.Request)(req))
+			if err != nil {
+				res.Body.Close()
+				c.log.ErrorContext(ctx, "opensearch: cannot refresh credentials", slog.String("node", conn.URL()), slog.Any("error", err))
+				return nil, err
+			}
+			if refreshed {
+				res.Body.Close()
 				continue // try again
 			}
 		}
@@ -1451,13 +1781,26 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		}
 
 		// Tracing
-		c.dumpResponse(res)
-
-		// Log deprecation warnings as errors
-		if len(res.Header["Warning"]) > 0 {
-			c.deprecationlog((*http.Request)(req), res)
-			for _, warning := range res.Header["Warning"] {
-				c.errorf("Deprecation warning: %s", warning)
+		c.dumpResponse(ctx, res)
+
+		// Report deprecation warnings to the handler, or log them as errors
+		warnings := parseDeprecationWarnings(res.Header)
+		if len(warnings) > 0 {
+			if deprecationHandler != nil {
+				method := strings.ToUpper(opt.Method)
+				deprecationHandler(ctx, &Deprecation{
+					Operation: operation.FromRequest(method, opt.Path).Name,
+					Method:    method,
+					Path:      opt.Path,
+					Warnings:  warnings,
+				})
+			} else {
+				for _, warning := range warnings {
+					c.log.ErrorContext(ctx, "opensearch: deprecation warning",
+						slog.String("method", strings.ToUpper(opt.Method)),
+						slog.String("url", req.URL.Redacted()),
+						slog.String("warning", warning.Text))
+				}
 			}
 		}
 
@@ -1465,14 +1808,11 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 		if err := checkResponse((*http.Request)(req), res, opt.IgnoreErrors...); err != nil {
 			// No retry if request succeeded
 			// We still try to return a response.
-			resp, _ = c.newResponse(res, opt.MaxResponseSize, opt.Stream)
+			resp, _ = c.newResponse(res, opt.MaxResponseSize, opt.Stream, warnings)
 			return resp, err
 		}
 
-		// We successfully made a request with this connection
-		conn.MarkAsHealthy()
-
-		resp, err = c.newResponse(res, opt.MaxResponseSize, opt.Stream)
+		resp, err = c.newResponse(res, opt.MaxResponseSize, opt.Stream, warnings)
 		if err != nil {
 			return nil, err
 		}
@@ -1481,15 +1821,37 @@ func (c *Client) PerformRequest(ctx context.Context, opt PerformRequestOptions)
 	}
 
 	duration := time.Now().UTC().Sub(start)
-	c.debugf("%s %s [status:%d, request:%.3fs]",
-		strings.ToUpper(opt.Method),
-		req.URL.Redacted(),
-		resp.StatusCode,
-		float64(int64(duration/time.Millisecond))/1000)
+	c.log.DebugContext(ctx, "opensearch: request",
+		slog.String("method", strings.ToUpper(opt.Method)),
+		slog.String("url", req.URL.Redacted()),
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("duration", duration),
+		slog.String("node", conn.URL()))
 
 	return resp, nil
 }
 
+// shouldRetry consults the Retrier whether to retry a failed request.
+// It returns false if the RetryBudget of the request is exhausted, or if
+// waiting for the next attempt would exceed it.
+func (c *Client) shouldRetry(ctx context.Context, retrier Retrier, info *RetryInfo, req *http.Request, res *http.Response, err error) (time.Duration, bool, error) {
+	info.Attempts++
+	if !info.allows(0) {
+		return 0, false, nil
+	}
+	wait, ok, rerr := retrier.Retry(context.WithValue(ctx, retryInfoKey{}, info), info.Attempts, req, res, err)
+	if rerr != nil || !ok {
+		return wait, ok, rerr
+	}
+	if !info.allows(wait) {
+		c.log.DebugContext(ctx, "opensearch: retry budget exhausted",
+			slog.Int("attempts", info.Attempts),
+			slog.Duration("elapsed", info.Elapsed()))
+		return 0, false, nil
+	}
+	return wait, true, nil
+}
+
 // -- Document APIs --
 
 // Index a document.
@@ -1615,11 +1977,33 @@ func (c *Client) Scroll(indices ...string) *ScrollService {
 	return NewScrollService(c).Index(indices...)
 }
 
+// SearchIterator pages through all hits of a query using a Point in Time
+// and search_after. Use this as an alternative to Scroll for deep pagination.
+func (c *Client) SearchIterator(indices ...string) *SearchIterator {
+	return NewSearchIterator(c).Index(indices...)
+}
+
 // ClearScroll can be used to clear search contexts manually.
 func (c *Client) ClearScroll(scrollIds ...string) *ClearScrollService {
 	return NewClearScrollService(c).ScrollId(scrollIds...)
 }
 
+// OpenPointInTime opens a new Point in Time on the given indices.
+func (c *Client) OpenPointInTime(indices ...string) *OpenPointInTimeService {
+	return NewOpenPointInTimeService(c).Index(indices...)
+}
+
+// ClosePointInTime closes one or more Points in Time by their ids.
+// Use ClosePointInTimeService.All to close all Points in Time.
+func (c *Client) ClosePointInTime(ids ...string) *ClosePointInTimeService {
+	return NewClosePointInTimeService(c).Id(ids...)
+}
+
+// ListPointInTime lists all Points in Time of the cluster.
+func (c *Client) ListPointInTime() *ListPointInTimeService {
+	return NewListPointInTimeService(c)
+}
+
 // -- Indices APIs --
 
 // CreateIndex returns a service to create a new index.
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
@@ -295,6 +296,62 @@ func TestClientDialFromConfig(t *testing.T) {
 	}
 }
 
+func TestClientFromConfigOptions(t *testing.T) {
+	cfg, err := config.Parse("http://node1:9200,node2:9200/?sniff=false&healthcheck=false&gzip=true&max_retries=2&backoff_initial=10ms&retry_status_codes=502,503&headers=X-Team:search&token=secret&timeout=3s&sniff_interval=1m&healthcheck_interval=30s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := configToOptions(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewSimpleClient(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"http://node1:9200", "http://node2:9200"}, client.urls; !reflect.DeepEqual(want, have) {
+		t.Fatalf("expected URLs %v, have %v", want, have)
+	}
+	if !client.gzipEnabled {
+		t.Fatal("expected gzip to be enabled")
+	}
+	if want, have := "search", client.headers.Get("X-Team"); want != have {
+		t.Fatalf("expected header %q, have %q", want, have)
+	}
+	if client.authProvider == nil {
+		t.Fatal("expected an AuthProvider for the token")
+	}
+	req, _ := http.NewRequest("GET", "http://node1:9200/", nil)
+	if err := client.authProvider.Authenticate(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "Bearer secret", req.Header.Get("Authorization"); want != have {
+		t.Fatalf("expected header %q, have %q", want, have)
+	}
+	if want, have := []int{502, 503}, client.retryStatusCodes; !reflect.DeepEqual(want, have) {
+		t.Fatalf("expected retry status codes %v, have %v", want, have)
+	}
+	if _, ok := client.retrier.(*BackoffRetrier); !ok {
+		t.Fatalf("expected BackoffRetrier, have %T", client.retrier)
+	}
+	if want, have := 3, client.retryBudget.MaxAttempts; want != have {
+		t.Fatalf("expected MaxAttempts %d, have %d", want, have)
+	}
+	if want, have := time.Minute, client.snifferInterval; want != have {
+		t.Fatalf("expected sniffer interval %v, have %v", want, have)
+	}
+	if want, have := 30*time.Second, client.healthcheckInterval; want != have {
+		t.Fatalf("expected healthcheck interval %v, have %v", want, have)
+	}
+	httpClient, ok := client.c.(*http.Client)
+	if !ok {
+		t.Fatalf("expected *http.Client, have %T", client.c)
+	}
+	if want, have := 3*time.Second, httpClient.Timeout; want != have {
+		t.Fatalf("expected timeout %v, have %v", want, have)
+	}
+}
+
 func TestClientDialContext(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -561,7 +618,7 @@ func TestClientHealthcheckTimeoutLeak(t *testing.T) {
 				url: "http://" + addr + "/",
 			},
 		},
-		log: logrus.StandardLogger(),
+		log: newLogrusLogger(logrus.StandardLogger()),
 	}
 
 	type closer interface {
@@ -1144,6 +1201,7 @@ func TestClientSelectConnAllDead(t *testing.T) {
 	client, err := NewClient(
 		SetSniff(false),
 		SetHealthcheck(false),
+		SetResurrectTimeout(50*time.Millisecond, time.Second),
 		SetURL("http://opensearch.svc:9200", "http://opensearch.svc:9201"))
 	if err != nil {
 		t.Fatal(err)
@@ -1153,24 +1211,27 @@ func TestClientSelectConnAllDead(t *testing.T) {
 	client.conns[0].MarkAsDead()
 	client.conns[1].MarkAsDead()
 
-	// If all connections are dead, next should make them alive again, but
-	// still return an error when it first finds out.
-	c, err := client.next()
-	if !IsConnErr(err) {
-		t.Fatal(err)
-	}
-	if c != nil {
-		t.Fatalf("expected no connection; got: %v", c)
+	// If all connections are dead, next returns an error until their
+	// resurrect timeout has passed.
+	for i := 0; i < 2; i++ {
+		c, err := client.next()
+		if !IsConnErr(err) {
+			t.Fatal(err)
+		}
+		if c != nil {
+			t.Fatalf("expected no connection; got: %v", c)
+		}
 	}
-	// Return a connection
-	c, err = client.next()
+	time.Sleep(60 * time.Millisecond)
+	// Return a connection to probe
+	c, err := client.next()
 	if err != nil {
 		t.Fatalf("expected no error; got: %v", err)
 	}
 	if c == nil {
 		t.Fatalf("expected connection; got: %v", c)
 	}
-	// Return a connection
+	// Return the other connection to probe
 	c, err = client.next()
 	if err != nil {
 		t.Fatalf("expected no error; got: %v", err)
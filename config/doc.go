@@ -4,6 +4,7 @@
 
 /*
 Package config allows parsing a configuration for Opensearch
-from a URL.
+from a URL, from a YAML or JSON file with LoadFile, or from
+environment variables with FromEnv.
 */
 package config
@@ -0,0 +1,85 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of the environment variables read by FromEnv.
+const EnvPrefix = "OPENSEARCH_"
+
+// LoadFile reads the configuration from a YAML or JSON file, e.g.:
+//
+//	urls:
+//	  - https://node1:9200
+//	  - https://node2:9200
+//	username: search
+//	password: secret
+//	max_retries: 3
+//	backoff_initial: 100ms
+//	backoff_max: 5s
+//	healthcheck_interval: 30s
+//	headers:
+//	  X-Team: search
+//	tls_ca_file: /etc/opensearch/ca.pem
+//	tls_min_version: "1.3"
+//
+// Unknown keys are reported as errors.
+func LoadFile(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading opensearch configuration: %v", err)
+	}
+	// JSON is a subset of YAML, so JSON files are decoded as YAML
+	file := struct {
+		Config        `yaml:",inline"`
+		TLSMinVersion string `yaml:"tls_min_version"`
+	}{
+		Config: Config{Shards: 1},
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing opensearch configuration %q: %v", filename, err)
+	}
+	cfg := &file.Config
+	if err := cfg.set("tls_min_version", file.TLSMinVersion); err != nil {
+		return nil, fmt.Errorf("invalid value %q for tls_min_version in %q: %v", file.TLSMinVersion, filename, err)
+	}
+	return cfg, nil
+}
+
+// FromEnv reads the configuration from environment variables.
+// OPENSEARCH_URL is parsed like the URL passed to Parse, and may contain
+// a comma-separated list of hosts. The other settings are read from
+// variables named like the parameters of the URL, in upper case and with
+// the prefix OPENSEARCH_, e.g. OPENSEARCH_USERNAME, OPENSEARCH_PASSWORD,
+// OPENSEARCH_MAX_RETRIES or OPENSEARCH_TLS_CA_FILE. They override the
+// parameters of OPENSEARCH_URL.
+func FromEnv() (*Config, error) {
+	cfg := &Config{Shards: 1}
+	if s := os.Getenv(EnvPrefix + "URL"); s != "" {
+		var err error
+		if cfg, err = Parse(s); err != nil {
+			return nil, err
+		}
+	}
+	for _, key := range settingKeys {
+		name := EnvPrefix + strings.ToUpper(key)
+		value := os.Getenv(name)
+		if err := cfg.set(key, value); err != nil {
+			return nil, fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+		}
+	}
+	return cfg, nil
+}
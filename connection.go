@@ -7,10 +7,39 @@ package opensearch
 import (
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// latencyDecay is the weight of a new sample in the moving average
+// of the request latency of a connection.
+const latencyDecay = 0.3
+
+// Connection is a read-only view of a connection to a node in the
+// cluster. It is passed to a Selector to pick the connection for the
+// next request.
+type Connection interface {
+	// NodeID returns the ID of the node of this connection.
+	NodeID() string
+	// URL returns the URL of this connection.
+	URL() string
+	// IsDead returns true if this connection is marked as dead.
+	IsDead() bool
+	// InFlight returns the number of requests currently in flight.
+	InFlight() int64
+	// Latency returns the moving average of the request latency, or 0
+	// if no request has been completed yet.
+	Latency() time.Duration
+}
+
 // conn represents a single connection to a node in a cluster.
+//
+// A connection acts as a circuit breaker: it is alive (closed), dead
+// (open), or half-open. A dead connection becomes half-open when its
+// resurrect timeout has passed, and a half-open connection is used for a
+// single request, the probe, that must succeed before the connection is
+// alive again. If the probe fails, the connection is dead again, with a
+// longer resurrect timeout.
 type conn struct {
 	sync.RWMutex
 	nodeID    string // node ID
@@ -18,6 +47,19 @@ type conn struct {
 	failures  int
 	dead      bool
 	deadSince *time.Time
+	deadAt    time.Time     // time of the last failure
+	halfOpen  bool          // true if the connection waits for a probe
+	probing   bool          // true if a probe is in flight
+	latency   time.Duration // moving average of the request latency
+	inFlight  atomic.Int64  // number of requests in flight
+
+	successes       int64
+	requestFailures int64 // failed requests, unlike failures which counts consecutive failures
+	deadTransitions int64
+	lastError       error
+	lastErrorAt     time.Time
+	latencies       []time.Duration // ring buffer of recent latencies
+	latencyIndex    int             // next index in latencies
 }
 
 // newConn creates a new connection to the given URL.
@@ -33,7 +75,7 @@ func newConn(nodeID, url string) *conn {
 func (c *conn) String() string {
 	c.RLock()
 	defer c.RUnlock()
-	return fmt.Sprintf("%s [dead=%v,failures=%d,deadSince=%v]", c.url, c.dead, c.failures, c.deadSince)
+	return fmt.Sprintf("%s [dead=%v,halfOpen=%v,failures=%d,deadSince=%v]", c.url, c.dead, c.halfOpen, c.failures, c.deadSince)
 }
 
 // NodeID returns the ID of the node of this connection.
@@ -62,11 +104,17 @@ func (c *conn) IsDead() bool {
 // counter and stores the current time in dead since.
 func (c *conn) MarkAsDead() {
 	c.Lock()
+	if !c.dead {
+		c.deadTransitions++
+	}
 	c.dead = true
+	c.halfOpen = false
+	c.probing = false
+	utcNow := time.Now().UTC()
 	if c.deadSince == nil {
-		utcNow := time.Now().UTC()
 		c.deadSince = &utcNow
 	}
+	c.deadAt = utcNow
 	c.failures += 1
 	c.Unlock()
 }
@@ -76,6 +124,8 @@ func (c *conn) MarkAsDead() {
 func (c *conn) MarkAsAlive() {
 	c.Lock()
 	c.dead = false
+	c.halfOpen = false
+	c.probing = false
 	c.Unlock()
 }
 
@@ -84,7 +134,135 @@ func (c *conn) MarkAsAlive() {
 func (c *conn) MarkAsHealthy() {
 	c.Lock()
 	c.dead = false
+	c.halfOpen = false
+	c.probing = false
 	c.deadSince = nil
 	c.failures = 0
 	c.Unlock()
 }
+
+// isAlive returns true if this connection is neither dead nor half-open,
+// i.e. if the selector may pick it.
+func (c *conn) isAlive() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return !c.dead && !c.halfOpen
+}
+
+// isHalfOpen returns true if this connection waits for a probe, or if
+// a probe is in flight.
+func (c *conn) isHalfOpen() bool {
+	c.RLock()
+	defer c.RUnlock()
+	return c.halfOpen
+}
+
+// resurrect makes a dead connection half-open, so that the next request
+// probes whether the node is back.
+func (c *conn) resurrect() {
+	c.Lock()
+	if c.dead {
+		c.dead = false
+		c.halfOpen = true
+	}
+	c.Unlock()
+}
+
+// resurrectIfDue makes a dead connection half-open if its resurrect
+// timeout has passed. The timeout starts at initial and doubles with
+// every consecutive failure, up to max.
+func (c *conn) resurrectIfDue(now time.Time, initial, max time.Duration) {
+	c.Lock()
+	if c.dead && !now.Before(c.deadAt.Add(resurrectTimeout(c.failures, initial, max))) {
+		c.dead = false
+		c.halfOpen = true
+	}
+	c.Unlock()
+}
+
+// resurrectTimeout returns the time a connection is kept dead after the
+// given number of consecutive failures.
+func resurrectTimeout(failures int, initial, max time.Duration) time.Duration {
+	timeout := initial
+	for i := 1; i < failures && timeout < max; i++ {
+		timeout *= 2
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
+
+// tryProbe returns true if this connection is half-open and no probe is
+// in flight yet. The caller must then use it for the probe.
+func (c *conn) tryProbe() bool {
+	c.Lock()
+	defer c.Unlock()
+	if !c.halfOpen || c.probing {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+// abortProbe ends a probe without result, e.g. because the request has
+// been cancelled, so that the next request probes again.
+func (c *conn) abortProbe() {
+	c.Lock()
+	c.probing = false
+	c.Unlock()
+}
+
+// InFlight returns the number of requests currently in flight.
+func (c *conn) InFlight() int64 {
+	return c.inFlight.Load()
+}
+
+// Latency returns the moving average of the request latency, or 0
+// if no request has been completed yet.
+func (c *conn) Latency() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	return c.latency
+}
+
+// beginRequest records the start of a request on this connection.
+func (c *conn) beginRequest() {
+	c.inFlight.Add(1)
+}
+
+// endRequest records the end of a request on this connection, with the
+// status code of the response, if any. A response with a server error or
+// a status that is retried, see SetRetryStatusCodes, is a failure. The
+// latency is only taken into account if the request was successful, so
+// that a node answering quickly with errors is not preferred.
+// Requests cancelled by the caller are neither successes nor failures.
+func (c *conn) endRequest(latency time.Duration, statusCode int, retried bool, err error) {
+	c.inFlight.Add(-1)
+	if IsContextErr(err) {
+		return
+	}
+	if err == nil && (retried || statusCode >= 500) {
+		err = fmt.Errorf("opensearch: node responded with status %d", statusCode)
+	}
+	c.Lock()
+	defer c.Unlock()
+	if err != nil {
+		c.requestFailures++
+		c.lastError = err
+		c.lastErrorAt = time.Now().UTC()
+		return
+	}
+	c.successes++
+	if c.latency == 0 {
+		c.latency = latency
+	} else {
+		c.latency = time.Duration(latencyDecay*float64(latency) + (1-latencyDecay)*float64(c.latency))
+	}
+	if len(c.latencies) < latencySamples {
+		c.latencies = append(c.latencies, latency)
+	} else {
+		c.latencies[c.latencyIndex] = latency
+	}
+	c.latencyIndex = (c.latencyIndex + 1) % latencySamples
+}
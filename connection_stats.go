@@ -0,0 +1,113 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"sort"
+	"time"
+)
+
+// latencySamples is the number of recent request latencies kept per
+// connection to compute latency percentiles.
+const latencySamples = 256
+
+// ConnectionStats is a snapshot of the statistics of a connection to
+// a node in the cluster, as returned by Client.ConnectionStats.
+type ConnectionStats struct {
+	NodeID string `json:"node_id,omitempty"`
+	URL    string `json:"url"`
+
+	// Dead is true if the connection is marked as dead. HalfOpen is true
+	// if the connection waits for a probe to rejoin the pool.
+	Dead      bool       `json:"dead"`
+	HalfOpen  bool       `json:"half_open"`
+	DeadSince *time.Time `json:"dead_since,omitempty"`
+
+	// InFlight is the number of requests currently in flight.
+	InFlight int64 `json:"in_flight"`
+	// Successes is the number of requests that got a response from the
+	// node without a server error.
+	Successes int64 `json:"successes"`
+	// Failures is the number of requests that failed without a response
+	// from the node, e.g. because the node is unreachable, or with a
+	// server error or a status that is retried, e.g. 503. Requests that
+	// are cancelled by the caller are not counted.
+	Failures int64 `json:"failures"`
+	// DeadTransitions is the number of times the connection has been
+	// marked as dead.
+	DeadTransitions int64 `json:"dead_transitions"`
+	// LastError is the error of the last failed request, and LastErrorAt
+	// the time it failed.
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+
+	// Latency is the moving average of the request latency. LatencyP50,
+	// LatencyP90 and LatencyP99 are percentiles of the latency of the
+	// most recent successful requests. All are 0 if no request has
+	// succeeded yet.
+	Latency    time.Duration `json:"latency"`
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP90 time.Duration `json:"latency_p90"`
+	LatencyP99 time.Duration `json:"latency_p99"`
+}
+
+// ConnectionStats returns the statistics of all connections of the
+// client, e.g. to find out which node is misbehaving.
+func (c *Client) ConnectionStats() []ConnectionStats {
+	c.connsMu.RLock()
+	conns := c.conns
+	c.connsMu.RUnlock()
+
+	stats := make([]ConnectionStats, 0, len(conns))
+	for _, conn := range conns {
+		stats = append(stats, conn.Stats())
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the statistics of this connection.
+func (c *conn) Stats() ConnectionStats {
+	c.RLock()
+	stats := ConnectionStats{
+		NodeID:          c.nodeID,
+		URL:             c.url,
+		Dead:            c.dead,
+		HalfOpen:        c.halfOpen,
+		InFlight:        c.inFlight.Load(),
+		Successes:       c.successes,
+		Failures:        c.requestFailures,
+		DeadTransitions: c.deadTransitions,
+		LastErrorAt:     c.lastErrorAt,
+		Latency:         c.latency,
+	}
+	if c.deadSince != nil {
+		deadSince := *c.deadSince
+		stats.DeadSince = &deadSince
+	}
+	if c.lastError != nil {
+		stats.LastError = c.lastError.Error()
+	}
+	samples := make([]time.Duration, len(c.latencies))
+	copy(samples, c.latencies)
+	c.RUnlock()
+
+	if len(samples) > 0 {
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+		stats.LatencyP50 = percentile(samples, 50)
+		stats.LatencyP90 = percentile(samples, 90)
+		stats.LatencyP99 = percentile(samples, 99)
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of the sorted samples, using
+// the nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,122 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	tests := []struct {
+		P    int
+		Want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{90, 90 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, percentile(samples, tt.P); want != have {
+			t.Errorf("p%d: want %v, have %v", tt.P, want, have)
+		}
+	}
+	if want, have := 5*time.Millisecond, percentile([]time.Duration{5 * time.Millisecond}, 99); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestConnStatsLatencySamples(t *testing.T) {
+	c := newConn("node1", "http://127.0.0.1:9200")
+	for i := 1; i <= latencySamples+10; i++ {
+		c.beginRequest()
+		c.endRequest(time.Duration(i)*time.Millisecond, http.StatusOK, false, nil)
+	}
+	if want, have := latencySamples, len(c.latencies); want != have {
+		t.Fatalf("expected %d latency samples, have %d", want, have)
+	}
+	stats := c.Stats()
+	if want, have := int64(latencySamples+10), stats.Successes; want != have {
+		t.Fatalf("expected %d successes, have %d", want, have)
+	}
+	// The oldest samples have been overwritten
+	if want, have := time.Duration(latencySamples+10)*time.Millisecond, stats.LatencyP99; want < have || have < want-5*time.Millisecond {
+		t.Fatalf("expected p99 latency of about %v, have %v", want, have)
+	}
+	if stats.LatencyP50 <= 10*time.Millisecond {
+		t.Fatalf("expected p50 latency to ignore overwritten samples, have %v", stats.LatencyP50)
+	}
+}
+
+func TestClientConnectionStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	transport := &flappingTransport{host: "localhost:" + u.Port()}
+	flappingURL := "http://" + transport.host
+
+	client, err := NewClient(
+		SetURL(ts.URL, flappingURL),
+		SetHttpClient(&http.Client{Transport: transport}),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetSelector(NewRoundRobinSelector()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		_, _ = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+	}
+
+	stats := client.ConnectionStats()
+	if want, have := 2, len(stats); want != have {
+		t.Fatalf("expected %d connections, have %d", want, have)
+	}
+	healthy, flapping := stats[0], stats[1]
+	if want, have := ts.URL, healthy.URL; want != have {
+		t.Fatalf("expected URL %q, have %q", want, have)
+	}
+	if want, have := int64(3), healthy.Successes; want != have {
+		t.Errorf("expected %d successes, have %d", want, have)
+	}
+	if healthy.Dead || healthy.Failures != 0 || healthy.DeadTransitions != 0 || healthy.LastError != "" {
+		t.Errorf("expected healthy connection, have %+v", healthy)
+	}
+	if healthy.Latency <= 0 || healthy.LatencyP50 <= 0 || healthy.LatencyP99 < healthy.LatencyP50 {
+		t.Errorf("expected latencies, have %+v", healthy)
+	}
+	if want, have := int64(0), healthy.InFlight; want != have {
+		t.Errorf("expected %d requests in flight, have %d", want, have)
+	}
+
+	if !flapping.Dead || flapping.DeadSince == nil {
+		t.Errorf("expected dead connection, have %+v", flapping)
+	}
+	if want, have := int64(1), flapping.Failures; want != have {
+		t.Errorf("expected %d failures, have %d", want, have)
+	}
+	if want, have := int64(1), flapping.DeadTransitions; want != have {
+		t.Errorf("expected %d dead transitions, have %d", want, have)
+	}
+	if flapping.LastError == "" || flapping.LastErrorAt.IsZero() {
+		t.Errorf("expected last error, have %+v", flapping)
+	}
+	if flapping.Latency != 0 || flapping.LatencyP50 != 0 {
+		t.Errorf("expected no latencies, have %+v", flapping)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResurrectTimeout(t *testing.T) {
+	tests := []struct {
+		Failures int
+		Want     time.Duration
+	}{
+		{0, 1 * time.Second},
+		{1, 1 * time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if want, have := tt.Want, resurrectTimeout(tt.Failures, 1*time.Second, 10*time.Second); want != have {
+			t.Errorf("failures=%d: want %v, have %v", tt.Failures, want, have)
+		}
+	}
+}
+
+func TestConnResurrectIfDue(t *testing.T) {
+	c := newConn("node1", "http://127.0.0.1:9200")
+	c.MarkAsDead()
+	c.MarkAsDead()
+	deadAt := c.deadAt
+
+	// Two failures keep the connection dead for 2s
+	c.resurrectIfDue(deadAt.Add(1999*time.Millisecond), 1*time.Second, 10*time.Second)
+	if !c.IsDead() || c.isHalfOpen() {
+		t.Fatalf("expected connection to be dead, have %v", c)
+	}
+	c.resurrectIfDue(deadAt.Add(2*time.Second), 1*time.Second, 10*time.Second)
+	if c.IsDead() || !c.isHalfOpen() || c.isAlive() {
+		t.Fatalf("expected connection to be half-open, have %v", c)
+	}
+
+	// Only one request may probe a half-open connection
+	if !c.tryProbe() {
+		t.Fatal("expected first probe to be allowed")
+	}
+	if c.tryProbe() {
+		t.Fatal("expected second probe to be rejected")
+	}
+	c.abortProbe()
+	if !c.tryProbe() {
+		t.Fatal("expected probe to be allowed after aborting the previous one")
+	}
+
+	// A failed probe makes the connection dead again
+	c.MarkAsDead()
+	if !c.IsDead() || c.isHalfOpen() {
+		t.Fatalf("expected connection to be dead, have %v", c)
+	}
+	if want, have := 3, c.failures; want != have {
+		t.Fatalf("expected %d failures, have %d", want, have)
+	}
+}
+
+// flappingTransport fails all requests to a node while it is unhealthy.
+type flappingTransport struct {
+	host     string
+	healthy  atomic.Bool
+	requests atomic.Int64
+}
+
+func (t *flappingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.URL.Host == t.host {
+		t.requests.Add(1)
+		if !t.healthy.Load() {
+			return nil, errors.New("connection refused")
+		}
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestClientProbesDeadNodeOnce(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	transport := &flappingTransport{host: "localhost:" + u.Port()}
+	flappingURL := "http://" + transport.host
+
+	client, err := NewClient(
+		SetURL(flappingURL, ts.URL),
+		SetHttpClient(&http.Client{Transport: transport}),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetResurrectTimeout(50*time.Millisecond, time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perform := func(n int) {
+		for i := 0; i < n; i++ {
+			_, _ = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		}
+	}
+	flappingConn := client.conns[0]
+
+	// The first failure marks the flapping node as dead
+	perform(2)
+	if !flappingConn.IsDead() {
+		t.Fatalf("expected flapping node to be dead, have %v", flappingConn)
+	}
+	before := transport.requests.Load()
+	perform(10)
+	if want, have := before, transport.requests.Load(); want != have {
+		t.Fatalf("expected no requests to dead node, have %d", have-want)
+	}
+
+	// After the resurrect timeout, a single probe fails and the node stays dead
+	time.Sleep(60 * time.Millisecond)
+	perform(10)
+	if want, have := before+1, transport.requests.Load(); want != have {
+		t.Fatalf("expected %d requests to flapping node, have %d", want, have)
+	}
+	if !flappingConn.IsDead() {
+		t.Fatalf("expected flapping node to be dead, have %v", flappingConn)
+	}
+
+	// The second failure doubles the resurrect timeout
+	time.Sleep(60 * time.Millisecond)
+	perform(10)
+	if want, have := before+1, transport.requests.Load(); want != have {
+		t.Fatalf("expected %d requests to flapping node, have %d", want, have)
+	}
+
+	// A successful probe makes the node alive again
+	transport.healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	perform(10)
+	if !flappingConn.isAlive() {
+		t.Fatalf("expected flapping node to be alive, have %v", flappingConn)
+	}
+	if want, have := int64(0), int64(flappingConn.failures); want != have {
+		t.Fatalf("expected %d failures, have %d", want, have)
+	}
+	if transport.requests.Load() < before+5 {
+		t.Fatalf("expected flapping node to get traffic again, have %d requests", transport.requests.Load()-before)
+	}
+}
+
+func TestClientKeepsAllDeadNodesDeadUntilResurrectTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	transport := &flappingTransport{host: "localhost:" + u.Port()}
+
+	client, err := NewClient(
+		SetURL("http://"+transport.host),
+		SetHttpClient(&http.Client{Transport: transport}),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetResurrectTimeout(50*time.Millisecond, time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perform := func(n int) (err error) {
+		for i := 0; i < n; i++ {
+			_, err = client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		}
+		return err
+	}
+
+	// The only node is dead, and is not resurrected before its timeout
+	perform(1)
+	if want, have := int64(1), transport.requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+	if err := perform(10); !errors.Is(err, ErrNoClient) {
+		t.Fatalf("expected %v, have %v", ErrNoClient, err)
+	}
+	if want, have := int64(1), transport.requests.Load(); want != have {
+		t.Fatalf("expected no requests to dead node, have %d", have-want)
+	}
+
+	// After the resurrect timeout, the node is probed again
+	transport.healthy.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	if err := perform(1); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(2), transport.requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+}
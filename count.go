@@ -46,6 +46,7 @@ type CountService struct {
 	terminateAfter         *int
 	bodyJson               interface{}
 	bodyString             string
+	hedge                  *HedgePolicy
 }
 
 // NewCountService creates a new CountService.
@@ -198,6 +199,13 @@ func (s *CountService) Preference(preference string) *CountService {
 	return s
 }
 
+// Hedge sends the request to a second node if the first one has not
+// answered in time, and uses the first response. See HedgePolicy.
+func (s *CountService) Hedge(policy *HedgePolicy) *CountService {
+	s.hedge = policy
+	return s
+}
+
 // Q in the Lucene query string syntax. You can also use Query to pass
 // a Query struct.
 func (s *CountService) Q(q string) *CountService {
@@ -337,6 +345,9 @@ func (s *CountService) Do(ctx context.Context) (int64, error) {
 	if err := s.Validate(); err != nil {
 		return 0, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ...); err != nil {
+		return 0, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -367,6 +378,7 @@ func (s *CountService) Do(ctx context.Context) (int64, error) {
 		Params:  params,
 		Body:    body,
 		Headers: s.headers,
+		Hedge:   s.hedge,
 	})
 	if err != nil {
 		return 0, err
@@ -255,6 +255,9 @@ func (s *DeleteService) Do(ctx context.Context) (*DeleteResponse, error) {
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
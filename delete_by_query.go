@@ -71,6 +71,7 @@ type DeleteByQueryService struct {
 	version                *bool
 	waitForActiveShards    string
 	waitForCompletion      *bool
+	cancelTasks            bool
 }
 
 // NewDeleteByQueryService creates a new DeleteByQueryService.
@@ -370,6 +371,16 @@ func (s *DeleteByQueryService) Slices(slices interface{}) *DeleteByQueryService
 	return s
 }
 
+// CancelTasks, if enabled, cancels the tasks of the request in the cluster
+// when the context passed to Do is done before the response arrives.
+// The tasks are found by an X-Opaque-Id header unique to the request. An
+// opaque id set with WithOpaqueID is kept as its prefix, so other requests
+// sharing it are not cancelled.
+func (s *DeleteByQueryService) CancelTasks(enabled bool) *DeleteByQueryService {
+	s.cancelTasks = enabled
+	return s
+}
+
 // Sort is a list of <field>:<direction> pairs.
 func (s *DeleteByQueryService) Sort(sort ...string) *DeleteByQueryService {
 	s.sort = append(s.sort, sort...)
@@ -657,6 +668,9 @@ func (s *DeleteByQueryService) Do(ctx context.Context) (*BulkIndexByScrollRespon
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ...); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -680,11 +694,12 @@ func (s *DeleteByQueryService) Do(ctx context.Context) (*BulkIndexByScrollRespon
 
 	// Get response
 	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
-		Method:  "POST",
-		Path:    path,
-		Params:  params,
-		Body:    body,
-		Headers: s.headers,
+		Method:      "POST",
+		Path:        path,
+		Params:      params,
+		Body:        body,
+		Headers:     s.headers,
+		CancelTasks: s.cancelTasks,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,147 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeprecationWarning is a warning returned by Opensearch in the Warning
+// header of a response, e.g. when a request uses a deprecated parameter.
+// The header has the format described in RFC 7234, section 5.5:
+//
+//	299 OpenSearch-2.11.0-4dcad6dd "[types removal] ..." "Mon, 02 Jan 2024 15:04:05 GMT"
+type DeprecationWarning struct {
+	Code  int       // warn code, e.g. 299
+	Agent string    // warn agent, e.g. "OpenSearch-2.11.0-4dcad6dd"
+	Text  string    // warning text, unquoted
+	Date  time.Time // zero if the header has no date
+	Raw   string    // the header value as returned by Opensearch
+}
+
+// Deprecation is passed to the DeprecationHandler for each response that
+// contains deprecation warnings.
+type Deprecation struct {
+	Operation string // API operation, e.g. "search" or "indices.put_mapping"
+	Method    string // HTTP method of the request, e.g. "GET"
+	Path      string // path of the request, e.g. "/index/_search"
+	Warnings  []DeprecationWarning
+}
+
+// DeprecationHandler is called for each response that contains
+// deprecation warnings, e.g. to fail tests before upgrading a cluster.
+// It must be safe for concurrent use.
+type DeprecationHandler func(ctx context.Context, d *Deprecation)
+
+// SetDeprecationHandler sets the handler that is called for responses
+// with deprecation warnings. By default, the warnings are logged with
+// the logger of the client. If a handler is set, they are not logged.
+func SetDeprecationHandler(handler DeprecationHandler) ClientOptionFunc {
+	return func(c *Client) error {
+		c.deprecationHandler = handler
+		return nil
+	}
+}
+
+// parseDeprecationWarnings parses the values of the Warning header.
+func parseDeprecationWarnings(header http.Header) []DeprecationWarning {
+	values := header.Values("Warning")
+	if len(values) == 0 {
+		return nil
+	}
+	warnings := make([]DeprecationWarning, 0, len(values))
+	for _, value := range values {
+		warnings = append(warnings, parseWarningHeader(value)...)
+	}
+	return warnings
+}
+
+// parseWarningHeader parses a value of the Warning header, which may
+// contain several comma-separated warnings. If the value is malformed,
+// it is returned as the text of a single warning.
+func parseWarningHeader(value string) []DeprecationWarning {
+	var warnings []DeprecationWarning
+	rest := strings.TrimSpace(value)
+	for rest != "" {
+		start := rest
+		var w DeprecationWarning
+		var ok bool
+
+		// warn-code
+		var code string
+		if code, rest, ok = strings.Cut(rest, " "); !ok {
+			return []DeprecationWarning{{Text: value, Raw: value}}
+		}
+		if w.Code, ok = parseWarnCode(code); !ok {
+			return []DeprecationWarning{{Text: value, Raw: value}}
+		}
+		// warn-agent
+		if w.Agent, rest, ok = strings.Cut(rest, " "); !ok {
+			return []DeprecationWarning{{Text: value, Raw: value}}
+		}
+		// warn-text
+		if w.Text, rest, ok = cutQuoted(rest); !ok {
+			return []DeprecationWarning{{Text: value, Raw: value}}
+		}
+		// warn-date (optional)
+		if strings.HasPrefix(rest, " \"") {
+			var date string
+			if date, rest, ok = cutQuoted(rest[1:]); !ok {
+				return []DeprecationWarning{{Text: value, Raw: value}}
+			}
+			w.Date, _ = http.ParseTime(date)
+		}
+		w.Raw = strings.TrimSpace(start[:len(start)-len(rest)])
+		warnings = append(warnings, w)
+
+		rest = strings.TrimSpace(rest)
+		if rest != "" {
+			if rest[0] != ',' {
+				return []DeprecationWarning{{Text: value, Raw: value}}
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+	return warnings
+}
+
+// parseWarnCode parses a 3-digit warn-code.
+func parseWarnCode(s string) (int, bool) {
+	if len(s) != 3 {
+		return 0, false
+	}
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
+
+// cutQuoted cuts the quoted string at the beginning of s, and returns it
+// unquoted, along with the rest of s.
+func cutQuoted(s string) (quoted, rest string, ok bool) {
+	if !strings.HasPrefix(s, "\"") {
+		return "", s, false
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
@@ -0,0 +1,129 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseWarningHeader(t *testing.T) {
+	date := time.Date(2024, time.January, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		Value string
+		Want  []DeprecationWarning
+	}{
+		{
+			Value: `299 OpenSearch-2.11.0-4dcad6dd "[types removal] Specifying types is deprecated." "Tue, 02 Jan 2024 15:04:05 GMT"`,
+			Want: []DeprecationWarning{{
+				Code:  299,
+				Agent: "OpenSearch-2.11.0-4dcad6dd",
+				Text:  "[types removal] Specifying types is deprecated.",
+				Date:  date,
+				Raw:   `299 OpenSearch-2.11.0-4dcad6dd "[types removal] Specifying types is deprecated." "Tue, 02 Jan 2024 15:04:05 GMT"`,
+			}},
+		},
+		{
+			Value: `299 OpenSearch-2.11.0 "parameter [\"include_type_name\"] is deprecated"`,
+			Want: []DeprecationWarning{{
+				Code:  299,
+				Agent: "OpenSearch-2.11.0",
+				Text:  `parameter ["include_type_name"] is deprecated`,
+				Raw:   `299 OpenSearch-2.11.0 "parameter [\"include_type_name\"] is deprecated"`,
+			}},
+		},
+		{
+			Value: `299 OpenSearch-2.11.0 "first", 299 OpenSearch-2.11.0 "second" "Tue, 02 Jan 2024 15:04:05 GMT"`,
+			Want: []DeprecationWarning{
+				{Code: 299, Agent: "OpenSearch-2.11.0", Text: "first", Raw: `299 OpenSearch-2.11.0 "first"`},
+				{Code: 299, Agent: "OpenSearch-2.11.0", Text: "second", Date: date, Raw: `299 OpenSearch-2.11.0 "second" "Tue, 02 Jan 2024 15:04:05 GMT"`},
+			},
+		},
+		{
+			Value: `something is deprecated`,
+			Want:  []DeprecationWarning{{Text: "something is deprecated", Raw: "something is deprecated"}},
+		},
+		{
+			Value: `299 OpenSearch-2.11.0 "unterminated`,
+			Want:  []DeprecationWarning{{Text: `299 OpenSearch-2.11.0 "unterminated`, Raw: `299 OpenSearch-2.11.0 "unterminated`}},
+		},
+	}
+	for i, tt := range tests {
+		if want, have := tt.Want, parseWarningHeader(tt.Value); !reflect.DeepEqual(want, have) {
+			t.Errorf("#%d: want %+v, have %+v", i, want, have)
+		}
+	}
+}
+
+func TestDeprecationHandler(t *testing.T) {
+	warning := `299 OpenSearch-2.11.0 "[types removal] Specifying types is deprecated." "Tue, 02 Jan 2024 15:04:05 GMT"`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tweets/_mapping" {
+			w.Header().Add("Warning", warning)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	var (
+		mu           sync.Mutex
+		deprecations []*Deprecation
+	)
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false),
+		SetDeprecationHandler(func(ctx context.Context, d *Deprecation) {
+			mu.Lock()
+			deprecations = append(deprecations, d)
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "get", Path: "/tweets/_mapping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(res.Deprecations); want != have {
+		t.Fatalf("want %d deprecations in response, have %d", want, have)
+	}
+	if want, have := "[types removal] Specifying types is deprecated.", res.Deprecations[0].Text; want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if want, have := []string{warning}, res.DeprecationWarnings; !reflect.DeepEqual(want, have) {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	if _, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want, have := 1, len(deprecations); want != have {
+		t.Fatalf("want %d calls of the handler, have %d", want, have)
+	}
+	d := deprecations[0]
+	if want, have := "indices.get_mapping", d.Operation; want != have {
+		t.Fatalf("want operation %q, have %q", want, have)
+	}
+	if want, have := "GET", d.Method; want != have {
+		t.Fatalf("want method %q, have %q", want, have)
+	}
+	if want, have := "/tweets/_mapping", d.Path; want != have {
+		t.Fatalf("want path %q, have %q", want, have)
+	}
+	if want, have := res.Deprecations, d.Warnings; !reflect.DeepEqual(want, have) {
+		t.Fatalf("want %+v, have %+v", want, have)
+	}
+	if want, have := 299, d.Warnings[0].Code; want != have {
+		t.Fatalf("want code %d, have %d", want, have)
+	}
+}
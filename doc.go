@@ -28,7 +28,9 @@ You can pass many more configuration parameters to NewClient. Review the
 documentation of NewClient for more information.
 
 If no Opensearch server is available, services will fail when creating
-a new request and will return ErrNoClient.
+a new request and will return ErrNoClient. Client.ConnectionStats returns
+the state and statistics of the connection to each node, to find out
+which node is failing and why.
 
 A Client provides services. The services usually come with a variety of
 methods to prepare the query and a Do function to execute it against the
@@ -143,6 +143,14 @@ func IsConnErr(err error) bool {
 	return err == ErrNoClient || errors.Is(err, ErrNoClient)
 }
 
+// IsUnsupported returns true if the given error indicates that a
+// service uses a feature which is not supported by the version of the
+// connected cluster, see UnsupportedError.
+func IsUnsupported(err error) bool {
+	var ue *UnsupportedError
+	return errors.As(err, &ue)
+}
+
 // IsNotFound returns true if the given error indicates that Opensearch
 // returned HTTP status 404. The err parameter can be of type *opensearch.Error,
 // opensearch.Error, *http.Response or int (indicating the HTTP status code).
@@ -205,6 +205,9 @@ func (s *ExistsService) Do(ctx context.Context) (bool, error) {
 	if err := s.Validate(); err != nil {
 		return false, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return false, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -345,6 +345,9 @@ func (s *ExplainService) Do(ctx context.Context) (*ExplainResponse, error) {
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
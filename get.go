@@ -42,6 +42,7 @@ type GetService struct {
 	versionType                   string
 	parent                        string
 	ignoreErrorsOnGeneratedFields *bool
+	hedge                         *HedgePolicy
 }
 
 // NewGetService creates a new GetService.
@@ -130,6 +131,13 @@ func (s *GetService) Preference(preference string) *GetService {
 	return s
 }
 
+// Hedge sends the request to a second node if the first one has not
+// answered in time, and uses the first response. See HedgePolicy.
+func (s *GetService) Hedge(policy *HedgePolicy) *GetService {
+	s.hedge = policy
+	return s
+}
+
 // StoredFields is a list of fields to return in the response.
 func (s *GetService) StoredFields(storedFields ...string) *GetService {
 	s.storedFields = append(s.storedFields, storedFields...)
@@ -269,6 +277,9 @@ func (s *GetService) Do(ctx context.Context) (*GetResult, error) {
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -282,6 +293,7 @@ func (s *GetService) Do(ctx context.Context) (*GetResult, error) {
 		Path:    path,
 		Params:  params,
 		Headers: s.headers,
+		Hedge:   s.hedge,
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,174 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy enables hedged requests for read requests: If the node of
+// the first request has not answered within a delay, the same request is
+// sent to a second healthy node, and the first response wins. The other
+// request is cancelled. Hedging cuts the tail latency e.g. when a node is
+// in a long GC pause, at the expense of some additional load.
+//
+// Hedging is only used for requests that are safe to repeat, like
+// searches and gets, and not for streamed responses.
+type HedgePolicy struct {
+	// Delay is the time to wait for the first node before sending the
+	// request to a second node.
+	Delay time.Duration
+
+	// Percentile, if set, derives the delay from the latencies of the
+	// most recent successful requests of all nodes, e.g. 95 waits for
+	// the 95th percentile. Delay is used as long as no latencies are
+	// known.
+	Percentile int
+}
+
+// hedgeState tracks the connections used by the attempts of a hedged
+// request, so that the second attempt goes to a different node.
+type hedgeState struct {
+	mu    sync.Mutex
+	conns []*conn
+}
+
+type hedgeStateKey struct{}
+
+// hedgeStateFromContext returns the hedgeState of the request, or nil if
+// the request is not hedged.
+func hedgeStateFromContext(ctx context.Context) *hedgeState {
+	hs, _ := ctx.Value(hedgeStateKey{}).(*hedgeState)
+	return hs
+}
+
+// use records that an attempt uses the connection.
+func (hs *hedgeState) use(conn *conn) {
+	hs.mu.Lock()
+	hs.conns = append(hs.conns, conn)
+	hs.mu.Unlock()
+}
+
+// used returns true if an attempt already uses the connection.
+func (hs *hedgeState) used(conn *conn) bool {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for _, c := range hs.conns {
+		if c == conn {
+			return true
+		}
+	}
+	return false
+}
+
+// hedgeResult is the outcome of an attempt of a hedged request.
+type hedgeResult struct {
+	res    *Response
+	err    error
+	hedged bool
+}
+
+// final returns true if the attempt got an answer from Opensearch, i.e.
+// the other attempt cannot do any better.
+func (r hedgeResult) final() bool {
+	return r.err == nil || (r.res != nil && r.res.StatusCode < http.StatusInternalServerError)
+}
+
+// performHedged performs a request with the hedge policy, see HedgePolicy.
+func (c *Client) performHedged(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+	delay := c.hedgeDelay(opt.Hedge)
+	hs := &hedgeState{}
+	ctx = context.WithValue(ctx, hedgeStateKey{}, hs)
+
+	results := make(chan hedgeResult, 2)
+	attempt := func(hedged bool) context.CancelFunc {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			res, err := c.performRequest(attemptCtx, opt)
+			results <- hedgeResult{res: res, err: err, hedged: hedged}
+		}()
+		return cancel
+	}
+	cancelFirst := attempt(false)
+	defer cancelFirst()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var first hedgeResult
+	select {
+	case first = <-results:
+		return first.res, first.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+
+	// Hedging is pointless without another healthy node
+	if !c.hasAliveConnExcept(hs) {
+		first = <-results
+		return first.res, first.err
+	}
+	c.log.DebugContext(ctx, "opensearch: sending hedged request",
+		slog.String("method", opt.Method),
+		slog.String("path", opt.Path),
+		slog.Duration("delay", delay))
+	cancelSecond := attempt(true)
+	defer cancelSecond()
+
+	first = <-results
+	if first.final() {
+		return first.res, first.err
+	}
+	second := <-results
+	if second.final() {
+		return second.res, second.err
+	}
+	// Both attempts failed: report the error of the original request
+	if second.hedged {
+		return first.res, first.err
+	}
+	return second.res, second.err
+}
+
+// hedgeDelay returns the delay after which a hedged request is sent.
+func (c *Client) hedgeDelay(policy *HedgePolicy) time.Duration {
+	if policy.Percentile <= 0 || policy.Percentile > 100 {
+		return policy.Delay
+	}
+	c.connsMu.RLock()
+	conns := c.conns
+	c.connsMu.RUnlock()
+
+	var samples []time.Duration
+	for _, conn := range conns {
+		conn.RLock()
+		samples = append(samples, conn.latencies...)
+		conn.RUnlock()
+	}
+	if len(samples) == 0 {
+		return policy.Delay
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return percentile(samples, policy.Percentile)
+}
+
+// hasAliveConnExcept returns true if there is an alive connection that is
+// not used by any attempt of the hedged request yet.
+func (c *Client) hasAliveConnExcept(hs *hedgeState) bool {
+	c.connsMu.RLock()
+	defer c.connsMu.RUnlock()
+	for _, conn := range c.conns {
+		if conn.isAlive() && !hs.used(conn) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowHandler answers after the delay, and counts the requests that have
+// been cancelled by the client before.
+type slowHandler struct {
+	delay     time.Duration
+	requests  atomic.Int64
+	cancelled atomic.Int64
+}
+
+func (h *slowHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.requests.Add(1)
+	// The server notices a closed connection only after reading the body
+	io.Copy(io.Discard, r.Body)
+	select {
+	case <-time.After(h.delay):
+	case <-r.Context().Done():
+		h.cancelled.Add(1)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"hits":{"total":{"value":1,"relation":"eq"}}}`))
+}
+
+func TestHedgedRequestUsesFirstResponse(t *testing.T) {
+	slow := &slowHandler{delay: 2 * time.Second}
+	fast := &slowHandler{}
+	slowServer := httptest.NewServer(slow)
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(fast)
+	defer fastServer.Close()
+
+	client, err := NewClient(SetURL(slowServer.URL, fastServer.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		start := time.Now()
+		_, err := client.PerformRequest(context.Background(), PerformRequestOptions{
+			Method: "GET",
+			Path:   "/_search",
+			Hedge:  &HedgePolicy{Delay: 20 * time.Millisecond},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Fatalf("expected hedged request to finish early, took %v", took)
+		}
+	}
+
+	// The round-robin selector sent half of the requests to the slow node
+	// first. These have been hedged to the fast node, and then cancelled.
+	if want, have := int64(2), slow.requests.Load(); want != have {
+		t.Fatalf("expected %d requests to slow node, have %d", want, have)
+	}
+	if want, have := int64(4), fast.requests.Load(); want != have {
+		t.Fatalf("expected %d requests to fast node, have %d", want, have)
+	}
+	deadline := time.Now().Add(time.Second)
+	for slow.cancelled.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want, have := int64(2), slow.cancelled.Load(); want != have {
+		t.Fatalf("expected %d cancelled requests on slow node, have %d", want, have)
+	}
+}
+
+func TestHedgedRequestWithSingleNode(t *testing.T) {
+	h := &slowHandler{delay: 100 * time.Millisecond}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/_search",
+		Hedge:  &HedgePolicy{Delay: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), h.requests.Load(); want != have {
+		t.Fatalf("expected %d requests, have %d", want, have)
+	}
+	if want, have := int64(0), h.cancelled.Load(); want != have {
+		t.Fatalf("expected %d cancelled requests, have %d", want, have)
+	}
+}
+
+func TestHedgeDelay(t *testing.T) {
+	client, err := NewClient(SetURL("http://127.0.0.1:9200", "http://127.0.0.1:9201"), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &HedgePolicy{Delay: 50 * time.Millisecond, Percentile: 90}
+
+	// No latencies known yet
+	if want, have := 50*time.Millisecond, client.hedgeDelay(policy); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+
+	for i := 1; i <= 5; i++ {
+		client.conns[0].endRequest(time.Duration(i)*time.Millisecond, http.StatusOK, false, nil)
+		client.conns[1].endRequest(time.Duration(i+5)*time.Millisecond, http.StatusOK, false, nil)
+	}
+	if want, have := 9*time.Millisecond, client.hedgeDelay(policy); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if want, have := 50*time.Millisecond, client.hedgeDelay(&HedgePolicy{Delay: 50 * time.Millisecond}); want != have {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+}
+
+func TestSearchServiceHedge(t *testing.T) {
+	slow := &slowHandler{delay: 2 * time.Second}
+	fast := &slowHandler{}
+	slowServer := httptest.NewServer(slow)
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(fast)
+	defer fastServer.Close()
+
+	client, err := NewClient(SetURL(slowServer.URL, fastServer.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		res, err := client.Search("test").Hedge(&HedgePolicy{Delay: 20 * time.Millisecond}).Do(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(1), res.TotalHits(); want != have {
+			t.Fatalf("want %d hits, have %d", want, have)
+		}
+	}
+	if want, have := int64(2), fast.requests.Load(); want != have {
+		t.Fatalf("expected %d requests to fast node, have %d", want, have)
+	}
+	deadline := time.Now().Add(time.Second)
+	for slow.cancelled.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if want, have := int64(1), slow.cancelled.Load(); want != have {
+		t.Fatalf("expected %d cancelled requests on slow node, have %d", want, have)
+	}
+}
@@ -327,6 +327,9 @@ func (s *IndexService) Do(ctx context.Context) (*IndexResponse, error) {
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	method, path, params, err := s.buildURL()
@@ -130,6 +130,11 @@ func (s *IndicesCreateService) Do(ctx context.Context) (*IndicesCreateResult, er
 	if s.index == "" {
 		return nil, errors.New("missing index name")
 	}
+	if s.includeTypeName != nil {
+		if err := s.client.checkCapability(ctx, CapabilityIncludeTypeName); err != nil {
+			return nil, err
+		}
+	}
 
 	// Build url
 	path, err := uritemplates.Expand("/{index}", map[string]string{
@@ -167,6 +167,9 @@ func (s *IndicesSyncedFlushService) Do(ctx context.Context) (*IndicesSyncedFlush
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkCapability(ctx, CapabilitySyncedFlush); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
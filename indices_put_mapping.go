@@ -227,6 +227,11 @@ func (s *IndicesPutMappingService) Do(ctx context.Context) (*PutMappingResponse,
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if s.includeTypeName != nil {
+		if err := s.client.checkCapability(ctx, CapabilityIncludeTypeName); err != nil {
+			return nil, err
+		}
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
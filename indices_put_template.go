@@ -236,6 +236,11 @@ func (s *IndicesPutTemplateService) Do(ctx context.Context) (*IndicesPutTemplate
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if s.includeTypeName != nil {
+		if err := s.client.checkCapability(ctx, CapabilityIncludeTypeName); err != nil {
+			return nil, err
+		}
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -203,6 +203,9 @@ func (s *IndicesUnfreezeService) Do(ctx context.Context) (*IndicesUnfreezeRespon
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkCapability(ctx, CapabilityFreeze); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
@@ -0,0 +1,296 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+// Package operation names the Opensearch API operation of a request,
+// e.g. for span names or deprecation warnings.
+package operation
+
+import (
+	"strings"
+)
+
+// Operation describes the Opensearch API called by a request.
+type Operation struct {
+	Name  string // e.g. search, bulk or ism.put_policy
+	Index string // index, alias or data stream, if any
+}
+
+// documentOperations are the APIs on a single document, by endpoint.
+var documentOperations = map[string]map[string]string{
+	"_doc": {
+		"GET":    "get",
+		"HEAD":   "exists",
+		"PUT":    "index",
+		"POST":   "index",
+		"DELETE": "delete",
+	},
+	"_source": {
+		"GET":  "get_source",
+		"HEAD": "exists_source",
+	},
+	"_create": {
+		"PUT":  "create",
+		"POST": "create",
+	},
+	"_update": {
+		"POST": "update",
+	},
+}
+
+// indicesOperations are the endpoints of the indices APIs.
+var indicesOperations = map[string]bool{
+	"_alias":              true,
+	"_aliases":            true,
+	"_analyze":            true,
+	"_cache":              true,
+	"_close":              true,
+	"_component_template": true,
+	"_flush":              true,
+	"_forcemerge":         true,
+	"_freeze":             true,
+	"_index_template":     true,
+	"_mapping":            true,
+	"_open":               true,
+	"_refresh":            true,
+	"_rollover":           true,
+	"_segments":           true,
+	"_settings":           true,
+	"_shrink":             true,
+	"_stats":              true,
+	"_template":           true,
+	"_unfreeze":           true,
+}
+
+// pluginActions are resources of plugin APIs that name an action
+// rather than a resource, e.g. POST /_plugins/_ism/add/{index}.
+var pluginActions = map[string]bool{
+	"add":           true,
+	"change_policy": true,
+	"explain":       true,
+	"preview":       true,
+	"remove":        true,
+	"retry":         true,
+	"_explain":      true,
+	"_execute":      true,
+	"_preview":      true,
+	"_search":       true,
+	"_start":        true,
+	"_stop":         true,
+}
+
+// pluginJobs are plugins whose resources, i.e. jobs, are not
+// prefixed with a resource name, e.g. PUT /_plugins/_transform/{id}.
+var pluginJobs = map[string]bool{
+	"rollup":    true,
+	"transform": true,
+}
+
+// methodVerbs names the action on a resource by HTTP method.
+var methodVerbs = map[string]string{
+	"GET":    "get",
+	"HEAD":   "exists",
+	"PUT":    "put",
+	"DELETE": "delete",
+}
+
+// FromRequest determines the API operation of a request from its method
+// and path, e.g. "PUT /_plugins/_ism/policies/hot-warm" is
+// "ism.put_policy".
+func FromRequest(method, path string) Operation {
+	var segments []string
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	if len(segments) == 0 {
+		if method == "HEAD" {
+			return Operation{Name: "ping"}
+		}
+		return Operation{Name: "info"}
+	}
+
+	// The first segment is an index, unless it is an endpoint
+	var op Operation
+	if !strings.HasPrefix(segments[0], "_") {
+		op.Index = segments[0]
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		switch method {
+		case "PUT":
+			op.Name = "indices.create"
+		case "DELETE":
+			op.Name = "indices.delete"
+		case "HEAD":
+			op.Name = "indices.exists"
+		default:
+			op.Name = "indices.get"
+		}
+		return op
+	}
+
+	endpoint := segments[0]
+	rest := segments[1:]
+	switch {
+	case documentOperations[endpoint] != nil:
+		if name, found := documentOperations[endpoint][method]; found {
+			op.Name = name
+		} else {
+			op.Name = strings.TrimPrefix(endpoint, "_")
+		}
+	case endpoint == "_search":
+		op.Name = searchOperation(method, rest)
+	case endpoint == "_plugins" || endpoint == "_opendistro":
+		op.Name = pluginOperation(method, rest)
+	case indicesOperations[endpoint]:
+		op.Name = "indices." + indicesOperation(method, endpoint)
+	case endpoint == "_cat" || endpoint == "_cluster" || endpoint == "_nodes":
+		op.Name = strings.TrimPrefix(endpoint, "_")
+		if len(rest) > 0 && !strings.HasPrefix(rest[0], "_") {
+			op.Name += "." + rest[0]
+		} else if endpoint == "_nodes" {
+			op.Name += ".info"
+		}
+	case endpoint == "_ingest" || endpoint == "_scripts" || endpoint == "_snapshot" || endpoint == "_tasks":
+		op.Name = namespacedOperation(method, endpoint, rest)
+	case strings.HasPrefix(endpoint, "_"):
+		op.Name = strings.TrimPrefix(endpoint, "_")
+	default:
+		op.Name = "unknown"
+	}
+	return op
+}
+
+// searchOperation names the operations below /_search.
+func searchOperation(method string, rest []string) string {
+	if len(rest) == 0 {
+		return "search"
+	}
+	switch rest[0] {
+	case "point_in_time":
+		switch method {
+		case "POST":
+			return "pit.open"
+		case "DELETE":
+			return "pit.close"
+		default:
+			return "pit.list"
+		}
+	case "scroll":
+		if method == "DELETE" {
+			return "clear_scroll"
+		}
+		return "scroll"
+	case "template":
+		return "search_template"
+	}
+	return "search"
+}
+
+// indicesOperation names the operations of the indices APIs,
+// e.g. get_mapping or refresh.
+func indicesOperation(method, endpoint string) string {
+	name := strings.TrimPrefix(endpoint, "_")
+	switch endpoint {
+	case "_alias", "_aliases", "_component_template", "_index_template", "_mapping", "_settings", "_template":
+		return verb(method, "put") + "_" + name
+	case "_cache":
+		return "clear_cache"
+	}
+	return name
+}
+
+// namespacedOperation names operations on resources of an API, e.g.
+// ingest.put_pipeline or snapshot.create.
+func namespacedOperation(method, endpoint string, rest []string) string {
+	ns := strings.TrimPrefix(endpoint, "_")
+	switch endpoint {
+	case "_ingest":
+		if len(rest) > 0 && rest[len(rest)-1] == "_simulate" {
+			return ns + ".simulate_pipeline"
+		}
+		return ns + "." + verb(method, "put") + "_pipeline"
+	case "_scripts":
+		return ns + "." + verb(method, "put") + "_script"
+	case "_snapshot":
+		switch {
+		case len(rest) > 0 && strings.HasPrefix(rest[len(rest)-1], "_"):
+			// e.g. _restore, _status or _verify
+			return ns + "." + strings.TrimPrefix(rest[len(rest)-1], "_")
+		case len(rest) <= 1:
+			return ns + "." + verb(method, "create") + "_repository"
+		case method == "PUT":
+			return ns + ".create"
+		}
+		return ns + "." + verb(method, "create")
+	case "_tasks":
+		switch {
+		case len(rest) > 0 && rest[len(rest)-1] == "_cancel":
+			return ns + ".cancel"
+		case len(rest) > 0:
+			return ns + ".get"
+		}
+		return ns + ".list"
+	}
+	return ns
+}
+
+// pluginOperation names the operations of plugins, e.g.
+// PUT /_plugins/_ism/policies/{id} is ism.put_policy and
+// GET /_plugins/_security/api/roles/{id} is security.get_role.
+func pluginOperation(method string, rest []string) string {
+	if len(rest) == 0 {
+		return "plugins"
+	}
+	ns := strings.TrimPrefix(rest[0], "_")
+	rest = rest[1:]
+	if len(rest) > 0 && rest[0] == "api" {
+		rest = rest[1:]
+	}
+	if pluginJobs[ns] {
+		rest = append([]string{"jobs"}, rest...)
+	}
+	if len(rest) == 0 {
+		return ns
+	}
+
+	resource := rest[0]
+	if pluginActions[resource] {
+		return ns + "." + strings.TrimPrefix(resource, "_")
+	}
+	// An action on the resource, e.g. /monitors/_search or /jobs/{id}/_start
+	for _, s := range rest[1:] {
+		if pluginActions[s] {
+			return ns + "." + strings.TrimPrefix(s, "_") + "_" + singular(resource)
+		}
+	}
+	return ns + "." + verb(method, "create") + "_" + singular(resource)
+}
+
+// verb returns the verb for the HTTP method. POST is named by the
+// given verb, as it means e.g. create or put depending on the API.
+func verb(method, post string) string {
+	if method == "POST" {
+		return post
+	}
+	if v, found := methodVerbs[method]; found {
+		return v
+	}
+	return strings.ToLower(method)
+}
+
+// singular returns the singular of an English noun used in an API
+// resource, e.g. policy for policies or role for roles.
+func singular(s string) string {
+	switch {
+	case strings.HasSuffix(s, "ies"):
+		return strings.TrimSuffix(s, "ies") + "y"
+	case strings.HasSuffix(s, "ss"):
+		return s
+	case strings.HasSuffix(s, "s"):
+		return strings.TrimSuffix(s, "s")
+	}
+	return s
+}
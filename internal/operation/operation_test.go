@@ -0,0 +1,76 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package operation
+
+import "testing"
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		Method string
+		Path   string
+		Name   string
+		Index  string
+	}{
+		{"GET", "/", "info", ""},
+		{"HEAD", "/", "ping", ""},
+		{"PUT", "/tweets", "indices.create", "tweets"},
+		{"DELETE", "/tweets", "indices.delete", "tweets"},
+		{"HEAD", "/tweets", "indices.exists", "tweets"},
+		{"GET", "/tweets/_doc/1", "get", "tweets"},
+		{"PUT", "/tweets/_doc/1", "index", "tweets"},
+		{"POST", "/tweets/_doc", "index", "tweets"},
+		{"DELETE", "/tweets/_doc/1", "delete", "tweets"},
+		{"POST", "/tweets/_update/1", "update", "tweets"},
+		{"POST", "/tweets/_search", "search", "tweets"},
+		{"POST", "/_search", "search", ""},
+		{"POST", "/_search/scroll", "scroll", ""},
+		{"DELETE", "/_search/scroll", "clear_scroll", ""},
+		{"POST", "/tweets/_search/point_in_time", "pit.open", "tweets"},
+		{"DELETE", "/_search/point_in_time", "pit.close", ""},
+		{"POST", "/_bulk", "bulk", ""},
+		{"POST", "/tweets/_bulk", "bulk", "tweets"},
+		{"POST", "/_msearch", "msearch", ""},
+		{"POST", "/tweets/_count", "count", "tweets"},
+		{"POST", "/tweets/_delete_by_query", "delete_by_query", "tweets"},
+		{"POST", "/_reindex", "reindex", ""},
+		{"GET", "/tweets/_mapping", "indices.get_mapping", "tweets"},
+		{"PUT", "/tweets/_mapping", "indices.put_mapping", "tweets"},
+		{"POST", "/tweets/_refresh", "indices.refresh", "tweets"},
+		{"PUT", "/_index_template/logs", "indices.put_index_template", ""},
+		{"GET", "/_cat/indices", "cat.indices", ""},
+		{"GET", "/_cluster/health", "cluster.health", ""},
+		{"GET", "/_nodes/stats", "nodes.stats", ""},
+		{"GET", "/_nodes", "nodes.info", ""},
+		{"PUT", "/_ingest/pipeline/my-pipeline", "ingest.put_pipeline", ""},
+		{"PUT", "/_snapshot/my-repo", "snapshot.put_repository", ""},
+		{"PUT", "/_snapshot/my-repo/snap-1", "snapshot.create", ""},
+		{"POST", "/_snapshot/my-repo/snap-1/_restore", "snapshot.restore", ""},
+		{"GET", "/_tasks", "tasks.list", ""},
+		{"GET", "/_tasks/node:1", "tasks.get", ""},
+		{"POST", "/_tasks/node:1/_cancel", "tasks.cancel", ""},
+		{"PUT", "/_plugins/_ism/policies/hot-warm", "ism.put_policy", ""},
+		{"GET", "/_plugins/_ism/policies/hot-warm", "ism.get_policy", ""},
+		{"DELETE", "/_plugins/_ism/policies/hot-warm", "ism.delete_policy", ""},
+		{"GET", "/_plugins/_ism/explain/tweets", "ism.explain", ""},
+		{"POST", "/_plugins/_alerting/monitors", "alerting.create_monitor", ""},
+		{"POST", "/_plugins/_alerting/monitors/_search", "alerting.search_monitor", ""},
+		{"GET", "/_plugins/_security/api/roles/admin", "security.get_role", ""},
+		{"PUT", "/_plugins/_security/api/internalusers/alice", "security.put_internaluser", ""},
+		{"GET", "/_plugins/_sm/policies/daily/_explain", "sm.explain_policy", ""},
+		{"PUT", "/_plugins/_transform/my-job", "transform.put_job", ""},
+		{"POST", "/_plugins/_transform/my-job/_start", "transform.start_job", ""},
+		{"POST", "/_plugins/_transform/_preview", "transform.preview_job", ""},
+		{"GET", "/tweets/tweet/1", "unknown", "tweets"},
+	}
+	for _, tt := range tests {
+		op := FromRequest(tt.Method, tt.Path)
+		if want, have := tt.Name, op.Name; want != have {
+			t.Errorf("%s %s: expected operation %q; got: %q", tt.Method, tt.Path, want, have)
+		}
+		if want, have := tt.Index, op.Index; want != have {
+			t.Errorf("%s %s: expected index %q; got: %q", tt.Method, tt.Path, want, have)
+		}
+	}
+}
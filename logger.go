@@ -4,7 +4,117 @@
 
 package opensearch
 
+import (
+	"context"
+	"log/slog"
+
+	"github.com/sirupsen/logrus"
+)
+
 // Logger specifies the interface for all log operations.
+//
+// Deprecated: Logger is not used by the client. Use SetSlogLogger to pass
+// a *slog.Logger, or SetLogger to pass a *logrus.Logger, instead.
 type Logger interface {
 	Printf(format string, v ...interface{})
 }
+
+// LevelTrace is the slog level used to dump HTTP requests and responses.
+// It is more verbose than slog.LevelDebug, and disabled by default.
+const LevelTrace = slog.Level(-8)
+
+// nopLogger discards all log records.
+var nopLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler that discards all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// newLogrusLogger returns a slog.Logger that writes to the logrus.Logger.
+func newLogrusLogger(log *logrus.Logger) *slog.Logger {
+	return slog.New(&logrusHandler{log: log})
+}
+
+// logrusHandler is a slog.Handler that writes to a logrus.Logger.
+// Attributes are passed as logrus fields.
+type logrusHandler struct {
+	log    *logrus.Logger
+	fields logrus.Fields
+	group  string
+}
+
+// logrusLevel maps a slog level to a logrus level.
+func logrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	}
+	return logrus.TraceLevel
+}
+
+// Enabled reports whether the logrus.Logger logs at the given level.
+func (h *logrusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.log.IsLevelEnabled(logrusLevel(level))
+}
+
+// Handle writes the record to the logrus.Logger.
+func (h *logrusHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make(logrus.Fields, len(h.fields)+r.NumAttrs())
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		h.addField(fields, h.group, attr)
+		return true
+	})
+	h.log.WithContext(ctx).WithTime(r.Time).WithFields(fields).Log(logrusLevel(r.Level), r.Message)
+	return nil
+}
+
+// WithAttrs returns a handler that adds the attributes to every record.
+func (h *logrusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make(logrus.Fields, len(h.fields)+len(attrs))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+	for _, attr := range attrs {
+		h.addField(fields, h.group, attr)
+	}
+	return &logrusHandler{log: h.log, fields: fields, group: h.group}
+}
+
+// WithGroup returns a handler that prefixes the keys of all attributes
+// with the group name.
+func (h *logrusHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &logrusHandler{log: h.log, fields: h.fields, group: h.group + name + "."}
+}
+
+func (h *logrusHandler) addField(fields logrus.Fields, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			h.addField(fields, prefix, a)
+		}
+		return
+	}
+	fields[prefix+attr.Key] = attr.Value.Any()
+}
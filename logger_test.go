@@ -0,0 +1,139 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetSlogLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetSlogLogger(log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/tweets/_doc/1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var record map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["msg"] == "opensearch: request" {
+			break
+		}
+		record = nil
+	}
+	if record == nil {
+		t.Fatalf("expected request to be logged; got: %s", buf.String())
+	}
+	if want, have := "DEBUG", record["level"]; want != have {
+		t.Errorf("expected level %v; got: %v", want, have)
+	}
+	if want, have := "GET", record["method"]; want != have {
+		t.Errorf("expected method %v; got: %v", want, have)
+	}
+	if want, have := ts.URL+"/tweets/_doc/1", record["url"]; want != have {
+		t.Errorf("expected url %v; got: %v", want, have)
+	}
+	if want, have := float64(http.StatusOK), record["status"]; want != have {
+		t.Errorf("expected status %v; got: %v", want, have)
+	}
+	if want, have := ts.URL, record["node"]; want != have {
+		t.Errorf("expected node %v; got: %v", want, have)
+	}
+	if _, found := record["duration"]; !found {
+		t.Error("expected duration")
+	}
+}
+
+func TestSetSlogLoggerTraceLevel(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"found":true}`))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: LevelTrace}))
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetSlogLogger(log),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/tweets/_doc/1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `msg="opensearch: response"`) || !strings.Contains(buf.String(), `found`) {
+		t.Fatalf("expected response to be dumped; got: %s", buf.String())
+	}
+}
+
+func TestSetLoggerWithLogrus(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.SetLevel(logrus.InfoLevel)
+
+	client, err := NewSimpleClient(SetLogger(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.log.With("processor", "worker").WithGroup("req").Error("opensearch: failed", slog.Int("status", 500))
+	client.log.Debug("opensearch: not logged")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected a single JSON record; got: %s (%v)", buf.String(), err)
+	}
+	if want, have := "error", record["level"]; want != have {
+		t.Errorf("expected level %v; got: %v", want, have)
+	}
+	if want, have := "opensearch: failed", record["msg"]; want != have {
+		t.Errorf("expected msg %v; got: %v", want, have)
+	}
+	if want, have := "worker", record["processor"]; want != have {
+		t.Errorf("expected processor %v; got: %v", want, have)
+	}
+	if want, have := float64(500), record["req.status"]; want != have {
+		t.Errorf("expected req.status %v; got: %v", want, have)
+	}
+}
@@ -34,6 +34,7 @@ type MgetService struct {
 	routing      string
 	storedFields []string
 	items        []*MultiGetItem
+	hedge        *HedgePolicy
 }
 
 // NewMgetService initializes a new Multi GET API request call.
@@ -91,6 +92,13 @@ func (s *MgetService) Preference(preference string) *MgetService {
 	return s
 }
 
+// Hedge sends the request to a second node if the first one has not
+// answered in time, and uses the first response. See HedgePolicy.
+func (s *MgetService) Hedge(policy *HedgePolicy) *MgetService {
+	s.hedge = policy
+	return s
+}
+
 // Refresh the shard containing the document before performing the operation.
 //
 // See https://www.opensearch.co/guide/en/opensearchsearch/reference/7.0/docs-refresh.html
@@ -186,6 +194,7 @@ func (s *MgetService) Do(ctx context.Context) (*MgetResponse, error) {
 		Params:  params,
 		Body:    body,
 		Headers: s.headers,
+		Hedge:   s.hedge,
 	})
 	if err != nil {
 		return nil, err
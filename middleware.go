@@ -0,0 +1,42 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import "context"
+
+// PerformRequestFunc performs a request to Opensearch, like
+// Client.PerformRequest does.
+type PerformRequestFunc func(ctx context.Context, opt PerformRequestOptions) (*Response, error)
+
+// Middleware wraps the execution of all requests of a Client, including
+// retries. It receives the request options, e.g. method, path, params and
+// body, and can inspect or change them before calling next. It can also
+// inspect the response or the error returned by next, or fail the request
+// without calling next at all.
+//
+// Example:
+//
+//	audit := func(next opensearch.PerformRequestFunc) opensearch.PerformRequestFunc {
+//		return func(ctx context.Context, opt opensearch.PerformRequestOptions) (*opensearch.Response, error) {
+//			res, err := next(ctx, opt)
+//			if opt.Method != "GET" && opt.Method != "HEAD" {
+//				log.Printf("%s %s: %v", opt.Method, opt.Path, err)
+//			}
+//			return res, err
+//		}
+//	}
+//	client, err := opensearch.NewClient(opensearch.SetMiddleware(audit))
+//
+// See SetMiddleware.
+type Middleware func(next PerformRequestFunc) PerformRequestFunc
+
+// chainMiddleware wraps f with the middleware, with the first middleware
+// being the outermost.
+func chainMiddleware(f PerformRequestFunc, middleware []Middleware) PerformRequestFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		f = middleware[i](f)
+	}
+	return f
+}
@@ -0,0 +1,118 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientMiddleware(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "value", r.Header.Get("X-Middleware"); want != have {
+			t.Errorf("expected header %q; got: %q", want, have)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"_index":"twitter","_id":"1","found":true}`))
+	}))
+	defer ts.Close()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next PerformRequestFunc) PerformRequestFunc {
+			return func(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+				calls = append(calls, name+":"+opt.Method+" "+opt.Path)
+				res, err := next(ctx, opt)
+				if err == nil {
+					calls = append(calls, name+":"+res.Header.Get("Content-Type"))
+				}
+				return res, err
+			}
+		}
+	}
+	setHeader := func(next PerformRequestFunc) PerformRequestFunc {
+		return func(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+			opt.Headers = opt.Headers.Clone()
+			if opt.Headers == nil {
+				opt.Headers = make(http.Header)
+			}
+			opt.Headers.Set("X-Middleware", "value")
+			return next(ctx, opt)
+		}
+	}
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetMiddleware(trace("outer"), trace("inner")),
+		SetMiddleware(setHeader),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Get().Index("twitter").Id("1").Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Found {
+		t.Fatal("expected document to be found")
+	}
+
+	want := []string{
+		"outer:GET /twitter/_doc/1",
+		"inner:GET /twitter/_doc/1",
+		"inner:application/json",
+		"outer:application/json",
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("expected calls %v; got: %v", want, calls)
+	}
+	for i := range want {
+		if want[i] != calls[i] {
+			t.Fatalf("expected calls %v; got: %v", want, calls)
+		}
+	}
+}
+
+func TestClientMiddlewareShortCircuit(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	errForbidden := errors.New("forbidden")
+	readOnly := func(next PerformRequestFunc) PerformRequestFunc {
+		return func(ctx context.Context, opt PerformRequestOptions) (*Response, error) {
+			if opt.Method != "GET" && opt.Method != "HEAD" {
+				return nil, errForbidden
+			}
+			return next(ctx, opt)
+		}
+	}
+
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetMiddleware(readOnly),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Delete().Index("twitter").Id("1").Do(context.Background())
+	if !errors.Is(err, errForbidden) {
+		t.Fatalf("expected %v; got: %v", errForbidden, err)
+	}
+	if want, have := 0, requests; want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
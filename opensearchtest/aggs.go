@@ -0,0 +1,410 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// aggregate computes the aggregations over the hits. It supports the
+// terms and date_histogram bucket aggregations, with sub-aggregations,
+// and the min, max, sum, avg and value_count metrics aggregations.
+func aggregate(hits []*hit, aggs map[string]interface{}) (map[string]interface{}, *apiError) {
+	res := make(map[string]interface{}, len(aggs))
+	for name, v := range aggs {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, badRequest("parsing_exception", "Expected [START_OBJECT] under aggregation [%s]", name)
+		}
+		subAggs, _ := def["aggs"].(map[string]interface{})
+		if subAggs == nil {
+			subAggs, _ = def["aggregations"].(map[string]interface{})
+		}
+		var (
+			result map[string]interface{}
+			found  bool
+		)
+		for typ, v := range def {
+			if typ == "aggs" || typ == "aggregations" || typ == "meta" {
+				continue
+			}
+			if found {
+				return nil, badRequest("parsing_exception", "Found two aggregation type definitions in [%s]", name)
+			}
+			found = true
+			params, _ := v.(map[string]interface{})
+			var apiErr *apiError
+			switch typ {
+			case "terms":
+				result, apiErr = termsAggregation(hits, params, subAggs)
+			case "date_histogram":
+				result, apiErr = dateHistogramAggregation(hits, params, subAggs)
+			case "min", "max", "sum", "avg", "value_count":
+				result, apiErr = metricAggregation(hits, typ, params)
+			default:
+				apiErr = badRequest("parsing_exception", "Unknown aggregation type [%s]", typ)
+			}
+			if apiErr != nil {
+				return nil, apiErr
+			}
+		}
+		if !found {
+			return nil, badRequest("parsing_exception", "Missing definition for aggregation [%s]", name)
+		}
+		res[name] = result
+	}
+	return res, nil
+}
+
+// aggregationValues returns the values of the field of an aggregation in
+// the document of a hit. Aggregations on text fields are not allowed.
+func aggregationValues(h *hit, name string) ([]interface{}, *apiError) {
+	f, values := h.ix.values(h.doc, name)
+	if f.Type == "text" {
+		return nil, fielddataDisabled(name)
+	}
+	return values, nil
+}
+
+// bucket is a bucket of a bucket aggregation.
+type bucket struct {
+	key  interface{}
+	hits []*hit
+}
+
+// json returns the bucket in the format of the response, with the
+// sub-aggregations computed over the hits of the bucket.
+func (b *bucket) json(subAggs map[string]interface{}) (map[string]interface{}, *apiError) {
+	res := map[string]interface{}{"doc_count": len(b.hits)}
+	switch key := b.key.(type) {
+	case time.Time:
+		res["key"] = key.UnixMilli()
+		res["key_as_string"] = formatDate(key)
+	case bool:
+		res["key"] = 0
+		if key {
+			res["key"] = 1
+		}
+		res["key_as_string"] = strconv.FormatBool(key)
+	default:
+		res["key"] = key
+	}
+	if len(subAggs) > 0 {
+		aggs, apiErr := aggregate(b.hits, subAggs)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		for name, agg := range aggs {
+			res[name] = agg
+		}
+	}
+	return res, nil
+}
+
+// termsAggregation returns a bucket for each distinct value of the field,
+// ordered by doc_count (descending) by default.
+func termsAggregation(hits []*hit, params, subAggs map[string]interface{}) (map[string]interface{}, *apiError) {
+	name, _ := params["field"].(string)
+	if name == "" {
+		return nil, badRequest("illegal_argument_exception", "Required one of fields [field, script], but none were specified.")
+	}
+	size := intParam(params["size"], "", 10)
+	minDocCount := intParam(params["min_doc_count"], "", 1)
+
+	groups := make(map[string]*bucket)
+	var buckets []*bucket
+	for _, h := range hits {
+		values, apiErr := aggregationValues(h, name)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		seen := make(map[string]bool)
+		for _, v := range values {
+			k := fmt.Sprintf("%T:%v", v, v)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			b, found := groups[k]
+			if !found {
+				b = &bucket{key: v}
+				groups[k] = b
+				buckets = append(buckets, b)
+			}
+			b.hits = append(b.hits, h)
+		}
+	}
+
+	byKey := func(i, j int) int { return compareValues(sortKey(buckets[i].key), sortKey(buckets[j].key)) }
+	byCount := func(i, j int) int { return len(buckets[i].hits) - len(buckets[j].hits) }
+	less := func(i, j int) bool {
+		if c := byCount(i, j); c != 0 {
+			return c > 0
+		}
+		return byKey(i, j) < 0
+	}
+	if order, ok := params["order"].(map[string]interface{}); ok {
+		switch {
+		case order["_key"] == "asc":
+			less = func(i, j int) bool { return byKey(i, j) < 0 }
+		case order["_key"] == "desc":
+			less = func(i, j int) bool { return byKey(i, j) > 0 }
+		case order["_count"] == "asc":
+			less = func(i, j int) bool {
+				if c := byCount(i, j); c != 0 {
+					return c < 0
+				}
+				return byKey(i, j) < 0
+			}
+		}
+	}
+	sort.SliceStable(buckets, less)
+
+	var (
+		results    []interface{}
+		otherCount int
+	)
+	for _, b := range buckets {
+		if len(b.hits) < minDocCount {
+			continue
+		}
+		if len(results) >= size {
+			otherCount += len(b.hits)
+			continue
+		}
+		res, apiErr := b.json(subAggs)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		results = append(results, res)
+	}
+	if results == nil {
+		results = []interface{}{}
+	}
+	return map[string]interface{}{
+		"doc_count_error_upper_bound": 0,
+		"sum_other_doc_count":         otherCount,
+		"buckets":                     results,
+	}, nil
+}
+
+// interval is the interval of a date_histogram aggregation: either a
+// calendar unit like 'M' for months, or a fixed duration.
+type interval struct {
+	unit  byte
+	fixed time.Duration
+}
+
+// calendarUnits are the supported calendar intervals.
+var calendarUnits = map[string]byte{
+	"minute": 'm', "1m": 'm',
+	"hour": 'h', "1h": 'h',
+	"day": 'd', "1d": 'd',
+	"week": 'w', "1w": 'w',
+	"month": 'M', "1M": 'M',
+	"quarter": 'q', "1q": 'q',
+	"year": 'y', "1y": 'y',
+}
+
+// parseFixedInterval parses a fixed interval like "30m" or "12h".
+func parseFixedInterval(s string) (time.Duration, bool) {
+	units := map[string]time.Duration{
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+	}
+	for _, suffix := range []string{"ms", "s", "m", "h", "d"} {
+		if len(s) > len(suffix) && s[len(s)-len(suffix):] == suffix {
+			n, err := strconv.Atoi(s[:len(s)-len(suffix)])
+			if err != nil || n <= 0 {
+				return 0, false
+			}
+			return time.Duration(n) * units[suffix], true
+		}
+	}
+	return 0, false
+}
+
+// floor returns the start of the bucket that t falls into.
+func (i interval) floor(t time.Time) time.Time {
+	switch {
+	case i.fixed > 0:
+		ms := t.UnixMilli()
+		size := i.fixed.Milliseconds()
+		start := ms - ms%size
+		if ms < 0 && ms%size != 0 {
+			start -= size
+		}
+		return time.UnixMilli(start).UTC()
+	case i.unit == 'q':
+		month := floorUnit(t, 'M')
+		return month.AddDate(0, -int(month.Month()-1)%3, 0)
+	}
+	return floorUnit(t, i.unit)
+}
+
+// next returns the start of the bucket following the bucket at t.
+func (i interval) next(t time.Time) time.Time {
+	switch {
+	case i.fixed > 0:
+		return t.Add(i.fixed)
+	case i.unit == 'q':
+		return t.AddDate(0, 3, 0)
+	}
+	return addUnit(t, i.unit, 1)
+}
+
+// dateHistogramAggregation returns a bucket for each interval between
+// the earliest and the latest date of the field, in UTC.
+func dateHistogramAggregation(hits []*hit, params, subAggs map[string]interface{}) (map[string]interface{}, *apiError) {
+	name, _ := params["field"].(string)
+	if name == "" {
+		return nil, badRequest("illegal_argument_exception", "Required one of fields [field, script], but none were specified.")
+	}
+	var (
+		ival interval
+		ok   bool
+	)
+	switch {
+	case params["calendar_interval"] != nil:
+		ival.unit, ok = calendarUnits[fmt.Sprint(params["calendar_interval"])]
+	case params["fixed_interval"] != nil:
+		ival.fixed, ok = parseFixedInterval(fmt.Sprint(params["fixed_interval"]))
+	case params["interval"] != nil:
+		s := fmt.Sprint(params["interval"])
+		if ival.unit, ok = calendarUnits[s]; !ok {
+			ival.fixed, ok = parseFixedInterval(s)
+		}
+	default:
+		return nil, badRequest("illegal_argument_exception", "Invalid interval specified, must be non-null and non-empty")
+	}
+	if !ok {
+		return nil, badRequest("illegal_argument_exception", "Unsupported interval for date_histogram on field [%s]", name)
+	}
+	minDocCount := intParam(params["min_doc_count"], "", 0)
+
+	groups := make(map[int64]*bucket)
+	var first, last time.Time
+	for _, h := range hits {
+		values, apiErr := aggregationValues(h, name)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		seen := make(map[int64]bool)
+		for _, v := range values {
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, badRequest("illegal_argument_exception", "Field [%s] is not a date field, as required by [date_histogram]", name)
+			}
+			key := ival.floor(t)
+			ms := key.UnixMilli()
+			if seen[ms] {
+				continue
+			}
+			seen[ms] = true
+			b, found := groups[ms]
+			if !found {
+				b = &bucket{key: key}
+				groups[ms] = b
+			}
+			b.hits = append(b.hits, h)
+			if first.IsZero() || key.Before(first) {
+				first = key
+			}
+			if last.IsZero() || key.After(last) {
+				last = key
+			}
+		}
+	}
+
+	results := []interface{}{}
+	if len(groups) > 0 {
+		for key := first; !key.After(last); key = ival.next(key) {
+			b, found := groups[key.UnixMilli()]
+			if !found {
+				b = &bucket{key: key}
+			}
+			if len(b.hits) < minDocCount {
+				continue
+			}
+			res, apiErr := b.json(subAggs)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			results = append(results, res)
+		}
+	}
+	return map[string]interface{}{"buckets": results}, nil
+}
+
+// metricAggregation computes a single-value metrics aggregation over the
+// numeric or date values of the field.
+func metricAggregation(hits []*hit, typ string, params map[string]interface{}) (map[string]interface{}, *apiError) {
+	name, _ := params["field"].(string)
+	if name == "" {
+		return nil, badRequest("illegal_argument_exception", "Required one of fields [field, script], but none were specified.")
+	}
+	var (
+		values []float64
+		isDate bool
+	)
+	for _, h := range hits {
+		vals, apiErr := aggregationValues(h, name)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		for _, v := range vals {
+			if typ == "value_count" {
+				values = append(values, 0)
+				continue
+			}
+			switch v := v.(type) {
+			case float64:
+				values = append(values, v)
+			case time.Time:
+				isDate = true
+				values = append(values, float64(v.UnixMilli()))
+			default:
+				return nil, badRequest("illegal_argument_exception", "Field [%s] of type [keyword] is not supported for aggregation [%s]", name, typ)
+			}
+		}
+	}
+
+	var value interface{}
+	switch typ {
+	case "value_count":
+		value = len(values)
+	case "sum":
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		value = sum
+	case "avg":
+		if len(values) > 0 {
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			value = sum / float64(len(values))
+		}
+	case "min", "max":
+		for _, v := range values {
+			if value == nil || (typ == "min" && v < value.(float64)) || (typ == "max" && v > value.(float64)) {
+				value = v
+			}
+		}
+	}
+	res := map[string]interface{}{"value": value}
+	if f, ok := value.(float64); ok && isDate && typ != "sum" {
+		res["value_as_string"] = formatDate(time.UnixMilli(int64(f)))
+	}
+	return res, nil
+}
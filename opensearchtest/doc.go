@@ -0,0 +1,39 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+/*
+Package opensearchtest provides an in-memory fake of an Opensearch
+cluster for unit tests, so that code using the client can be tested
+without running Opensearch, e.g. in Docker.
+
+	func TestRepository(t *testing.T) {
+		server := opensearchtest.NewServer()
+		defer server.Close()
+
+		client, err := server.Client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Index, search etc. with the client
+	}
+
+The fake implements a realistic subset of the APIs: creating, getting
+and deleting indices with explicit or dynamic mappings; indexing,
+getting, updating and deleting documents; bulk requests; and searches
+and counts with the match_all, ids, exists, term, terms, match, range
+and bool queries, sorting, paging, source filtering, and the terms,
+date_histogram, min, max, sum, avg and value_count aggregations. It also
+answers the Info, Cluster Health and Nodes Info APIs, so the client can
+sniff and run healthchecks as with a real cluster.
+
+The fake is not a search engine: documents are searchable immediately,
+all hits have a score of 1 and are returned in the order they have been
+created unless sorted, and text is analyzed by splitting it into
+lowercase words. Use a real cluster to test relevance and analyzers.
+Requests to other APIs fail with status 400.
+
+To test against responses recorded from a real cluster instead, see
+package replay.
+*/
+package opensearchtest
@@ -0,0 +1,493 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resolveIndices returns the indices for a comma-separated list of index
+// names, which may contain wildcards. Index names without wildcards must
+// exist.
+func (s *Server) resolveIndices(expr string) ([]*index, *apiError) {
+	seen := make(map[string]bool)
+	var indices []*index
+	add := func(ix *index) {
+		if !seen[ix.name] {
+			seen[ix.name] = true
+			indices = append(indices, ix)
+		}
+	}
+	for _, name := range strings.Split(expr, ",") {
+		switch {
+		case name == "_all" || name == "*":
+			for _, ix := range s.indices {
+				add(ix)
+			}
+		case strings.Contains(name, "*"):
+			for _, ix := range s.indices {
+				if ok, _ := path.Match(name, ix.name); ok {
+					add(ix)
+				}
+			}
+		default:
+			ix, found := s.indices[name]
+			if !found {
+				return nil, indexNotFound(name)
+			}
+			add(ix)
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i].name < indices[j].name })
+	return indices, nil
+}
+
+// newIndex creates a new, empty index.
+func (s *Server) newIndex(name string) (*index, *apiError) {
+	if name == "" || name != strings.ToLower(name) || strings.ContainsAny(name, `\/*?"<>| ,#:`) || strings.IndexAny(name, "_-+") == 0 {
+		return nil, &apiError{
+			Status: http.StatusBadRequest,
+			Type:   "invalid_index_name_exception",
+			Reason: fmt.Sprintf("Invalid index name [%s]", name),
+			Index:  name,
+		}
+	}
+	ix := &index{
+		name:   name,
+		fields: make(map[string]field),
+		docs:   make(map[string]*document),
+	}
+	s.indices[name] = ix
+	return ix, nil
+}
+
+// getOrCreateIndex returns the index with the given name, creating it
+// if it does not exist yet.
+func (s *Server) getOrCreateIndex(name string) (*index, *apiError) {
+	if ix, found := s.indices[name]; found {
+		return ix, nil
+	}
+	return s.newIndex(name)
+}
+
+func (s *Server) createIndex(r *request, name string) (int, interface{}, *apiError) {
+	if _, found := s.indices[name]; found {
+		return 0, nil, &apiError{
+			Status: http.StatusBadRequest,
+			Type:   "resource_already_exists_exception",
+			Reason: fmt.Sprintf("index [%s] already exists", name),
+			Index:  name,
+		}
+	}
+	body, apiErr := r.decodeBody()
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	ix, apiErr := s.newIndex(name)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if mappings, ok := body["mappings"].(map[string]interface{}); ok {
+		if properties, ok := mappings["properties"].(map[string]interface{}); ok {
+			ix.addMapping(properties, "")
+		}
+	}
+	return http.StatusOK, map[string]interface{}{
+		"acknowledged":        true,
+		"shards_acknowledged": true,
+		"index":               name,
+	}, nil
+}
+
+func (s *Server) deleteIndex(expr string) (int, interface{}, *apiError) {
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	for _, ix := range indices {
+		delete(s.indices, ix.name)
+	}
+	return http.StatusOK, map[string]interface{}{"acknowledged": true}, nil
+}
+
+func (s *Server) indexExists(expr string) (int, interface{}, *apiError) {
+	if _, apiErr := s.resolveIndices(expr); apiErr != nil {
+		return http.StatusNotFound, nil, nil
+	}
+	return http.StatusOK, nil, nil
+}
+
+func (s *Server) getIndex(expr string) (int, interface{}, *apiError) {
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	res := make(map[string]interface{})
+	for _, ix := range indices {
+		res[ix.name] = map[string]interface{}{
+			"aliases":  map[string]interface{}{},
+			"mappings": ix.mapping(),
+			"settings": map[string]interface{}{
+				"index": map[string]interface{}{
+					"number_of_shards":   "1",
+					"number_of_replicas": "1",
+					"provided_name":      ix.name,
+				},
+			},
+		}
+	}
+	return http.StatusOK, res, nil
+}
+
+func (s *Server) getMapping(expr string) (int, interface{}, *apiError) {
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	res := make(map[string]interface{})
+	for _, ix := range indices {
+		res[ix.name] = map[string]interface{}{"mappings": ix.mapping()}
+	}
+	return http.StatusOK, res, nil
+}
+
+// refresh is a no-op, as documents are searchable immediately.
+func (s *Server) refresh(expr string) (int, interface{}, *apiError) {
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]interface{}{"_shards": shards(len(indices))}, nil
+}
+
+// shards returns the "_shards" header of responses.
+func shards(n int) map[string]interface{} {
+	return map[string]interface{}{"total": n, "successful": n, "skipped": 0, "failed": 0}
+}
+
+// newId returns a random document id.
+func newId() string {
+	b := make([]byte, 15)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// parseSeqNo parses the if_seq_no parameter, which is nil if v is nil
+// or empty.
+func parseSeqNo(v interface{}) (*int64, *apiError) {
+	if v == nil || v == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	if err != nil {
+		return nil, badRequest("illegal_argument_exception", "invalid if_seq_no [%v]", v)
+	}
+	return &n, nil
+}
+
+// checkSeqNo implements optimistic concurrency control with if_seq_no.
+func checkSeqNo(ix *index, id string, doc *document, ifSeqNo *int64) *apiError {
+	if ifSeqNo == nil {
+		return nil
+	}
+	conflict := &apiError{Status: http.StatusConflict, Type: "version_conflict_engine_exception", Index: ix.name}
+	switch {
+	case doc == nil:
+		conflict.Reason = fmt.Sprintf("[%s]: version conflict, required seqNo [%d], primary term [1]. but no document was found", id, *ifSeqNo)
+		return conflict
+	case doc.seqNo != *ifSeqNo:
+		conflict.Reason = fmt.Sprintf("[%s]: version conflict, required seqNo [%d], primary term [1]. current document has seqNo [%d] and primary term [1]", id, *ifSeqNo, doc.seqNo)
+		return conflict
+	}
+	return nil
+}
+
+// writeResult returns the response to a write operation on a document.
+func (ix *index) writeResult(doc *document, result string) map[string]interface{} {
+	return map[string]interface{}{
+		"_index":        ix.name,
+		"_id":           doc.id,
+		"_version":      doc.version,
+		"result":        result,
+		"_shards":       map[string]interface{}{"total": 2, "successful": 1, "failed": 0},
+		"_seq_no":       doc.seqNo,
+		"_primary_term": 1,
+	}
+}
+
+// indexDocument creates or replaces a document. If create is true, the
+// document must not exist yet. A new id is generated if id is empty.
+func (s *Server) indexDocument(name, id string, source map[string]interface{}, create bool, ifSeqNo *int64) (int, map[string]interface{}, *apiError) {
+	ix, apiErr := s.getOrCreateIndex(name)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if id == "" {
+		id = newId()
+	}
+	doc, exists := ix.docs[id]
+	if exists && create {
+		return 0, nil, &apiError{
+			Status: http.StatusConflict,
+			Type:   "version_conflict_engine_exception",
+			Reason: fmt.Sprintf("[%s]: version conflict, document already exists (current version [%d])", id, doc.version),
+			Index:  name,
+		}
+	}
+	if apiErr := checkSeqNo(ix, id, doc, ifSeqNo); apiErr != nil {
+		return 0, nil, apiErr
+	}
+	ix.addDynamicMapping(source, "")
+	if !exists {
+		s.order++
+		doc = &document{id: id, order: s.order}
+		ix.docs[id] = doc
+	}
+	doc.version++
+	doc.seqNo = ix.seqNo
+	doc.source = source
+	ix.seqNo++
+	if exists {
+		return http.StatusOK, ix.writeResult(doc, "updated"), nil
+	}
+	return http.StatusCreated, ix.writeResult(doc, "created"), nil
+}
+
+// deleteDocument deletes a document.
+func (s *Server) deleteDocument(name, id string, ifSeqNo *int64) (int, map[string]interface{}, *apiError) {
+	ix, found := s.indices[name]
+	if !found {
+		return 0, nil, indexNotFound(name)
+	}
+	doc, exists := ix.docs[id]
+	if apiErr := checkSeqNo(ix, id, doc, ifSeqNo); apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if !exists {
+		res := ix.writeResult(&document{id: id, version: 1, seqNo: ix.seqNo}, "not_found")
+		ix.seqNo++
+		return http.StatusNotFound, res, nil
+	}
+	delete(ix.docs, id)
+	doc.version++
+	doc.seqNo = ix.seqNo
+	ix.seqNo++
+	return http.StatusOK, ix.writeResult(doc, "deleted"), nil
+}
+
+// updateDocument applies a partial update to a document, with support
+// for "doc", "doc_as_upsert" and "upsert". Scripts are not supported.
+func (s *Server) updateDocument(name, id string, body map[string]interface{}, ifSeqNo *int64) (int, map[string]interface{}, *apiError) {
+	if _, found := body["script"]; found {
+		return 0, nil, badRequest("illegal_argument_exception", "scripted updates are not supported by opensearchtest")
+	}
+	partial, _ := body["doc"].(map[string]interface{})
+	docAsUpsert, _ := body["doc_as_upsert"].(bool)
+	upsert, _ := body["upsert"].(map[string]interface{})
+
+	var doc *document
+	if ix, found := s.indices[name]; found {
+		doc = ix.docs[id]
+		if apiErr := checkSeqNo(ix, id, doc, ifSeqNo); apiErr != nil {
+			return 0, nil, apiErr
+		}
+	}
+	if doc == nil {
+		switch {
+		case docAsUpsert && partial != nil:
+			return s.indexDocument(name, id, partial, true, nil)
+		case upsert != nil:
+			return s.indexDocument(name, id, upsert, true, nil)
+		}
+		return 0, nil, &apiError{
+			Status: http.StatusNotFound,
+			Type:   "document_missing_exception",
+			Reason: fmt.Sprintf("[%s]: document missing", id),
+			Index:  name,
+		}
+	}
+	if partial == nil {
+		return 0, nil, badRequest("action_request_validation_exception", "Validation Failed: 1: script or doc is missing;")
+	}
+
+	source := mergeSource(doc.source, partial)
+	ix := s.indices[name]
+	if reflect.DeepEqual(source, doc.source) {
+		return http.StatusOK, ix.writeResult(doc, "noop"), nil
+	}
+	return s.indexDocument(name, id, source, false, nil)
+}
+
+// mergeSource returns a copy of the source with the partial document
+// merged into it. Objects are merged recursively, other values replaced.
+func mergeSource(source, partial map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(source)+len(partial))
+	for k, v := range source {
+		merged[k] = v
+	}
+	for k, v := range partial {
+		if sub, ok := v.(map[string]interface{}); ok {
+			if orig, ok := merged[k].(map[string]interface{}); ok {
+				merged[k] = mergeSource(orig, sub)
+				continue
+			}
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+func (s *Server) indexDocumentRequest(r *request, name, id string, create bool) (int, interface{}, *apiError) {
+	source, apiErr := r.decodeBody()
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	ifSeqNo, apiErr := parseSeqNo(r.query.Get("if_seq_no"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return s.indexDocument(name, id, source, create, ifSeqNo)
+}
+
+func (s *Server) deleteDocumentRequest(r *request, name, id string) (int, interface{}, *apiError) {
+	ifSeqNo, apiErr := parseSeqNo(r.query.Get("if_seq_no"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return s.deleteDocument(name, id, ifSeqNo)
+}
+
+func (s *Server) updateDocumentRequest(r *request, name, id string) (int, interface{}, *apiError) {
+	body, apiErr := r.decodeBody()
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	ifSeqNo, apiErr := parseSeqNo(r.query.Get("if_seq_no"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return s.updateDocument(name, id, body, ifSeqNo)
+}
+
+func (s *Server) getDocument(name, id string) (int, interface{}, *apiError) {
+	ix, found := s.indices[name]
+	if !found {
+		return 0, nil, indexNotFound(name)
+	}
+	doc, found := ix.docs[id]
+	if !found {
+		return http.StatusNotFound, map[string]interface{}{"_index": name, "_id": id, "found": false}, nil
+	}
+	return http.StatusOK, map[string]interface{}{
+		"_index":        name,
+		"_id":           id,
+		"_version":      doc.version,
+		"_seq_no":       doc.seqNo,
+		"_primary_term": 1,
+		"found":         true,
+		"_source":       doc.source,
+	}, nil
+}
+
+func (s *Server) getSource(name, id string) (int, interface{}, *apiError) {
+	ix, found := s.indices[name]
+	if !found {
+		return 0, nil, indexNotFound(name)
+	}
+	doc, found := ix.docs[id]
+	if !found {
+		return 0, nil, &apiError{
+			Status: http.StatusNotFound,
+			Type:   "resource_not_found_exception",
+			Reason: fmt.Sprintf("Document not found [%s]/[%s]", name, id),
+		}
+	}
+	return http.StatusOK, doc.source, nil
+}
+
+// bulk executes a bulk request. Items fail individually, e.g. with a
+// version conflict, while a malformed request fails as a whole.
+func (s *Server) bulk(r *request, defaultIndex string) (int, interface{}, *apiError) {
+	start := s.now()
+	lines := bytes.Split(r.body, []byte("\n"))
+	var (
+		items     []interface{}
+		hasErrors bool
+	)
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+		var action map[string]map[string]interface{}
+		if err := decodeJSON(line, &action); err != nil || len(action) != 1 {
+			return 0, nil, badRequest("illegal_argument_exception", "Malformed action/metadata line [%d], expected a simple object", i+1)
+		}
+		for op, meta := range action {
+			name, _ := meta["_index"].(string)
+			if name == "" {
+				name = defaultIndex
+			}
+			id := ""
+			if v, found := meta["_id"]; found {
+				id = fmt.Sprint(v)
+			}
+			var source map[string]interface{}
+			if op == "index" || op == "create" || op == "update" {
+				i++
+				if i >= len(lines) || len(bytes.TrimSpace(lines[i])) == 0 {
+					return 0, nil, badRequest("illegal_argument_exception", "Malformed bulk request: missing source for action line [%d]", i)
+				}
+				if err := decodeJSON(lines[i], &source); err != nil {
+					return 0, nil, badRequest("parse_exception", "Malformed bulk request: invalid source on line [%d]: %v", i+1, err)
+				}
+			}
+			ifSeqNo, apiErr := parseSeqNo(meta["if_seq_no"])
+
+			var (
+				status int
+				res    map[string]interface{}
+			)
+			switch {
+			case apiErr != nil:
+			case name == "":
+				apiErr = badRequest("action_request_validation_exception", "Validation Failed: 1: index is missing;")
+			case op == "index":
+				status, res, apiErr = s.indexDocument(name, id, source, meta["op_type"] == "create", ifSeqNo)
+			case op == "create":
+				status, res, apiErr = s.indexDocument(name, id, source, true, ifSeqNo)
+			case op == "update" && id != "":
+				status, res, apiErr = s.updateDocument(name, id, source, ifSeqNo)
+			case op == "delete" && id != "":
+				status, res, apiErr = s.deleteDocument(name, id, ifSeqNo)
+			case op == "update" || op == "delete":
+				apiErr = badRequest("action_request_validation_exception", "Validation Failed: 1: id is missing;")
+			default:
+				return 0, nil, badRequest("illegal_argument_exception", "Malformed action/metadata line [%d], expected one of [create, delete, index, update] but found [%s]", i+1, op)
+			}
+			if apiErr != nil {
+				hasErrors = true
+				status = apiErr.Status
+				res = map[string]interface{}{"_index": name, "_id": id, "error": apiErr.cause()}
+			}
+			res["status"] = status
+			items = append(items, map[string]interface{}{op: res})
+		}
+	}
+	return http.StatusOK, map[string]interface{}{
+		"took":   s.now().Sub(start).Milliseconds(),
+		"errors": hasErrors,
+		"items":  items,
+	}, nil
+}
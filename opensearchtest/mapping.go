@@ -0,0 +1,474 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// keywordIgnoreAbove is the length above which strings are not indexed
+// in the keyword sub-field created by dynamic mapping.
+const keywordIgnoreAbove = 256
+
+// field is a mapped field of an index.
+type field struct {
+	// Type of the field, e.g. "text", "keyword", "long", or "date".
+	Type string
+	// Path of the value in the source. It differs from the name of the
+	// field for multi-fields, e.g. "user.keyword" is read from "user".
+	Path string
+	// IgnoreAbove skips keyword values longer than this, if positive.
+	IgnoreAbove int
+}
+
+// isNumeric returns true for the numeric field types.
+func (f field) isNumeric() bool {
+	switch f.Type {
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		return true
+	}
+	return false
+}
+
+// addMapping adds the fields of an explicit mapping, i.e. the "properties"
+// of the "mappings" passed when creating an index.
+func (ix *index) addMapping(properties map[string]interface{}, prefix string) {
+	for name, v := range properties {
+		def, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		full := prefix + name
+		if props, ok := def["properties"].(map[string]interface{}); ok {
+			ix.addMapping(props, full+".")
+			continue
+		}
+		typ, _ := def["type"].(string)
+		if typ == "" {
+			typ = "object"
+		}
+		ix.fields[full] = field{Type: typ, Path: full, IgnoreAbove: intValue(def["ignore_above"])}
+		subfields, _ := def["fields"].(map[string]interface{})
+		for subname, v := range subfields {
+			subdef, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			typ, _ := subdef["type"].(string)
+			ix.fields[full+"."+subname] = field{Type: typ, Path: full, IgnoreAbove: intValue(subdef["ignore_above"])}
+		}
+	}
+}
+
+// addDynamicMapping maps the fields of the source that are not mapped
+// yet, like the default dynamic mapping of Opensearch: strings become
+// dates if they look like one, and text fields with a keyword sub-field
+// otherwise.
+func (ix *index) addDynamicMapping(source map[string]interface{}, prefix string) {
+	for name, v := range source {
+		full := prefix + name
+		if _, found := ix.fields[full]; found {
+			continue
+		}
+		if arr, ok := v.([]interface{}); ok {
+			v = nil
+			for _, elem := range arr {
+				if elem != nil {
+					v = elem
+					break
+				}
+			}
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			ix.addDynamicMapping(v, full+".")
+		case string:
+			if _, err := parseDate(v); err == nil {
+				ix.fields[full] = field{Type: "date", Path: full}
+			} else {
+				ix.fields[full] = field{Type: "text", Path: full}
+				ix.fields[full+".keyword"] = field{Type: "keyword", Path: full, IgnoreAbove: keywordIgnoreAbove}
+			}
+		case json.Number:
+			if strings.ContainsAny(v.String(), ".eE") {
+				ix.fields[full] = field{Type: "float", Path: full}
+			} else {
+				ix.fields[full] = field{Type: "long", Path: full}
+			}
+		case bool:
+			ix.fields[full] = field{Type: "boolean", Path: full}
+		}
+	}
+}
+
+// mapping returns the mapping of the index in the format returned by the
+// Get Mapping API.
+func (ix *index) mapping() map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	// Fields are added in order of their names, so that a field is
+	// added before its multi-fields
+	names := make([]string, 0, len(ix.fields))
+	for name := range ix.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := ix.fields[name]
+		def := map[string]interface{}{"type": f.Type}
+		if f.IgnoreAbove > 0 {
+			def["ignore_above"] = f.IgnoreAbove
+		}
+		if f.Path != name {
+			parent := lookupProperty(properties, f.Path)
+			if parent == nil {
+				continue
+			}
+			subfields, _ := parent["fields"].(map[string]interface{})
+			if subfields == nil {
+				subfields = make(map[string]interface{})
+				parent["fields"] = subfields
+			}
+			subfields[strings.TrimPrefix(name, f.Path+".")] = def
+			continue
+		}
+		// Dots in field names denote objects, as in Opensearch
+		props := properties
+		parts := strings.Split(name, ".")
+		for _, part := range parts[:len(parts)-1] {
+			obj, _ := props[part].(map[string]interface{})
+			if obj == nil {
+				obj = make(map[string]interface{})
+				props[part] = obj
+			}
+			sub, _ := obj["properties"].(map[string]interface{})
+			if sub == nil {
+				sub = make(map[string]interface{})
+				obj["properties"] = sub
+			}
+			props = sub
+		}
+		props[parts[len(parts)-1]] = def
+	}
+	return map[string]interface{}{"properties": properties}
+}
+
+// lookupProperty returns the definition of the field at the given path
+// in the properties of a mapping, or nil.
+func lookupProperty(properties map[string]interface{}, path string) map[string]interface{} {
+	name, rest, nested := strings.Cut(path, ".")
+	def, _ := properties[name].(map[string]interface{})
+	if def == nil || !nested {
+		return def
+	}
+	props, _ := def["properties"].(map[string]interface{})
+	return lookupProperty(props, rest)
+}
+
+// values returns the mapped field with the given name and its values in
+// the document, converted to the type of the field. Text fields return
+// their tokens. The field type is empty if the field is not mapped.
+func (ix *index) values(doc *document, name string) (field, []interface{}) {
+	if name == "_id" {
+		return field{Type: "keyword", Path: "_id"}, []interface{}{doc.id}
+	}
+	f, found := ix.fields[name]
+	if !found {
+		return field{}, nil
+	}
+	var values []interface{}
+	for _, raw := range lookup(doc.source, f.Path) {
+		if f.Type == "text" {
+			s, ok := raw.(string)
+			if !ok {
+				s = fmt.Sprint(raw)
+			}
+			for _, token := range analyze(s) {
+				values = append(values, token)
+			}
+			continue
+		}
+		if v, ok := f.convert(raw); ok {
+			values = append(values, v)
+		}
+	}
+	return f, values
+}
+
+// lookup returns the values at the given path, e.g. "user.name", with
+// arrays flattened. Keys of the source may contain dots themselves.
+func lookup(v interface{}, path string) []interface{} {
+	if path == "" {
+		switch v := v.(type) {
+		case nil:
+			return nil
+		case []interface{}:
+			var values []interface{}
+			for _, elem := range v {
+				values = append(values, lookup(elem, "")...)
+			}
+			return values
+		}
+		return []interface{}{v}
+	}
+	var values []interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for i := 0; i <= len(path); i++ {
+			if i < len(path) && path[i] != '.' {
+				continue
+			}
+			if child, found := v[path[:i]]; found {
+				values = append(values, lookup(child, strings.TrimPrefix(path[i:], "."))...)
+			}
+		}
+	case []interface{}:
+		for _, elem := range v {
+			values = append(values, lookup(elem, path)...)
+		}
+	}
+	return values
+}
+
+// convert converts a value of the source, or of a query, to the type of
+// the field: a string for keyword fields, a float64 for numeric fields,
+// a bool for boolean fields, and a time.Time for date fields.
+func (f field) convert(v interface{}) (interface{}, bool) {
+	switch {
+	case f.Type == "keyword" || f.Type == "text":
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case json.Number:
+			s = v.String()
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			s = strconv.FormatBool(v)
+		default:
+			return nil, false
+		}
+		if f.IgnoreAbove > 0 && len(s) > f.IgnoreAbove {
+			return nil, false
+		}
+		return s, true
+	case f.isNumeric():
+		n, err := toFloat(v)
+		return n, err == nil
+	case f.Type == "boolean":
+		switch v := v.(type) {
+		case bool:
+			return v, true
+		case string:
+			b, err := strconv.ParseBool(v)
+			return b, err == nil
+		}
+	case f.Type == "date":
+		switch v := v.(type) {
+		case string:
+			t, err := parseDate(v)
+			return t, err == nil
+		default:
+			ms, err := toFloat(v)
+			if err != nil {
+				return nil, false
+			}
+			return time.UnixMilli(int64(ms)).UTC(), true
+		}
+	}
+	return nil, false
+}
+
+// toFloat converts a JSON number, or a string containing one, to float64.
+func toFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.Float64()
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("not a number: %v", v)
+}
+
+// intValue returns v as an int, or 0 if it is not a number.
+func intValue(v interface{}) int {
+	n, err := toFloat(v)
+	if err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// analyze splits text into lowercase tokens, like the standard analyzer.
+func analyze(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// dateFormats are the formats of dates accepted in documents and queries.
+var dateFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+// parseDate parses a date in one of the dateFormats.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range dateFormats {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse date field [%s]", s)
+}
+
+// parseDateMath parses a date like parseDate, and also supports date math
+// relative to now, e.g. "now-1d" or "now-1h/d".
+func parseDateMath(s string, now time.Time) (time.Time, error) {
+	if !strings.HasPrefix(s, "now") {
+		return parseDate(s)
+	}
+	t := now.UTC()
+	expr := s[len("now"):]
+	for len(expr) > 0 {
+		op := expr[0]
+		expr = expr[1:]
+		i := 0
+		for i < len(expr) && expr[i] >= '0' && expr[i] <= '9' {
+			i++
+		}
+		n := 1
+		if i > 0 {
+			n, _ = strconv.Atoi(expr[:i])
+		}
+		if i >= len(expr) {
+			return time.Time{}, fmt.Errorf("failed to parse date math [%s]", s)
+		}
+		unit := expr[i]
+		expr = expr[i+1:]
+		switch op {
+		case '+', '-':
+			if op == '-' {
+				n = -n
+			}
+			t = addUnit(t, unit, n)
+		case '/':
+			t = floorUnit(t, unit)
+		default:
+			return time.Time{}, fmt.Errorf("failed to parse date math [%s]", s)
+		}
+		if t.IsZero() {
+			return time.Time{}, fmt.Errorf("failed to parse date math [%s]", s)
+		}
+	}
+	return t, nil
+}
+
+// addUnit adds n date math units, e.g. 'd' for days, to t. It returns
+// the zero time for unknown units.
+func addUnit(t time.Time, unit byte, n int) time.Time {
+	switch unit {
+	case 'y':
+		return t.AddDate(n, 0, 0)
+	case 'M':
+		return t.AddDate(0, n, 0)
+	case 'w':
+		return t.AddDate(0, 0, 7*n)
+	case 'd':
+		return t.AddDate(0, 0, n)
+	case 'h', 'H':
+		return t.Add(time.Duration(n) * time.Hour)
+	case 'm':
+		return t.Add(time.Duration(n) * time.Minute)
+	case 's':
+		return t.Add(time.Duration(n) * time.Second)
+	}
+	return time.Time{}
+}
+
+// floorUnit rounds t down to the date math unit, e.g. 'd' for days. It
+// returns the zero time for unknown units.
+func floorUnit(t time.Time, unit byte) time.Time {
+	switch unit {
+	case 'y':
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case 'M':
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case 'w':
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case 'd':
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case 'h', 'H':
+		return t.Truncate(time.Hour)
+	case 'm':
+		return t.Truncate(time.Minute)
+	case 's':
+		return t.Truncate(time.Second)
+	}
+	return time.Time{}
+}
+
+// compareValues compares two converted values of the same type.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if b, ok := b.(time.Time); ok {
+			return a.Compare(b)
+		}
+	}
+	return 0
+}
+
+// equalValues returns true if two converted values are equal.
+func equalValues(a, b interface{}) bool {
+	switch a := a.(type) {
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	}
+	return a == b
+}
@@ -0,0 +1,353 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// predicate returns true if the document matches a query.
+type predicate func(ix *index, doc *document) bool
+
+// compileQuery compiles the JSON of a query into a predicate. It supports
+// the match_all, match_none, ids, exists, term, terms, match, range and
+// bool queries.
+func (s *Server) compileQuery(v interface{}) (predicate, *apiError) {
+	q, ok := v.(map[string]interface{})
+	if !ok || len(q) != 1 {
+		return nil, badRequest("parsing_exception", "query malformed, must start with start_object")
+	}
+	for typ, body := range q {
+		params, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, badRequest("parsing_exception", "[%s] query malformed, no start_object after query name", typ)
+		}
+		switch typ {
+		case "match_all":
+			return func(*index, *document) bool { return true }, nil
+		case "match_none":
+			return func(*index, *document) bool { return false }, nil
+		case "ids":
+			return compileIdsQuery(params)
+		case "exists":
+			return compileExistsQuery(params)
+		case "term":
+			return compileTermQuery(params)
+		case "terms":
+			return compileTermsQuery(params)
+		case "match":
+			return compileMatchQuery(params)
+		case "range":
+			return s.compileRangeQuery(params)
+		case "bool":
+			return s.compileBoolQuery(params)
+		}
+		return nil, badRequest("parsing_exception", "unknown query [%s]", typ)
+	}
+	return nil, nil
+}
+
+// fieldParams returns the name of the field of a query like term or match,
+// and its parameters. A short form like {"user":"olivere"} is returned
+// as the parameters {key: "olivere"}.
+func fieldParams(typ string, params map[string]interface{}, key string) (string, map[string]interface{}, *apiError) {
+	var (
+		name   string
+		fields map[string]interface{}
+	)
+	for k, v := range params {
+		if k == "boost" || k == "_name" {
+			continue
+		}
+		if name != "" {
+			return "", nil, badRequest("parsing_exception", "[%s] query doesn't support multiple fields, found [%s] and [%s]", typ, name, k)
+		}
+		name = k
+		if m, ok := v.(map[string]interface{}); ok {
+			fields = m
+		} else {
+			fields = map[string]interface{}{key: v}
+		}
+	}
+	if name == "" {
+		return "", nil, badRequest("parsing_exception", "[%s] query requires a field", typ)
+	}
+	return name, fields, nil
+}
+
+func compileIdsQuery(params map[string]interface{}) (predicate, *apiError) {
+	values, _ := params["values"].([]interface{})
+	ids := make(map[string]bool, len(values))
+	for _, v := range values {
+		ids[fmt.Sprint(v)] = true
+	}
+	return func(_ *index, doc *document) bool {
+		return ids[doc.id]
+	}, nil
+}
+
+func compileExistsQuery(params map[string]interface{}) (predicate, *apiError) {
+	name, _ := params["field"].(string)
+	if name == "" {
+		return nil, badRequest("parsing_exception", "[exists] must be provided with a [field]")
+	}
+	return func(ix *index, doc *document) bool {
+		_, values := ix.values(doc, name)
+		return len(values) > 0
+	}, nil
+}
+
+// matchesValue returns true if any of the values equals the query value,
+// converted to the type of the field. Query values are not analyzed.
+func matchesValue(f field, values []interface{}, v interface{}) bool {
+	want, ok := f.convert(v)
+	if !ok {
+		return false
+	}
+	for _, have := range values {
+		if equalValues(have, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileTermQuery(params map[string]interface{}) (predicate, *apiError) {
+	name, fields, apiErr := fieldParams("term", params, "value")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	value, found := fields["value"]
+	if !found {
+		return nil, badRequest("parsing_exception", "[term] query requires a value for field [%s]", name)
+	}
+	return func(ix *index, doc *document) bool {
+		f, values := ix.values(doc, name)
+		return matchesValue(f, values, value)
+	}, nil
+}
+
+func compileTermsQuery(params map[string]interface{}) (predicate, *apiError) {
+	var (
+		name  string
+		terms []interface{}
+	)
+	for k, v := range params {
+		if k == "boost" || k == "_name" {
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, badRequest("parsing_exception", "[terms] query does not support [%s]", k)
+		}
+		name, terms = k, arr
+	}
+	if name == "" {
+		return nil, badRequest("parsing_exception", "[terms] query requires a field")
+	}
+	return func(ix *index, doc *document) bool {
+		f, values := ix.values(doc, name)
+		for _, term := range terms {
+			if matchesValue(f, values, term) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// compileMatchQuery compiles a match query. The query text is analyzed
+// for text fields, and must match any ("or", the default) or all ("and")
+// of the tokens of the field. For other fields, it is a term query.
+func compileMatchQuery(params map[string]interface{}) (predicate, *apiError) {
+	name, fields, apiErr := fieldParams("match", params, "query")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	query, found := fields["query"]
+	if !found {
+		return nil, badRequest("parsing_exception", "[match] query requires a query for field [%s]", name)
+	}
+	operator, _ := fields["operator"].(string)
+	and := strings.EqualFold(operator, "and")
+	return func(ix *index, doc *document) bool {
+		f, values := ix.values(doc, name)
+		if f.Type != "text" {
+			return matchesValue(f, values, query)
+		}
+		tokens := make(map[string]bool, len(values))
+		for _, v := range values {
+			tokens[v.(string)] = true
+		}
+		terms := analyze(fmt.Sprint(query))
+		if len(terms) == 0 {
+			return false
+		}
+		for _, term := range terms {
+			switch {
+			case tokens[term] && !and:
+				return true
+			case !tokens[term] && and:
+				return false
+			}
+		}
+		return and
+	}, nil
+}
+
+// bound is the lower or upper bound of a range query.
+type bound struct {
+	value     interface{}
+	inclusive bool
+}
+
+// compileRangeQuery compiles a range query, with bounds given either as
+// gt, gte, lt and lte, or as from, to, include_lower and include_upper.
+// Dates support date math relative to now, e.g. "now-1d".
+func (s *Server) compileRangeQuery(params map[string]interface{}) (predicate, *apiError) {
+	name, fields, apiErr := fieldParams("range", params, "")
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var lower, upper *bound
+	includeLower, includeUpper := true, true
+	if v, ok := fields["include_lower"].(bool); ok {
+		includeLower = v
+	}
+	if v, ok := fields["include_upper"].(bool); ok {
+		includeUpper = v
+	}
+	for k, v := range fields {
+		if v == nil {
+			continue
+		}
+		switch k {
+		case "gt":
+			lower = &bound{v, false}
+		case "gte":
+			lower = &bound{v, true}
+		case "lt":
+			upper = &bound{v, false}
+		case "lte":
+			upper = &bound{v, true}
+		}
+	}
+	if v := fields["from"]; v != nil && lower == nil {
+		lower = &bound{v, includeLower}
+	}
+	if v := fields["to"]; v != nil && upper == nil {
+		upper = &bound{v, includeUpper}
+	}
+
+	now := s.now()
+	convert := func(f field, b *bound) (interface{}, bool) {
+		if s, ok := b.value.(string); ok && f.Type == "date" {
+			t, err := parseDateMath(s, now)
+			return t, err == nil
+		}
+		return f.convert(b.value)
+	}
+	return func(ix *index, doc *document) bool {
+		f, values := ix.values(doc, name)
+		if f.Type == "text" {
+			return false
+		}
+		var lo, hi interface{}
+		if lower != nil {
+			v, ok := convert(f, lower)
+			if !ok {
+				return false
+			}
+			lo = v
+		}
+		if upper != nil {
+			v, ok := convert(f, upper)
+			if !ok {
+				return false
+			}
+			hi = v
+		}
+		for _, v := range values {
+			if lo != nil {
+				c := compareValues(v, lo)
+				if c < 0 || (c == 0 && !lower.inclusive) {
+					continue
+				}
+			}
+			if hi != nil {
+				c := compareValues(v, hi)
+				if c > 0 || (c == 0 && !upper.inclusive) {
+					continue
+				}
+			}
+			return true
+		}
+		return false
+	}, nil
+}
+
+// compileBoolQuery compiles a bool query with must, filter, should and
+// must_not clauses, and minimum_should_match as a number.
+func (s *Server) compileBoolQuery(params map[string]interface{}) (predicate, *apiError) {
+	clauses := make(map[string][]predicate)
+	for _, occur := range []string{"must", "filter", "should", "must_not"} {
+		var queries []interface{}
+		switch v := params[occur].(type) {
+		case nil:
+		case []interface{}:
+			queries = v
+		default:
+			queries = []interface{}{v}
+		}
+		for _, q := range queries {
+			p, apiErr := s.compileQuery(q)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			clauses[occur] = append(clauses[occur], p)
+		}
+	}
+
+	minimumShouldMatch := 0
+	if len(clauses["must"]) == 0 && len(clauses["filter"]) == 0 && len(clauses["should"]) > 0 {
+		minimumShouldMatch = 1
+	}
+	if v, found := params["minimum_should_match"]; found {
+		n, err := toFloat(v)
+		if err != nil {
+			return nil, badRequest("parsing_exception", "[bool] query supports minimum_should_match only as a number, got [%v]", v)
+		}
+		minimumShouldMatch = int(n)
+		if minimumShouldMatch < 0 {
+			minimumShouldMatch += len(clauses["should"])
+		}
+	}
+
+	return func(ix *index, doc *document) bool {
+		for _, p := range clauses["must"] {
+			if !p(ix, doc) {
+				return false
+			}
+		}
+		for _, p := range clauses["filter"] {
+			if !p(ix, doc) {
+				return false
+			}
+		}
+		for _, p := range clauses["must_not"] {
+			if p(ix, doc) {
+				return false
+			}
+		}
+		matched := 0
+		for _, p := range clauses["should"] {
+			if p(ix, doc) {
+				matched++
+			}
+		}
+		return matched >= minimumShouldMatch
+	}, nil
+}
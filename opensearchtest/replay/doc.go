@@ -0,0 +1,33 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+/*
+Package replay records HTTP interactions with Opensearch to cassette
+files and replays them, so that tests can run without a cluster.
+
+Record the interactions once against a running cluster:
+
+	rec, err := replay.New("testdata/search.json", replay.WithMode(replay.ModeRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Stop() // writes the cassette
+
+	client, err := opensearch.NewClient(
+		opensearch.SetURL("http://127.0.0.1:9200"),
+		opensearch.SetHttpClient(rec.Client()),
+		opensearch.SetSniff(false),
+		opensearch.SetHealthcheck(false),
+	)
+
+Then commit the cassette and run the same test with the default
+ModeReplay, which fails requests that have not been recorded.
+
+By default, requests are matched by method, path, query string and body,
+with JSON bodies compared regardless of formatting and key order. Use
+WithMatcher to change that, e.g. to ignore the body. The Authorization
+header is never written to a cassette; use WithRedactedHeaders to leave
+out other headers.
+*/
+package replay
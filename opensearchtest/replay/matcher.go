@@ -0,0 +1,85 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+)
+
+// Matcher reports whether a request matches a recorded one.
+// The body of the request is passed uncompressed.
+type Matcher func(req *Request, recorded *Request) bool
+
+// DefaultMatchers match requests by method, path, query string and
+// normalized JSON body.
+var DefaultMatchers = []Matcher{
+	MatchMethod,
+	MatchPath,
+	MatchQuery,
+	MatchJSONBody,
+}
+
+// MatchMethod matches requests with the same HTTP method.
+func MatchMethod(req *Request, recorded *Request) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchPath matches requests with the same path.
+func MatchPath(req *Request, recorded *Request) bool {
+	return req.Path == recorded.Path
+}
+
+// MatchQuery matches requests with the same query parameters,
+// regardless of their order.
+func MatchQuery(req *Request, recorded *Request) bool {
+	q1, err1 := url.ParseQuery(req.Query)
+	q2, err2 := url.ParseQuery(recorded.Query)
+	if err1 != nil || err2 != nil {
+		return req.Query == recorded.Query
+	}
+	if len(q1) == 0 && len(q2) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(q1, q2)
+}
+
+// MatchBody matches requests with exactly the same body.
+func MatchBody(req *Request, recorded *Request) bool {
+	return req.Body == recorded.Body
+}
+
+// MatchJSONBody matches requests whose bodies are equal JSON documents,
+// regardless of formatting and the order of keys. Bodies with one JSON
+// document per line, e.g. of bulk requests, are compared line by line.
+// Bodies that are no JSON must be equal.
+func MatchJSONBody(req *Request, recorded *Request) bool {
+	if req.Body == recorded.Body {
+		return true
+	}
+	v1, ok1 := decodeJSONLines(req.Body)
+	v2, ok2 := decodeJSONLines(recorded.Body)
+	if !ok1 || !ok2 {
+		return false
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+// decodeJSONLines decodes the body as a stream of JSON documents.
+func decodeJSONLines(body string) ([]interface{}, bool) {
+	var docs []interface{}
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.UseNumber()
+	for dec.More() {
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			return nil, false
+		}
+		docs = append(docs, doc)
+	}
+	return docs, true
+}
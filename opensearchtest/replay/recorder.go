@@ -0,0 +1,278 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package replay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Mode specifies whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeReplay replays recorded interactions, and fails requests that
+	// have not been recorded. It is the default.
+	ModeReplay Mode = iota
+	// ModeRecord passes all requests to the cluster and records them,
+	// replacing the interactions of an existing cassette.
+	ModeRecord
+	// ModeReplayOrRecord replays recorded interactions, and passes all
+	// other requests to the cluster and records them.
+	ModeReplayOrRecord
+)
+
+// ErrNoInteraction is returned in ModeReplay for requests that do not
+// match any recorded interaction.
+var ErrNoInteraction = errors.New("replay: no recorded interaction matches the request")
+
+// Recorder is an http.RoundTripper that records requests and responses
+// to a cassette, or replays them from it.
+type Recorder struct {
+	path     string
+	mode     Mode
+	rt       http.RoundTripper
+	matchers []Matcher
+	redacted map[string]bool
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[*Interaction]bool
+	changed  bool
+}
+
+// Option signature for specifying options, e.g. WithMode.
+type Option func(r *Recorder)
+
+// WithMode specifies whether to record or replay. It is ModeReplay
+// by default.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithRoundTripper specifies the http.RoundTripper to pass requests to
+// when recording. If it is nil (default), the recorder will use
+// http.DefaultTransport.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.rt = rt
+	}
+}
+
+// WithMatcher specifies the matchers a request must satisfy to replay
+// a recorded interaction. It is DefaultMatchers by default.
+func WithMatcher(matchers ...Matcher) Option {
+	return func(r *Recorder) {
+		r.matchers = matchers
+	}
+}
+
+// WithRedactedHeaders specifies request headers that must not be written
+// to the cassette, e.g. because they contain credentials. The
+// Authorization header is always redacted.
+func WithRedactedHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		for _, h := range headers {
+			r.redacted[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+}
+
+// New creates a new Recorder for the cassette at the given path. In
+// ModeReplay, the cassette must exist.
+func New(path string, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		path:     path,
+		matchers: DefaultMatchers,
+		redacted: map[string]bool{"Authorization": true},
+		used:     make(map[*Interaction]bool),
+	}
+	for _, o := range opts {
+		o(r)
+	}
+
+	switch r.mode {
+	case ModeRecord:
+		r.cassette = new(Cassette)
+	default:
+		cassette, err := LoadCassette(path)
+		switch {
+		case err == nil:
+			r.cassette = cassette
+		case errors.Is(err, fs.ErrNotExist) && r.mode == ModeReplayOrRecord:
+			r.cassette = new(Cassette)
+		default:
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Client returns an http.Client that uses the Recorder as its transport,
+// e.g. to pass it to opensearch.SetHttpClient.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Stop writes the cassette if any interactions have been recorded.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.changed {
+		return nil
+	}
+	if err := r.cassette.Save(r.path); err != nil {
+		return err
+	}
+	r.changed = false
+	return nil
+}
+
+// RoundTrip replays or records the request.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Do not modify the request of the caller
+	req = req.Clone(req.Context())
+	recReq, err := r.newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode != ModeRecord {
+		if i := r.find(recReq); i != nil {
+			return i.Response.toHTTP(req), nil
+		}
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, recReq.Method, recReq.Path)
+		}
+	}
+
+	rt := r.rt
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	res, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	i := &Interaction{
+		Request: *recReq,
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     res.Header.Clone(),
+			Body:       string(body),
+		},
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.used[i] = true
+	r.changed = true
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// newRequest converts the HTTP request into a recorded request, leaving
+// the body of the HTTP request intact.
+func (r *Recorder) newRequest(req *http.Request) (*Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	if req.Header.Get("Content-Encoding") == "gzip" && len(body) > 0 {
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		body, err = io.ReadAll(gz)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	header := make(http.Header)
+	for k, v := range req.Header {
+		if !r.redacted[http.CanonicalHeaderKey(k)] {
+			header[k] = append([]string(nil), v...)
+		}
+	}
+	return &Request{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Header: header,
+		Body:   string(body),
+	}, nil
+}
+
+// find returns the first matching interaction that has not been replayed
+// yet, so that identical requests replay their responses in order. If all
+// matching interactions have been replayed, the last one is used again.
+func (r *Recorder) find(req *Request) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *Interaction
+	for _, i := range r.cassette.Interactions {
+		if !r.matches(req, &i.Request) {
+			continue
+		}
+		if !r.used[i] {
+			r.used[i] = true
+			return i
+		}
+		last = i
+	}
+	return last
+}
+
+func (r *Recorder) matches(req *Request, recorded *Request) bool {
+	for _, m := range r.matchers {
+		if !m(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// toHTTP returns the recorded response as an HTTP response to req.
+func (res Response) toHTTP(req *http.Request) *http.Response {
+	header := res.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}
+}
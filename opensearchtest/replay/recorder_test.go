@@ -0,0 +1,245 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/disaster37/opensearch/v2"
+)
+
+// fakeCluster answers the few requests used in the tests below,
+// and counts the requests it received.
+type fakeCluster struct {
+	mu       sync.Mutex
+	requests int
+	version  int
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/tweets/_search":
+		fmt.Fprint(w, `{"took":1,"hits":{"total":{"value":1,"relation":"eq"},"hits":[{"_index":"tweets","_id":"1","_source":{"user":"olivere"}}]}}`)
+	case r.Method == "POST" && r.URL.Path == "/_bulk":
+		body, _ := io.ReadAll(r.Body)
+		n := strings.Count(string(body), `"index"`)
+		var items []string
+		for i := 0; i < n; i++ {
+			items = append(items, fmt.Sprintf(`{"index":{"_index":"tweets","_id":"%d","status":201}}`, i+1))
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":false,"items":[%s]}`, strings.Join(items, ","))
+	case r.Method == "PUT" && r.URL.Path == "/_plugins/_ism/policies/hot-warm":
+		f.version++
+		fmt.Fprintf(w, `{"_id":"hot-warm","_version":%d,"_seq_no":0,"_primary_term":1}`, f.version)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, `{"error":"unexpected %s %s"}`, r.Method, r.URL.Path)
+	}
+}
+
+func newTestClient(t *testing.T, url string, rec *Recorder, options ...opensearch.ClientOptionFunc) *opensearch.Client {
+	t.Helper()
+	client, err := opensearch.NewClient(append([]opensearch.ClientOptionFunc{
+		opensearch.SetURL(url),
+		opensearch.SetHttpClient(rec.Client()),
+		opensearch.SetSniff(false),
+		opensearch.SetHealthcheck(false),
+		opensearch.SetBasicAuth("admin", "secret"),
+	}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// exercise runs a search, a bulk request and two ISM calls.
+func exercise(t *testing.T, client *opensearch.Client) {
+	t.Helper()
+	ctx := context.Background()
+
+	res, err := client.Search("tweets").Query(opensearch.NewTermQuery("user", "olivere")).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), res.TotalHits(); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+
+	bulk, err := client.Bulk().Add(
+		opensearch.NewBulkIndexRequest().Index("tweets").Id("1").Doc(map[string]interface{}{"user": "olivere", "retweets": 1}),
+		opensearch.NewBulkIndexRequest().Index("tweets").Id("2").Doc(map[string]interface{}{"user": "sandrae", "retweets": 2}),
+	).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(bulk.Items); want != have {
+		t.Fatalf("expected %d bulk items; got: %d", want, have)
+	}
+
+	// Identical requests replay their responses in order
+	for i := 1; i <= 2; i++ {
+		policy, err := client.IsmPutPolicy("hot-warm").Body(map[string]interface{}{"policy": map[string]interface{}{"description": "hot warm"}}).Do(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := int64(i), policy.Version; want != have {
+			t.Fatalf("expected version %d; got: %d", want, have)
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	cluster := &fakeCluster{}
+	ts := httptest.NewServer(cluster)
+	path := filepath.Join(t.TempDir(), "cassettes", "exercise.json")
+
+	// Record
+	rec, err := New(path, WithMode(ModeRecord))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise(t, newTestClient(t, ts.URL, rec))
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 4, len(cassette.Interactions); want != have {
+		t.Fatalf("expected %d interactions; got: %d", want, have)
+	}
+	for _, i := range cassette.Interactions {
+		if i.Request.Header.Get("Authorization") != "" {
+			t.Fatal("expected Authorization header to be redacted")
+		}
+	}
+
+	// Replay, with gzip-compressed requests and without the cluster
+	rec, err = New(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exercise(t, newTestClient(t, ts.URL, rec, opensearch.SetGzip(true)))
+	if want, have := 4, cluster.requests; want != have {
+		t.Fatalf("expected %d requests to the cluster; got: %d", want, have)
+	}
+
+	// Requests that have not been recorded fail
+	_, err = newTestClient(t, ts.URL, rec).Get().Index("tweets").Id("1").Do(context.Background())
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Fatalf("expected %v; got: %v", ErrNoInteraction, err)
+	}
+}
+
+func TestReplayOrRecord(t *testing.T) {
+	cluster := &fakeCluster{}
+	ts := httptest.NewServer(cluster)
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "search.json")
+
+	for i := 0; i < 2; i++ {
+		rec, err := New(path, WithMode(ModeReplayOrRecord))
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := newTestClient(t, ts.URL, rec)
+		if _, err := client.Search("tweets").Do(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if err := rec.Stop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want, have := 1, cluster.requests; want != have {
+		t.Fatalf("expected %d requests to the cluster; got: %d", want, have)
+	}
+}
+
+func TestReplayWithMissingCassette(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing.json"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %v; got: %v", os.ErrNotExist, err)
+	}
+}
+
+func TestReplayWithMatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &Cassette{
+		Interactions: []*Interaction{
+			{
+				Request:  Request{Method: "POST", Path: "/tweets/_search", Body: `{"query":{"match_all":{}}}`},
+				Response: Response{StatusCode: 200, Body: `{"hits":{"total":{"value":42,"relation":"eq"}}}`},
+			},
+		},
+	}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ignore the body
+	rec, err := New(path, WithMatcher(MatchMethod, MatchPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newTestClient(t, "http://127.0.0.1:9200", rec)
+	res, err := client.Search("tweets").Query(opensearch.NewTermQuery("user", "olivere")).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(42), res.TotalHits(); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+}
+
+func TestMatchJSONBody(t *testing.T) {
+	tests := []struct {
+		Body     string
+		Recorded string
+		Match    bool
+	}{
+		{``, ``, true},
+		{`{"a":1,"b":[1,2]}`, `{ "b": [1, 2], "a": 1 }`, true},
+		{`{"a":1}`, `{"a":2}`, false},
+		{`{"a":1.0}`, `{"a":1}`, false},
+		{"{\"index\":{}}\n{\"a\":1,\"b\":2}\n", "{ \"index\": {} }\n{\"b\":2,\"a\":1}\n", true},
+		{"{\"index\":{}}\n{\"a\":1}\n", "{\"index\":{}}\n", false},
+		{`not json`, `not json`, true},
+		{`not json`, `not JSON`, false},
+	}
+	for _, tt := range tests {
+		req := &Request{Body: tt.Body}
+		recorded := &Request{Body: tt.Recorded}
+		if want, have := tt.Match, MatchJSONBody(req, recorded); want != have {
+			t.Errorf("%q vs. %q: expected %v; got: %v", tt.Body, tt.Recorded, want, have)
+		}
+	}
+}
+
+func TestMatchQuery(t *testing.T) {
+	if !MatchQuery(&Request{Query: "a=1&b=2"}, &Request{Query: "b=2&a=1"}) {
+		t.Fatal("expected query to match regardless of order")
+	}
+	if MatchQuery(&Request{Query: "a=1"}, &Request{Query: "a=2"}) {
+		t.Fatal("expected query not to match")
+	}
+}
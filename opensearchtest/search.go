@@ -0,0 +1,394 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultSearchSize is the number of hits returned if no size is given.
+const defaultSearchSize = 10
+
+// hit is a document matching a search.
+type hit struct {
+	ix   *index
+	doc  *document
+	sort []interface{} // sort values, nil if not sorted
+}
+
+// sorter is a sort order of a search.
+type sorter struct {
+	field string
+	desc  bool
+}
+
+// findHits returns all documents of the indices that match the query,
+// in the order in which they have been created.
+func (s *Server) findHits(indices []*index, query interface{}) ([]*hit, *apiError) {
+	if query == nil {
+		query = map[string]interface{}{"match_all": map[string]interface{}{}}
+	}
+	matches, apiErr := s.compileQuery(query)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	var hits []*hit
+	for _, ix := range indices {
+		for _, doc := range ix.docs {
+			if matches(ix, doc) {
+				hits = append(hits, &hit{ix: ix, doc: doc})
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].doc.order < hits[j].doc.order })
+	return hits, nil
+}
+
+func (s *Server) search(r *request, expr string) (int, interface{}, *apiError) {
+	start := s.now()
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	body, apiErr := r.decodeBody()
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	hits, apiErr := s.findHits(indices, body["query"])
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+
+	res := map[string]interface{}{
+		"timed_out": false,
+		"_shards":   shards(len(indices)),
+	}
+	aggs, _ := body["aggs"].(map[string]interface{})
+	if aggs == nil {
+		aggs, _ = body["aggregations"].(map[string]interface{})
+	}
+	if aggs != nil {
+		aggregations, apiErr := aggregate(hits, aggs)
+		if apiErr != nil {
+			return 0, nil, apiErr
+		}
+		res["aggregations"] = aggregations
+	}
+
+	sorters := parseSort(body["sort"])
+	if len(sorters) == 0 && r.query.Get("sort") != "" {
+		for _, spec := range strings.Split(r.query.Get("sort"), ",") {
+			name, order, _ := strings.Cut(spec, ":")
+			sorters = append(sorters, sorter{field: name, desc: order == "desc"})
+		}
+	}
+	if len(sorters) > 0 {
+		if apiErr := sortHits(indices, hits, sorters); apiErr != nil {
+			return 0, nil, apiErr
+		}
+	}
+	total := len(hits)
+	if after, ok := body["search_after"].([]interface{}); ok {
+		if len(after) != len(sorters) {
+			return 0, nil, badRequest("illegal_argument_exception", "search_after has %d value(s) but sort has %d", len(after), len(sorters))
+		}
+		afterKeys := make([]interface{}, len(after))
+		for i, v := range after {
+			afterKeys[i] = sortKey(v)
+		}
+		i := sort.Search(len(hits), func(i int) bool {
+			return compareSortKeys(hits[i].sort, afterKeys, sorters) > 0
+		})
+		hits = hits[i:]
+	}
+
+	from := intParam(body["from"], r.query.Get("from"), 0)
+	size := intParam(body["size"], r.query.Get("size"), defaultSearchSize)
+	if from > len(hits) {
+		from = len(hits)
+	}
+	if from+size < len(hits) {
+		hits = hits[from : from+size]
+	} else {
+		hits = hits[from:]
+	}
+
+	includes, excludes, fetchSource := parseSourceFilter(body["_source"])
+	var maxScore interface{} = 1.0
+	if total == 0 || len(sorters) > 0 {
+		maxScore = nil
+	}
+	docs := make([]interface{}, 0, len(hits))
+	for _, h := range hits {
+		doc := map[string]interface{}{
+			"_index": h.ix.name,
+			"_id":    h.doc.id,
+			"_score": maxScore,
+		}
+		if fetchSource {
+			doc["_source"] = filterSource(h.doc.source, "", includes, excludes)
+		}
+		if h.sort != nil {
+			doc["sort"] = h.sort
+		}
+		docs = append(docs, doc)
+	}
+	searchHits := map[string]interface{}{
+		"max_score": maxScore,
+		"hits":      docs,
+	}
+	switch v := body["track_total_hits"].(type) {
+	case bool:
+		if v {
+			searchHits["total"] = map[string]interface{}{"value": total, "relation": "eq"}
+		}
+	case json.Number:
+		if limit, _ := v.Int64(); int64(total) > limit {
+			searchHits["total"] = map[string]interface{}{"value": limit, "relation": "gte"}
+		} else {
+			searchHits["total"] = map[string]interface{}{"value": total, "relation": "eq"}
+		}
+	default:
+		searchHits["total"] = map[string]interface{}{"value": total, "relation": "eq"}
+	}
+	res["hits"] = searchHits
+	res["took"] = s.now().Sub(start).Milliseconds()
+	return http.StatusOK, res, nil
+}
+
+func (s *Server) count(r *request, expr string) (int, interface{}, *apiError) {
+	indices, apiErr := s.resolveIndices(expr)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	body, apiErr := r.decodeBody()
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	hits, apiErr := s.findHits(indices, body["query"])
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]interface{}{
+		"count":   len(hits),
+		"_shards": shards(len(indices)),
+	}, nil
+}
+
+// intParam returns the parameter from the body, or else from the query
+// string, or else the default value.
+func intParam(v interface{}, query string, defaultValue int) int {
+	if v == nil && query != "" {
+		v = query
+	}
+	if v == nil {
+		return defaultValue
+	}
+	n, err := toFloat(v)
+	if err != nil || n < 0 {
+		return defaultValue
+	}
+	return int(n)
+}
+
+// parseSort parses the sort of a search, e.g. ["user", {"created":"desc"}]
+// or [{"created":{"order":"desc"}}].
+func parseSort(v interface{}) []sorter {
+	var specs []interface{}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		specs = v
+	default:
+		specs = []interface{}{v}
+	}
+	var sorters []sorter
+	for _, spec := range specs {
+		switch spec := spec.(type) {
+		case string:
+			sorters = append(sorters, sorter{field: spec, desc: spec == "_score"})
+		case map[string]interface{}:
+			for name, v := range spec {
+				order, _ := v.(string)
+				if params, ok := v.(map[string]interface{}); ok {
+					order, _ = params["order"].(string)
+				}
+				desc := order == "desc" || (order == "" && name == "_score")
+				sorters = append(sorters, sorter{field: name, desc: desc})
+			}
+		}
+	}
+	return sorters
+}
+
+// fielddataDisabled is returned when sorting or aggregating on a text field.
+func fielddataDisabled(name string) *apiError {
+	return badRequest("illegal_argument_exception", "Text fields are not optimised for operations that require per-document field data like aggregations and sorting, so these operations are disabled by default. Please use a keyword field instead. Alternatively, set fielddata=true on [%s] in order to load field data by uninverting the inverted index. Note that this can use significant memory.", name)
+}
+
+// sortHits sorts the hits, and sets their sort values.
+func sortHits(indices []*index, hits []*hit, sorters []sorter) *apiError {
+	for _, sorter := range sorters {
+		switch sorter.field {
+		case "_score", "_doc", "_id":
+			continue
+		}
+		mapped := false
+		for _, ix := range indices {
+			f, found := ix.fields[sorter.field]
+			if f.Type == "text" {
+				return fielddataDisabled(sorter.field)
+			}
+			mapped = mapped || found
+		}
+		if !mapped {
+			return badRequest("query_shard_exception", "No mapping found for [%s] in order to sort on", sorter.field)
+		}
+	}
+
+	for _, h := range hits {
+		h.sort = make([]interface{}, len(sorters))
+		for i, sorter := range sorters {
+			switch sorter.field {
+			case "_score":
+				h.sort[i] = 1.0
+			case "_doc":
+				h.sort[i] = float64(h.doc.order)
+			default:
+				_, values := h.ix.values(h.doc, sorter.field)
+				for _, v := range values {
+					key := sortKey(v)
+					if h.sort[i] == nil || (compareValues(key, h.sort[i]) < 0) != sorter.desc {
+						h.sort[i] = key
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return compareSortKeys(hits[i].sort, hits[j].sort, sorters) < 0
+	})
+	return nil
+}
+
+// sortKey converts a value to a sort value, i.e. a string or a float64.
+// Dates are sorted by milliseconds since the epoch.
+func sortKey(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case time.Time:
+		return float64(v.UnixMilli())
+	case bool:
+		if v {
+			return 1.0
+		}
+		return 0.0
+	}
+	return v
+}
+
+// compareSortKeys compares the sort values of two hits. Missing values
+// are sorted last.
+func compareSortKeys(a, b []interface{}, sorters []sorter) int {
+	for i, sorter := range sorters {
+		switch {
+		case a[i] == nil && b[i] == nil:
+			continue
+		case a[i] == nil:
+			return 1
+		case b[i] == nil:
+			return -1
+		}
+		c := compareValues(a[i], b[i])
+		if sorter.desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// parseSourceFilter parses the _source parameter of a search, which is
+// either a bool, one or more patterns of fields to include, or an object
+// with includes and excludes.
+func parseSourceFilter(v interface{}) (includes, excludes []string, fetch bool) {
+	strs := func(v interface{}) []string {
+		switch v := v.(type) {
+		case string:
+			return []string{v}
+		case []interface{}:
+			var s []string
+			for _, elem := range v {
+				s = append(s, fmt.Sprint(elem))
+			}
+			return s
+		}
+		return nil
+	}
+	switch v := v.(type) {
+	case bool:
+		return nil, nil, v
+	case map[string]interface{}:
+		includes = append(strs(v["includes"]), strs(v["include"])...)
+		excludes = append(strs(v["excludes"]), strs(v["exclude"])...)
+		return includes, excludes, true
+	}
+	return strs(v), nil, true
+}
+
+// filterSource returns the fields of the source that match any of the
+// include patterns, e.g. "user" or "user.*", and none of the exclude
+// patterns. All fields are included if there are no include patterns.
+func filterSource(source map[string]interface{}, prefix string, includes, excludes []string) map[string]interface{} {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return source
+	}
+	filtered := make(map[string]interface{})
+	for k, v := range source {
+		name := prefix + k
+		if matchAny(excludes, name) {
+			continue
+		}
+		obj, isObject := v.(map[string]interface{})
+		switch {
+		case len(includes) == 0 || matchAny(includes, name):
+			if isObject {
+				v = filterSource(obj, name+".", nil, excludes)
+			}
+			filtered[k] = v
+		case isObject:
+			if sub := filterSource(obj, name+".", includes, excludes); len(sub) > 0 {
+				filtered[k] = sub
+			}
+		}
+	}
+	return filtered
+}
+
+// matchAny returns true if the name matches any of the patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDate formats a date like Opensearch does for key_as_string.
+func formatDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,354 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/disaster37/opensearch/v2"
+)
+
+const (
+	// ClusterName is the name of the fake cluster.
+	ClusterName = "opensearchtest"
+	// NodeName is the name of the single node of the fake cluster.
+	NodeName = "opensearchtest-0"
+	// Version is the Opensearch version reported by the fake cluster.
+	Version = "2.11.0"
+
+	nodeId = "b2bbf8c9-opensearchtest"
+)
+
+// Server is an in-memory fake of a single-node Opensearch cluster,
+// served by an httptest.Server. It implements the APIs most commonly
+// used by applications: creating and deleting indices, indexing, getting,
+// updating and deleting documents, bulk requests, and searches with
+// term, match, range and bool queries plus terms and date_histogram
+// aggregations. It also answers the requests of the client for sniffing
+// and healthchecks.
+//
+// Documents are searchable immediately after being indexed, and all
+// hits have a score of 1. Requests to unsupported APIs fail with
+// status 400.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	indices map[string]*index
+	order   int64
+	now     func() time.Time
+}
+
+// index is an index of the fake cluster.
+type index struct {
+	name   string
+	fields map[string]field
+	docs   map[string]*document
+	seqNo  int64
+}
+
+// document is a document of an index.
+type document struct {
+	id      string
+	version int64
+	seqNo   int64
+	order   int64 // order in which the documents have been created
+	source  map[string]interface{}
+}
+
+// NewServer starts and returns a new Server. Callers should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		indices: make(map[string]*index),
+		now:     time.Now,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Client returns a new client that connects to the server. Sniffing and
+// healthchecks work as with a real cluster, so they need not be disabled.
+func (s *Server) Client(options ...opensearch.ClientOptionFunc) (*opensearch.Client, error) {
+	return opensearch.NewClient(append([]opensearch.ClientOptionFunc{
+		opensearch.SetURL(s.URL),
+		opensearch.SetScheme("http"),
+	}, options...)...)
+}
+
+// Reset deletes all indices, e.g. between tests.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.indices = make(map[string]*index)
+}
+
+// apiError is an error returned by the fake cluster.
+type apiError struct {
+	Status int
+	Type   string
+	Reason string
+	Index  string
+}
+
+// cause returns the error in the format of the "error" field of bulk
+// response items, and of the root causes of errors.
+func (e *apiError) cause() map[string]interface{} {
+	cause := map[string]interface{}{"type": e.Type, "reason": e.Reason}
+	if e.Index != "" {
+		cause["index"] = e.Index
+	}
+	return cause
+}
+
+// body returns the error in the format of an error response.
+func (e *apiError) body() map[string]interface{} {
+	err := e.cause()
+	err["root_cause"] = []interface{}{e.cause()}
+	return map[string]interface{}{"error": err, "status": e.Status}
+}
+
+func badRequest(typ, format string, args ...interface{}) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Type: typ, Reason: fmt.Sprintf(format, args...)}
+}
+
+func indexNotFound(name string) *apiError {
+	return &apiError{
+		Status: http.StatusNotFound,
+		Type:   "index_not_found_exception",
+		Reason: fmt.Sprintf("no such index [%s]", name),
+		Index:  name,
+	}
+}
+
+// request is a request to the fake cluster.
+type request struct {
+	*http.Request
+	parts []string   // unescaped path segments
+	query url.Values // query string
+	body  []byte     // uncompressed body
+}
+
+// decodeBody decodes the JSON body into a map. An empty body returns
+// an empty map.
+func (r *request) decodeBody() (map[string]interface{}, *apiError) {
+	m := make(map[string]interface{})
+	if len(bytes.TrimSpace(r.body)) == 0 {
+		return m, nil
+	}
+	if err := decodeJSON(r.body, &m); err != nil {
+		return nil, badRequest("parse_exception", "request body is not valid JSON: %v", err)
+	}
+	return m, nil
+}
+
+// decodeJSON decodes JSON, keeping numbers as json.Number.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &request{Request: r, query: r.URL.Query()}
+	for _, part := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(part)
+		if err != nil {
+			unescaped = part
+		}
+		req.parts = append(req.parts, unescaped)
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.writeError(w, r, badRequest("parse_exception", "cannot decompress request body: %v", err))
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		s.writeError(w, r, badRequest("parse_exception", "cannot read request body: %v", err))
+		return
+	}
+	req.body = data
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, res, apiErr := s.route(req)
+	if apiErr != nil {
+		s.writeError(w, r, apiErr)
+		return
+	}
+	s.writeJSON(w, r, status, res)
+}
+
+// route dispatches the request to the handler of the API.
+func (s *Server) route(r *request) (int, interface{}, *apiError) {
+	parts, method := r.parts, r.Method
+	switch {
+	case len(parts) == 0 && (method == "GET" || method == "HEAD"):
+		return http.StatusOK, s.info(), nil
+	case len(parts) >= 2 && parts[0] == "_cluster" && parts[1] == "health" && method == "GET":
+		return s.clusterHealth(r)
+	case len(parts) >= 1 && len(parts) <= 3 && parts[0] == "_nodes" && method == "GET":
+		return http.StatusOK, s.nodesInfo(), nil
+	case len(parts) == 1 && parts[0] == "_bulk" && (method == "POST" || method == "PUT"):
+		return s.bulk(r, "")
+	case len(parts) == 1 && (parts[0] == "_search" || parts[0] == "_count" || parts[0] == "_refresh"):
+		return s.route(&request{Request: r.Request, parts: []string{"_all", parts[0]}, query: r.query, body: r.body})
+	case len(parts) > 0 && strings.HasPrefix(parts[0], "_") && parts[0] != "_all":
+		// Other APIs are not supported
+	case len(parts) == 1:
+		switch method {
+		case "PUT":
+			return s.createIndex(r, parts[0])
+		case "DELETE":
+			return s.deleteIndex(parts[0])
+		case "HEAD":
+			return s.indexExists(parts[0])
+		case "GET":
+			return s.getIndex(parts[0])
+		}
+	case len(parts) == 2:
+		switch {
+		case parts[1] == "_bulk" && (method == "POST" || method == "PUT"):
+			return s.bulk(r, parts[0])
+		case parts[1] == "_search" && (method == "GET" || method == "POST"):
+			return s.search(r, parts[0])
+		case parts[1] == "_count" && (method == "GET" || method == "POST"):
+			return s.count(r, parts[0])
+		case parts[1] == "_refresh" && (method == "GET" || method == "POST"):
+			return s.refresh(parts[0])
+		case parts[1] == "_mapping" && method == "GET":
+			return s.getMapping(parts[0])
+		case parts[1] == "_doc" && method == "POST":
+			return s.indexDocumentRequest(r, parts[0], "", false)
+		}
+	case len(parts) == 3:
+		switch {
+		case parts[1] == "_doc" && (method == "PUT" || method == "POST"):
+			return s.indexDocumentRequest(r, parts[0], parts[2], r.query.Get("op_type") == "create")
+		case parts[1] == "_create" && (method == "PUT" || method == "POST"):
+			return s.indexDocumentRequest(r, parts[0], parts[2], true)
+		case parts[1] == "_doc" && (method == "GET" || method == "HEAD"):
+			return s.getDocument(parts[0], parts[2])
+		case parts[1] == "_source" && method == "GET":
+			return s.getSource(parts[0], parts[2])
+		case parts[1] == "_doc" && method == "DELETE":
+			return s.deleteDocumentRequest(r, parts[0], parts[2])
+		case parts[1] == "_update" && method == "POST":
+			return s.updateDocumentRequest(r, parts[0], parts[2])
+		}
+	}
+	return 0, nil, badRequest("illegal_argument_exception", "no handler found for uri [%s] and method [%s]", r.URL.RequestURI(), method)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		w.Write(data)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err *apiError) {
+	s.writeJSON(w, r, err.Status, err.body())
+}
+
+// info returns the response of the Info API at "/".
+func (s *Server) info() map[string]interface{} {
+	return map[string]interface{}{
+		"name":         NodeName,
+		"cluster_name": ClusterName,
+		"cluster_uuid": nodeId,
+		"version": map[string]interface{}{
+			"distribution":                        "opensearch",
+			"number":                              Version,
+			"build_type":                          "tar",
+			"lucene_version":                      "9.7.0",
+			"minimum_wire_compatibility_version":  "7.10.0",
+			"minimum_index_compatibility_version": "7.0.0",
+		},
+		"tagline": "The OpenSearch Project: https://opensearch.org/",
+	}
+}
+
+// clusterHealth returns the response of the Cluster Health API. The fake
+// cluster is always green.
+func (s *Server) clusterHealth(r *request) (int, interface{}, *apiError) {
+	if len(r.parts) > 2 {
+		for _, name := range strings.Split(r.parts[2], ",") {
+			if _, apiErr := s.resolveIndices(name); apiErr != nil {
+				return 0, nil, apiErr
+			}
+		}
+	}
+	return http.StatusOK, map[string]interface{}{
+		"cluster_name":                     ClusterName,
+		"status":                           "green",
+		"timed_out":                        false,
+		"number_of_nodes":                  1,
+		"number_of_data_nodes":             1,
+		"active_primary_shards":            len(s.indices),
+		"active_shards":                    len(s.indices),
+		"relocating_shards":                0,
+		"initializing_shards":              0,
+		"unassigned_shards":                0,
+		"delayed_unassigned_shards":        0,
+		"number_of_pending_tasks":          0,
+		"number_of_in_flight_fetch":        0,
+		"task_max_waiting_in_queue_millis": 0,
+		"active_shards_percent_as_number":  100.0,
+	}, nil
+}
+
+// nodesInfo returns the response of the Nodes Info API, e.g. at
+// "/_nodes/http", which the client uses for sniffing.
+func (s *Server) nodesInfo() map[string]interface{} {
+	addr := s.Listener.Addr().String()
+	return map[string]interface{}{
+		"_nodes":       map[string]interface{}{"total": 1, "successful": 1, "failed": 0},
+		"cluster_name": ClusterName,
+		"nodes": map[string]interface{}{
+			nodeId: map[string]interface{}{
+				"name":              NodeName,
+				"transport_address": "127.0.0.1:9300",
+				"host":              "127.0.0.1",
+				"ip":                "127.0.0.1",
+				"version":           Version,
+				"roles":             []string{"cluster_manager", "data", "ingest"},
+				"http": map[string]interface{}{
+					"bound_address":               []string{addr},
+					"publish_address":             addr,
+					"max_content_length_in_bytes": 104857600,
+				},
+			},
+		},
+	}
+}
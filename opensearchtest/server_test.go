@@ -0,0 +1,512 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearchtest
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/disaster37/opensearch/v2"
+)
+
+type tweet struct {
+	User     string    `json:"user"`
+	Message  string    `json:"message"`
+	Retweets int       `json:"retweets"`
+	Created  time.Time `json:"created"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+var tweets = map[string]tweet{
+	"1": {User: "olivere", Message: "Welcome to Golang and Opensearch.", Retweets: 108, Created: time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC), Tags: []string{"golang", "opensearch"}},
+	"2": {User: "olivere", Message: "Another unrelated topic.", Retweets: 0, Created: time.Date(2024, 1, 16, 9, 30, 0, 0, time.UTC), Tags: []string{"golang"}},
+	"3": {User: "sandrae", Message: "Cycling is fun.", Retweets: 12, Created: time.Date(2024, 1, 18, 17, 45, 0, 0, time.UTC), Tags: []string{"sports", "cycling"}},
+}
+
+// setup starts a server with the tweets indexed, and returns a client
+// with sniffing and healthchecks enabled.
+func setup(t *testing.T) (*Server, *opensearch.Client) {
+	t.Helper()
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bulk := client.Bulk().Index("tweets")
+	for id, tweet := range tweets {
+		bulk.Add(opensearch.NewBulkIndexRequest().Id(id).Doc(tweet))
+	}
+	res, err := bulk.Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors {
+		t.Fatalf("expected no bulk errors; got: %+v", res.Failed())
+	}
+	return server, client
+}
+
+func searchIds(t *testing.T, res *opensearch.SearchResult) []string {
+	t.Helper()
+	var ids []string
+	for _, hit := range res.Hits.Hits {
+		ids = append(ids, hit.Id)
+	}
+	return ids
+}
+
+func TestServerClusterAPIs(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	client, err := server.Client(opensearch.SetHealthcheckInterval(10 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := client.OpensearchVersion(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := Version, version; want != have {
+		t.Fatalf("expected version %q; got: %q", want, have)
+	}
+	health, err := client.ClusterHealth().WaitForGreenStatus().Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "green", health.Status; want != have {
+		t.Fatalf("expected status %q; got: %q", want, have)
+	}
+	nodes, err := client.NodesInfo().Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(nodes.Nodes); want != have {
+		t.Fatalf("expected %d node; got: %d", want, have)
+	}
+
+	// Healthchecks keep the sniffed node alive
+	time.Sleep(50 * time.Millisecond)
+	if _, err := client.ClusterHealth().Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerDocuments(t *testing.T) {
+	_, client := setup(t)
+	ctx := context.Background()
+
+	doc, err := client.Get().Index("tweets").Id("1").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tw tweet
+	if err := json.Unmarshal(doc.Source, &tw); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := tweets["1"].Message, tw.Message; want != have {
+		t.Fatalf("expected message %q; got: %q", want, have)
+	}
+
+	// Partial update
+	upd, err := client.Update().Index("tweets").Id("1").Doc(map[string]interface{}{"retweets": 109}).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "updated", upd.Result; want != have {
+		t.Fatalf("expected result %q; got: %q", want, have)
+	}
+	if want, have := int64(2), upd.Version; want != have {
+		t.Fatalf("expected version %d; got: %d", want, have)
+	}
+	upd, err = client.Update().Index("tweets").Id("1").Doc(map[string]interface{}{"retweets": 109}).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "noop", upd.Result; want != have {
+		t.Fatalf("expected result %q; got: %q", want, have)
+	}
+
+	// Optimistic concurrency control
+	doc, err = client.Get().Index("tweets").Id("1").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Index().Index("tweets").Id("1").IfSeqNo(*doc.SeqNo - 1).IfPrimaryTerm(1).BodyJson(tweets["1"]).Do(ctx)
+	if !opensearch.IsConflict(err) {
+		t.Fatalf("expected conflict; got: %v", err)
+	}
+	_, err = client.Index().Index("tweets").Id("1").OpType("create").BodyJson(tweets["1"]).Do(ctx)
+	if !opensearch.IsConflict(err) {
+		t.Fatalf("expected conflict; got: %v", err)
+	}
+
+	// Delete
+	del, err := client.Delete().Index("tweets").Id("1").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "deleted", del.Result; want != have {
+		t.Fatalf("expected result %q; got: %q", want, have)
+	}
+	if _, err := client.Get().Index("tweets").Id("1").Do(ctx); !opensearch.IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+	if _, err := client.Delete().Index("tweets").Id("1").Do(ctx); !opensearch.IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+	if _, err := client.Get().Index("missing").Id("1").Do(ctx); !opensearch.IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+
+	// Auto-generated id
+	idx, err := client.Index().Index("tweets").BodyJson(tweets["2"]).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Id == "" || idx.Result != "created" {
+		t.Fatalf("expected a created document with an id; got: %+v", idx)
+	}
+}
+
+func TestServerIndices(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client, err := server.Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	mapping := `{"mappings":{"properties":{"user":{"type":"keyword"},"message":{"type":"text"}}}}`
+	if _, err := client.CreateIndex("tweets").Body(mapping).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateIndex("tweets").Body(mapping).Do(ctx); err == nil {
+		t.Fatal("expected an error creating an existing index")
+	}
+	exists, err := client.IndexExists("tweets").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("expected index to exist")
+	}
+
+	// Explicit mappings are used, dynamic mappings added
+	if _, err := client.Index().Index("tweets").Id("1").BodyJson(tweets["1"]).Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.GetMapping().Index("tweets").Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(res["tweets"])
+	want := `{"mappings":{"properties":{` +
+		`"created":{"type":"date"},` +
+		`"message":{"type":"text"},` +
+		`"retweets":{"type":"long"},` +
+		`"tags":{"fields":{"keyword":{"ignore_above":256,"type":"keyword"}},"type":"text"},` +
+		`"user":{"type":"keyword"}}}}`
+	if have := string(data); want != have {
+		t.Fatalf("expected mapping\n%s\ngot:\n%s", want, have)
+	}
+
+	// Term query on the keyword field matches the exact value
+	count, err := client.Count("tweets").Query(opensearch.NewTermQuery("user", "olivere")).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(1), count; want != have {
+		t.Fatalf("expected %d documents; got: %d", want, have)
+	}
+
+	if _, err := client.DeleteIndex("tweets").Do(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteIndex("tweets").Do(ctx); !opensearch.IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+	if _, err := client.Search("tweets").Do(ctx); !opensearch.IsNotFound(err) {
+		t.Fatalf("expected not found; got: %v", err)
+	}
+}
+
+func TestServerBulk(t *testing.T) {
+	_, client := setup(t)
+
+	res, err := client.Bulk().Index("tweets").Add(
+		opensearch.NewBulkCreateRequest().Id("1").Doc(tweets["1"]),
+		opensearch.NewBulkUpdateRequest().Id("2").Doc(map[string]interface{}{"retweets": 1}),
+		opensearch.NewBulkUpdateRequest().Id("4").Doc(map[string]interface{}{"retweets": 1}),
+		opensearch.NewBulkUpdateRequest().Id("5").Doc(tweets["1"]).DocAsUpsert(true),
+		opensearch.NewBulkDeleteRequest().Id("3"),
+		opensearch.NewBulkDeleteRequest().Id("6"),
+	).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Errors {
+		t.Fatal("expected errors")
+	}
+	want := []struct {
+		Status int
+		Result string
+		Error  string
+	}{
+		{409, "", "version_conflict_engine_exception"},
+		{200, "updated", ""},
+		{404, "", "document_missing_exception"},
+		{201, "created", ""},
+		{200, "deleted", ""},
+		{404, "not_found", ""},
+	}
+	if len(res.Items) != len(want) {
+		t.Fatalf("expected %d items; got: %d", len(want), len(res.Items))
+	}
+	for i, item := range res.Items {
+		for _, have := range item {
+			if have.Status != want[i].Status || have.Result != want[i].Result {
+				t.Errorf("item %d: expected status %d and result %q; got: %d and %q", i, want[i].Status, want[i].Result, have.Status, have.Result)
+			}
+			var errType string
+			if have.Error != nil {
+				errType = have.Error.Type
+			}
+			if errType != want[i].Error {
+				t.Errorf("item %d: expected error %q; got: %q", i, want[i].Error, errType)
+			}
+		}
+	}
+}
+
+func TestServerGzip(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	client, err := server.Client(opensearch.SetGzip(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.Bulk().Add(opensearch.NewBulkIndexRequest().Index("tweets").Id("1").Doc(tweets["1"])).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Errors {
+		t.Fatalf("expected no bulk errors; got: %+v", res.Failed())
+	}
+}
+
+func TestServerQueries(t *testing.T) {
+	server, client := setup(t)
+	server.now = func() time.Time { return time.Date(2024, 1, 18, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		Name  string
+		Query opensearch.Query
+		Ids   []string
+	}{
+		{"match_all", opensearch.NewMatchAllQuery(), []string{"1", "2", "3"}},
+		{"term on text", opensearch.NewTermQuery("user", "olivere"), []string{"1", "2"}},
+		{"term is not analyzed", opensearch.NewTermQuery("message", "Cycling"), nil},
+		{"term on keyword", opensearch.NewTermQuery("tags.keyword", "golang"), []string{"1", "2"}},
+		{"term on number", opensearch.NewTermQuery("retweets", 12), []string{"3"}},
+		{"terms", opensearch.NewTermsQuery("tags.keyword", "sports", "opensearch"), []string{"1", "3"}},
+		{"match", opensearch.NewMatchQuery("message", "golang cycling"), []string{"1", "3"}},
+		{"match with and", opensearch.NewMatchQuery("message", "golang cycling").Operator("and"), nil},
+		{"range on number", opensearch.NewRangeQuery("retweets").Gt(0).Lte(12), []string{"3"}},
+		{"range on date", opensearch.NewRangeQuery("created").Gte("2024-01-16"), []string{"2", "3"}},
+		{"range with date math", opensearch.NewRangeQuery("created").Gte("now-2d/d"), []string{"2", "3"}},
+		{"exists", opensearch.NewExistsQuery("tags"), []string{"1", "2", "3"}},
+		{"ids", opensearch.NewIdsQuery().Ids("3", "4"), []string{"3"}},
+		{"bool", opensearch.NewBoolQuery().
+			Must(opensearch.NewMatchQuery("user", "olivere")).
+			MustNot(opensearch.NewTermQuery("retweets", 0)), []string{"1"}},
+		{"bool with should", opensearch.NewBoolQuery().
+			Should(opensearch.NewTermQuery("user", "sandrae"), opensearch.NewRangeQuery("retweets").Gte(100)), []string{"1", "3"}},
+		{"bool with filter and should", opensearch.NewBoolQuery().
+			Filter(opensearch.NewTermQuery("tags.keyword", "golang")).
+			Should(opensearch.NewTermQuery("retweets", 0)), []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		res, err := client.Search("tweets").Query(tt.Query).Do(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", tt.Name, err)
+		}
+		ids := searchIds(t, res)
+		sort.Strings(ids)
+		if want, have := len(tt.Ids), int(res.TotalHits()); want != have {
+			t.Errorf("%s: expected %d hits; got: %d", tt.Name, want, have)
+		}
+		if want, have := tt.Ids, ids; len(want) != len(have) || (len(want) > 0 && !equal(want, have)) {
+			t.Errorf("%s: expected hits %v; got: %v", tt.Name, want, have)
+		}
+	}
+
+	// Unsupported queries fail
+	_, err := client.Search("tweets").Query(opensearch.NewFuzzyQuery("user", "olivere")).Do(context.Background())
+	if !opensearch.IsStatusCode(err, 400) {
+		t.Fatalf("expected status 400; got: %v", err)
+	}
+}
+
+func equal(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestServerSortAndPaging(t *testing.T) {
+	_, client := setup(t)
+	ctx := context.Background()
+
+	res, err := client.Search("tweets").Sort("retweets", false).From(1).Size(1).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"3"}, searchIds(t, res); !equal(want, have) || len(have) != 1 {
+		t.Fatalf("expected hits %v; got: %v", want, have)
+	}
+	if want, have := int64(3), res.TotalHits(); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+
+	// search_after
+	res, err = client.Search("tweets").Sort("created", true).Size(2).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"1", "2"}, searchIds(t, res); !equal(want, have) || len(have) != 2 {
+		t.Fatalf("expected hits %v; got: %v", want, have)
+	}
+	res, err = client.Search("tweets").Sort("created", true).SearchAfter(res.Hits.Hits[1].Sort...).Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"3"}, searchIds(t, res); !equal(want, have) || len(have) != 1 {
+		t.Fatalf("expected hits %v; got: %v", want, have)
+	}
+
+	// Sorting by a text field fails
+	_, err = client.Search("tweets").Sort("message", true).Do(ctx)
+	if !opensearch.IsStatusCode(err, 400) {
+		t.Fatalf("expected status 400; got: %v", err)
+	}
+
+	// Source filtering
+	res, err = client.Search("tweets").
+		Query(opensearch.NewIdsQuery().Ids("1")).
+		FetchSourceContext(opensearch.NewFetchSourceContext(true).Include("user", "tag*")).
+		Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := `{"tags":["golang","opensearch"],"user":"olivere"}`, string(res.Hits.Hits[0].Source); want != have {
+		t.Fatalf("expected source %s; got: %s", want, have)
+	}
+}
+
+func TestServerAggregations(t *testing.T) {
+	_, client := setup(t)
+	ctx := context.Background()
+
+	res, err := client.Search("tweets").
+		Size(0).
+		Aggregation("users", opensearch.NewTermsAggregation().Field("user.keyword").
+			SubAggregation("retweets", opensearch.NewSumAggregation().Field("retweets"))).
+		Aggregation("tags", opensearch.NewTermsAggregation().Field("tags.keyword").Size(1)).
+		Aggregation("days", opensearch.NewDateHistogramAggregation().Field("created").CalendarInterval("day").
+			SubAggregation("max_retweets", opensearch.NewMaxAggregation().Field("retweets"))).
+		Do(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, len(res.Hits.Hits); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+
+	users, found := res.Aggregations.Terms("users")
+	if !found {
+		t.Fatal("expected users aggregation")
+	}
+	if want, have := 2, len(users.Buckets); want != have {
+		t.Fatalf("expected %d buckets; got: %d", want, have)
+	}
+	if want, have := "olivere", users.Buckets[0].Key; want != have {
+		t.Fatalf("expected key %v; got: %v", want, have)
+	}
+	if want, have := int64(2), users.Buckets[0].DocCount; want != have {
+		t.Fatalf("expected doc count %d; got: %d", want, have)
+	}
+	sum, found := users.Buckets[0].Sum("retweets")
+	if !found || sum.Value == nil || *sum.Value != 108 {
+		t.Fatalf("expected sum of retweets of 108; got: %+v", sum)
+	}
+
+	tags, found := res.Aggregations.Terms("tags")
+	if !found {
+		t.Fatal("expected tags aggregation")
+	}
+	if want, have := 1, len(tags.Buckets); want != have {
+		t.Fatalf("expected %d bucket; got: %d", want, have)
+	}
+	if want, have := "golang", tags.Buckets[0].Key; want != have {
+		t.Fatalf("expected key %v; got: %v", want, have)
+	}
+	if want, have := int64(3), tags.SumOfOtherDocCount; want != have {
+		t.Fatalf("expected sum of other doc count %d; got: %d", want, have)
+	}
+
+	days, found := res.Aggregations.DateHistogram("days")
+	if !found {
+		t.Fatal("expected days aggregation")
+	}
+	wantDays := []struct {
+		Key      string
+		DocCount int64
+	}{
+		{"2024-01-15T00:00:00.000Z", 1},
+		{"2024-01-16T00:00:00.000Z", 1},
+		{"2024-01-17T00:00:00.000Z", 0},
+		{"2024-01-18T00:00:00.000Z", 1},
+	}
+	if want, have := len(wantDays), len(days.Buckets); want != have {
+		t.Fatalf("expected %d buckets; got: %d", want, have)
+	}
+	for i, b := range days.Buckets {
+		if b.KeyAsString == nil || *b.KeyAsString != wantDays[i].Key || b.DocCount != wantDays[i].DocCount {
+			t.Errorf("bucket %d: expected %s with %d documents; got: %v with %d", i, wantDays[i].Key, wantDays[i].DocCount, b.KeyAsString, b.DocCount)
+		}
+	}
+	max, found := days.Buckets[3].Max("max_retweets")
+	if !found || max.Value == nil || *max.Value != 12 {
+		t.Fatalf("expected max retweets of 12; got: %+v", max)
+	}
+
+	// Aggregating a text field fails
+	_, err = client.Search("tweets").Aggregation("users", opensearch.NewTermsAggregation().Field("user")).Do(ctx)
+	if !opensearch.IsStatusCode(err, 400) {
+		t.Fatalf("expected status 400; got: %v", err)
+	}
+}
+
+func TestServerReset(t *testing.T) {
+	server, client := setup(t)
+	server.Reset()
+	exists, err := client.IndexExists("tweets").Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("expected index to be deleted")
+	}
+}
@@ -124,6 +124,7 @@ func (s *PingService) Do(ctx context.Context) (*PingResult, int, error) {
 	basicAuth := s.client.basicAuthUsername != "" || s.client.basicAuthPassword != ""
 	basicAuthUsername := s.client.basicAuthUsername
 	basicAuthPassword := s.client.basicAuthPassword
+	authProvider := s.client.authProvider
 	defaultHeaders := s.client.headers
 	s.client.mu.RUnlock()
 
@@ -174,7 +175,11 @@ func (s *PingService) Do(ctx context.Context) (*PingResult, int, error) {
 		}
 	}
 
-	if basicAuth {
+	if authProvider != nil {
+		if err := authProvider.Authenticate(ctx, (*http.Request)(req)); err != nil {
+			return nil, 0, err
+		}
+	} else if basicAuth {
 		req.SetBasicAuth(basicAuthUsername, basicAuthPassword)
 	}
 
@@ -0,0 +1,183 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ClosePointInTimeService closes one or more points in time by their ids,
+// or all points in time of the cluster.
+//
+// See https://opensearch.org/docs/latest/search-plugins/point-in-time-api/#delete-pits
+// for details.
+type ClosePointInTimeService struct {
+	client *Client
+
+	pretty     *bool       // pretty format the returned JSON response
+	human      *bool       // return human readable values for statistics
+	errorTrace *bool       // include the stack trace of returned errors
+	filterPath []string    // list of filters used to reduce the response
+	headers    http.Header // custom request-level HTTP headers
+
+	id  []string
+	all bool
+}
+
+// NewClosePointInTimeService creates a new ClosePointInTimeService.
+func NewClosePointInTimeService(client *Client) *ClosePointInTimeService {
+	return &ClosePointInTimeService{
+		client: client,
+	}
+}
+
+// Pretty tells Opensearch whether to return a formatted JSON response.
+func (s *ClosePointInTimeService) Pretty(pretty bool) *ClosePointInTimeService {
+	s.pretty = &pretty
+	return s
+}
+
+// Human specifies whether human readable values should be returned in
+// the JSON response, e.g. "7.5mb".
+func (s *ClosePointInTimeService) Human(human bool) *ClosePointInTimeService {
+	s.human = &human
+	return s
+}
+
+// ErrorTrace specifies whether to include the stack trace of returned errors.
+func (s *ClosePointInTimeService) ErrorTrace(errorTrace bool) *ClosePointInTimeService {
+	s.errorTrace = &errorTrace
+	return s
+}
+
+// FilterPath specifies a list of filters used to reduce the response.
+func (s *ClosePointInTimeService) FilterPath(filterPath ...string) *ClosePointInTimeService {
+	s.filterPath = filterPath
+	return s
+}
+
+// Header adds a header to the request.
+func (s *ClosePointInTimeService) Header(name string, value string) *ClosePointInTimeService {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(name, value)
+	return s
+}
+
+// Headers specifies the headers of the request.
+func (s *ClosePointInTimeService) Headers(headers http.Header) *ClosePointInTimeService {
+	s.headers = headers
+	return s
+}
+
+// Id adds one or more point in time ids to close. Pass SearchResult.PitId
+// of the last search to close the most recent point in time.
+func (s *ClosePointInTimeService) Id(ids ...string) *ClosePointInTimeService {
+	s.id = append(s.id, ids...)
+	return s
+}
+
+// All indicates to close all points in time of the cluster.
+func (s *ClosePointInTimeService) All() *ClosePointInTimeService {
+	s.all = true
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *ClosePointInTimeService) buildURL() (string, url.Values, error) {
+	// Build URL
+	path := "/_search/point_in_time"
+	if s.all {
+		path = "/_search/point_in_time/_all"
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if v := s.pretty; v != nil {
+		params.Set("pretty", fmt.Sprint(*v))
+	}
+	if v := s.human; v != nil {
+		params.Set("human", fmt.Sprint(*v))
+	}
+	if v := s.errorTrace; v != nil {
+		params.Set("error_trace", fmt.Sprint(*v))
+	}
+	if len(s.filterPath) > 0 {
+		params.Set("filter_path", strings.Join(s.filterPath, ","))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *ClosePointInTimeService) Validate() error {
+	var invalid []string
+	if !s.all && len(s.id) == 0 {
+		invalid = append(invalid, "Id")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// Do executes the operation.
+func (s *ClosePointInTimeService) Do(ctx context.Context) (*ClosePointInTimeResponse, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.client.checkCapability(ctx, CapabilityPointInTime); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup HTTP request body
+	var body interface{}
+	if !s.all {
+		body = map[string][]string{
+			"pit_id": s.id,
+		}
+	}
+
+	// Get HTTP response
+	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
+		Method:  "DELETE",
+		Path:    path,
+		Params:  params,
+		Body:    body,
+		Headers: s.headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(ClosePointInTimeResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ClosePointInTimeResponse is the result of closing points in time.
+type ClosePointInTimeResponse struct {
+	Pits []*ClosePointInTimeResult `json:"pits,omitempty"`
+}
+
+// ClosePointInTimeResult is the outcome of closing a single point in time.
+type ClosePointInTimeResult struct {
+	PitId      string `json:"pit_id"`
+	Successful bool   `json:"successful"`
+}
@@ -0,0 +1,91 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClosePointInTimeBuildURL(t *testing.T) {
+	client := setupTestClient(t)
+
+	tests := []struct {
+		Ids          []string
+		All          bool
+		ExpectedPath string
+		ExpectErr    bool
+	}{
+		{
+			nil,
+			false,
+			"",
+			true,
+		},
+		{
+			[]string{"pit1", "pit2"},
+			false,
+			"/_search/point_in_time",
+			false,
+		},
+		{
+			nil,
+			true,
+			"/_search/point_in_time/_all",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		builder := client.ClosePointInTime(test.Ids...)
+		if test.All {
+			builder = builder.All()
+		}
+		err := builder.Validate()
+		if err != nil {
+			if !test.ExpectErr {
+				t.Errorf("case #%d: %v", i+1, err)
+				continue
+			}
+		} else {
+			// err == nil
+			if test.ExpectErr {
+				t.Errorf("case #%d: expected error", i+1)
+				continue
+			}
+			path, _, _ := builder.buildURL()
+			if path != test.ExpectedPath {
+				t.Errorf("case #%d: expected %q; got: %q", i+1, test.ExpectedPath, path)
+			}
+		}
+	}
+}
+
+func TestClosePointInTimeAll(t *testing.T) {
+	client := setupTestClientAndCreateIndexAndAddDocs(t)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.OpenPointInTime(testIndexName).KeepAlive("1m").Do(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := client.ClosePointInTime().All().Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Pits) < 2 {
+		t.Fatalf("expected at least 2 closed Points in Time; got %d", len(res.Pits))
+	}
+
+	list, err := client.ListPointInTime().Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 0, len(list.Pits); want != have {
+		t.Fatalf("Pits: want %d, have %d", want, have)
+	}
+}
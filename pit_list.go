@@ -0,0 +1,148 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ListPointInTimeService lists all points in time of the cluster.
+//
+// See https://opensearch.org/docs/latest/search-plugins/point-in-time-api/#list-all-pits
+// for details.
+type ListPointInTimeService struct {
+	client *Client
+
+	pretty     *bool       // pretty format the returned JSON response
+	human      *bool       // return human readable values for statistics
+	errorTrace *bool       // include the stack trace of returned errors
+	filterPath []string    // list of filters used to reduce the response
+	headers    http.Header // custom request-level HTTP headers
+}
+
+// NewListPointInTimeService creates a new ListPointInTimeService.
+func NewListPointInTimeService(client *Client) *ListPointInTimeService {
+	return &ListPointInTimeService{
+		client: client,
+	}
+}
+
+// Pretty tells Opensearch whether to return a formatted JSON response.
+func (s *ListPointInTimeService) Pretty(pretty bool) *ListPointInTimeService {
+	s.pretty = &pretty
+	return s
+}
+
+// Human specifies whether human readable values should be returned in
+// the JSON response, e.g. "7.5mb".
+func (s *ListPointInTimeService) Human(human bool) *ListPointInTimeService {
+	s.human = &human
+	return s
+}
+
+// ErrorTrace specifies whether to include the stack trace of returned errors.
+func (s *ListPointInTimeService) ErrorTrace(errorTrace bool) *ListPointInTimeService {
+	s.errorTrace = &errorTrace
+	return s
+}
+
+// FilterPath specifies a list of filters used to reduce the response.
+func (s *ListPointInTimeService) FilterPath(filterPath ...string) *ListPointInTimeService {
+	s.filterPath = filterPath
+	return s
+}
+
+// Header adds a header to the request.
+func (s *ListPointInTimeService) Header(name string, value string) *ListPointInTimeService {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(name, value)
+	return s
+}
+
+// Headers specifies the headers of the request.
+func (s *ListPointInTimeService) Headers(headers http.Header) *ListPointInTimeService {
+	s.headers = headers
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *ListPointInTimeService) buildURL() (string, url.Values, error) {
+	// Build URL
+	path := "/_search/point_in_time/_all"
+
+	// Add query string parameters
+	params := url.Values{}
+	if v := s.pretty; v != nil {
+		params.Set("pretty", fmt.Sprint(*v))
+	}
+	if v := s.human; v != nil {
+		params.Set("human", fmt.Sprint(*v))
+	}
+	if v := s.errorTrace; v != nil {
+		params.Set("error_trace", fmt.Sprint(*v))
+	}
+	if len(s.filterPath) > 0 {
+		params.Set("filter_path", strings.Join(s.filterPath, ","))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *ListPointInTimeService) Validate() error {
+	return nil
+}
+
+// Do executes the operation.
+func (s *ListPointInTimeService) Do(ctx context.Context) (*ListPointInTimeResponse, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.client.checkCapability(ctx, CapabilityPointInTime); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get HTTP response
+	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
+		Method:  "GET",
+		Path:    path,
+		Params:  params,
+		Headers: s.headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(ListPointInTimeResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// ListPointInTimeResponse is the result of listing all points in time.
+type ListPointInTimeResponse struct {
+	Pits []*PointInTimeInfo `json:"pits,omitempty"`
+}
+
+// PointInTimeInfo describes a point in time that is open in the cluster.
+type PointInTimeInfo struct {
+	PitId        string `json:"pit_id"`
+	CreationTime int64  `json:"creation_time,omitempty"` // in milliseconds since epoch
+	KeepAlive    int64  `json:"keep_alive,omitempty"`    // in milliseconds
+}
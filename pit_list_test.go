@@ -0,0 +1,25 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"testing"
+)
+
+func TestListPointInTimeBuildURL(t *testing.T) {
+	client := setupTestClient(t)
+
+	builder := client.ListPointInTime()
+	if err := builder.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	path, _, err := builder.buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "/_search/point_in_time/_all", path; want != have {
+		t.Fatalf("expected %q; got: %q", want, have)
+	}
+}
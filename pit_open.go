@@ -0,0 +1,231 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/disaster37/opensearch/v2/uritemplates"
+)
+
+// OpenPointInTimeService opens a point in time that can be used in subsequent
+// searches.
+//
+// See https://opensearch.org/docs/latest/search-plugins/point-in-time-api/#create-a-pit
+// for details.
+type OpenPointInTimeService struct {
+	client *Client
+
+	pretty     *bool       // pretty format the returned JSON response
+	human      *bool       // return human readable values for statistics
+	errorTrace *bool       // include the stack trace of returned errors
+	filterPath []string    // list of filters used to reduce the response
+	headers    http.Header // custom request-level HTTP headers
+
+	index                   []string
+	preference              string
+	routing                 string
+	expandWildcards         string
+	keepAlive               string
+	allowPartialPitCreation *bool
+}
+
+// NewOpenPointInTimeService creates a new OpenPointInTimeService.
+func NewOpenPointInTimeService(client *Client) *OpenPointInTimeService {
+	return &OpenPointInTimeService{
+		client: client,
+	}
+}
+
+// Pretty tells Opensearch whether to return a formatted JSON response.
+func (s *OpenPointInTimeService) Pretty(pretty bool) *OpenPointInTimeService {
+	s.pretty = &pretty
+	return s
+}
+
+// Human specifies whether human readable values should be returned in
+// the JSON response, e.g. "7.5mb".
+func (s *OpenPointInTimeService) Human(human bool) *OpenPointInTimeService {
+	s.human = &human
+	return s
+}
+
+// ErrorTrace specifies whether to include the stack trace of returned errors.
+func (s *OpenPointInTimeService) ErrorTrace(errorTrace bool) *OpenPointInTimeService {
+	s.errorTrace = &errorTrace
+	return s
+}
+
+// FilterPath specifies a list of filters used to reduce the response.
+func (s *OpenPointInTimeService) FilterPath(filterPath ...string) *OpenPointInTimeService {
+	s.filterPath = filterPath
+	return s
+}
+
+// Header adds a header to the request.
+func (s *OpenPointInTimeService) Header(name string, value string) *OpenPointInTimeService {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(name, value)
+	return s
+}
+
+// Headers specifies the headers of the request.
+func (s *OpenPointInTimeService) Headers(headers http.Header) *OpenPointInTimeService {
+	s.headers = headers
+	return s
+}
+
+// Index is a list of index names to open the point in time.
+func (s *OpenPointInTimeService) Index(index ...string) *OpenPointInTimeService {
+	s.index = append(s.index, index...)
+	return s
+}
+
+// Preference specifies the node or shard the operation should be performed on.
+func (s *OpenPointInTimeService) Preference(preference string) *OpenPointInTimeService {
+	s.preference = preference
+	return s
+}
+
+// Routing is a specific routing value.
+func (s *OpenPointInTimeService) Routing(routing string) *OpenPointInTimeService {
+	s.routing = routing
+	return s
+}
+
+// ExpandWildcards indicates whether to expand wildcard expression to
+// concrete indices that are open, closed or both.
+func (s *OpenPointInTimeService) ExpandWildcards(expandWildcards string) *OpenPointInTimeService {
+	s.expandWildcards = expandWildcards
+	return s
+}
+
+// KeepAlive is the time for which the point in time will be kept alive,
+// e.g. "1m" or "2h".
+func (s *OpenPointInTimeService) KeepAlive(keepAlive string) *OpenPointInTimeService {
+	s.keepAlive = keepAlive
+	return s
+}
+
+// AllowPartialPitCreation specifies whether to create a point in time
+// when some shards are unavailable.
+func (s *OpenPointInTimeService) AllowPartialPitCreation(allow bool) *OpenPointInTimeService {
+	s.allowPartialPitCreation = &allow
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *OpenPointInTimeService) buildURL() (string, url.Values, error) {
+	// Build URL
+	path, err := uritemplates.Expand("/{index}/_search/point_in_time", map[string]string{
+		"index": strings.Join(s.index, ","),
+	})
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if v := s.pretty; v != nil {
+		params.Set("pretty", fmt.Sprint(*v))
+	}
+	if v := s.human; v != nil {
+		params.Set("human", fmt.Sprint(*v))
+	}
+	if v := s.errorTrace; v != nil {
+		params.Set("error_trace", fmt.Sprint(*v))
+	}
+	if len(s.filterPath) > 0 {
+		params.Set("filter_path", strings.Join(s.filterPath, ","))
+	}
+	if s.preference != "" {
+		params.Set("preference", s.preference)
+	}
+	if s.routing != "" {
+		params.Set("routing", s.routing)
+	}
+	if s.expandWildcards != "" {
+		params.Set("expand_wildcards", s.expandWildcards)
+	}
+	if s.keepAlive != "" {
+		params.Set("keep_alive", s.keepAlive)
+	}
+	if v := s.allowPartialPitCreation; v != nil {
+		params.Set("allow_partial_pit_creation", fmt.Sprint(*v))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *OpenPointInTimeService) Validate() error {
+	var invalid []string
+	if len(s.index) == 0 {
+		invalid = append(invalid, "Index")
+	}
+	if s.keepAlive == "" {
+		invalid = append(invalid, "KeepAlive")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// Do executes the operation.
+func (s *OpenPointInTimeService) Do(ctx context.Context) (*OpenPointInTimeResponse, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.client.checkCapability(ctx, CapabilityPointInTime); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get HTTP response
+	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
+		Method:  "POST",
+		Path:    path,
+		Params:  params,
+		Headers: s.headers,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(OpenPointInTimeResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	ret.keepAlive = s.keepAlive
+	return ret, nil
+}
+
+// OpenPointInTimeResponse is the result of calling OpenPointInTimeService.Do.
+type OpenPointInTimeResponse struct {
+	Id           string      `json:"pit_id"`
+	Shards       *ShardsInfo `json:"_shards,omitempty"`
+	CreationTime int64       `json:"creation_time,omitempty"`
+
+	keepAlive string // keep alive used to open the point in time
+}
+
+// PointInTime returns a PointInTime for use in SearchSource, reusing the
+// keep alive that the point in time has been opened with.
+func (r *OpenPointInTimeResponse) PointInTime() *PointInTime {
+	return NewPointInTimeWithKeepAlive(r.Id, r.keepAlive)
+}
@@ -0,0 +1,129 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenPointInTimeBuildURL(t *testing.T) {
+	client := setupTestClient(t)
+
+	tests := []struct {
+		Indices      []string
+		KeepAlive    string
+		ExpectedPath string
+		ExpectErr    bool
+	}{
+		{
+			[]string{},
+			"1m",
+			"",
+			true,
+		},
+		{
+			[]string{"index1"},
+			"",
+			"",
+			true,
+		},
+		{
+			[]string{"index1"},
+			"1m",
+			"/index1/_search/point_in_time",
+			false,
+		},
+		{
+			[]string{"index1", "index2"},
+			"1m",
+			"/index1%2Cindex2/_search/point_in_time",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		builder := client.OpenPointInTime(test.Indices...).KeepAlive(test.KeepAlive)
+		err := builder.Validate()
+		if err != nil {
+			if !test.ExpectErr {
+				t.Errorf("case #%d: %v", i+1, err)
+				continue
+			}
+		} else {
+			// err == nil
+			if test.ExpectErr {
+				t.Errorf("case #%d: expected error", i+1)
+				continue
+			}
+			path, params, _ := builder.buildURL()
+			if path != test.ExpectedPath {
+				t.Errorf("case #%d: expected %q; got: %q", i+1, test.ExpectedPath, path)
+			}
+			if got, want := params.Get("keep_alive"), test.KeepAlive; got != want {
+				t.Errorf("case #%d: expected keep_alive=%q; got: %q", i+1, want, got)
+			}
+		}
+	}
+}
+
+func TestPointInTimeOpenAndClose(t *testing.T) {
+	client := setupTestClientAndCreateIndexAndAddDocs(t)
+
+	// Open a Point in Time
+	pit, err := client.OpenPointInTime(testIndexName).KeepAlive("1m").Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pit == nil {
+		t.Fatal("expected non-nil Point in Time")
+	}
+	if pit.Id == "" {
+		t.Fatal("expected non-blank Point in Time ID")
+	}
+
+	// Search using the Point in Time
+	res, err := client.Search().
+		Query(NewMatchAllQuery()).
+		PointInTime(pit.PointInTime()).
+		Size(100).
+		Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := int64(3), res.TotalHits(); want != have {
+		t.Fatalf("TotalHits: want %d, have %d", want, have)
+	}
+	if res.PitId == "" {
+		t.Fatal("expected non-blank Point in Time ID in search result")
+	}
+
+	// The Point in Time must be listed
+	list, err := client.ListPointInTime().Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, p := range list.Pits {
+		if p.PitId == res.PitId {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected Point in Time %q to be listed", res.PitId)
+	}
+
+	// Close the Point in Time
+	closed, err := client.ClosePointInTime(res.PitId).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 1, len(closed.Pits); want != have {
+		t.Fatalf("Pits: want %d, have %d", want, have)
+	}
+	if !closed.Pits[0].Successful {
+		t.Fatal("expected Point in Time to be closed successfully")
+	}
+}
@@ -14,11 +14,10 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/olivere/env"
-	"github.com/olivere/opensearch"
 
-	aws "github.com/disaster37/opensearch/v2/aws/v4"
+	"github.com/disaster37/opensearch/v2"
+	"github.com/disaster37/opensearch/v2/aws"
 )
 
 func main() {
@@ -45,7 +44,7 @@ func main() {
 		log.Fatal("please specify an AWS region with -region")
 	}
 
-	signingClient := aws.NewV4SigningClient(credentials.NewStaticCredentials(
+	signingClient := aws.NewV4SigningClient(aws.NewStaticCredentialsProvider(
 		*accessKey,
 		*secretKey,
 		"",
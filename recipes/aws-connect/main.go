@@ -15,9 +15,8 @@ import (
 	"log"
 
 	"github.com/olivere/env"
-	"github.com/olivere/opensearch"
-	awsauth "github.com/smartystreets/go-aws-auth"
 
+	"github.com/disaster37/opensearch/v2"
 	"github.com/disaster37/opensearch/v2/aws"
 )
 
@@ -27,6 +26,7 @@ func main() {
 		secretKey = flag.String("secret-key", env.String("", "AWS_SECRET_KEY"), "Secret access key")
 		url       = flag.String("url", "http://localhost:9200", "Opensearch URL")
 		sniff     = flag.Bool("sniff", false, "Enable or disable sniffing")
+		region    = flag.String("region", "eu-west-1", "AWS Region name")
 	)
 	flag.Parse()
 	log.SetFlags(0)
@@ -41,10 +41,15 @@ func main() {
 		log.Fatal("missing -secret-key or AWS_SECRET_KEY environment variable")
 	}
 
-	signingClient := aws.NewV4SigningClient(awsauth.Credentials{
-		AccessKeyID:     *accessKey,
-		SecretAccessKey: *secretKey,
-	})
+	if *region == "" {
+		log.Fatal("please specify an AWS region with -region")
+	}
+
+	signingClient := aws.NewV4SigningClient(aws.NewStaticCredentialsProvider(
+		*accessKey,
+		*secretKey,
+		"",
+	), *region)
 
 	// Create an Opensearch client
 	client, err := opensearch.NewClient(
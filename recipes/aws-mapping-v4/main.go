@@ -18,11 +18,10 @@ import (
 	"os"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/olivere/env"
-	"github.com/olivere/opensearch"
 
-	aws "github.com/disaster37/opensearch/v2/aws/v4"
+	"github.com/disaster37/opensearch/v2"
+	"github.com/disaster37/opensearch/v2/aws"
 )
 
 const (
@@ -90,7 +89,7 @@ func main() {
 	}
 
 	// Create an Opensearch client
-	signingClient := aws.NewV4SigningClient(credentials.NewStaticCredentials(
+	signingClient := aws.NewV4SigningClient(aws.NewStaticCredentialsProvider(
 		*accessKey,
 		*secretKey,
 		"",
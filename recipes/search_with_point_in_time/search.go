@@ -22,7 +22,7 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/olivere/opensearch/v7"
+	"github.com/disaster37/opensearch/v2"
 )
 
 func main() {
@@ -78,7 +78,7 @@ func main() {
 		).
 		Size(*size).
 		PointInTime(
-			opensearch.NewPointInTimeWithKeepAlive(pit.Id, "2m"),
+			pit.PointInTime(),
 		).
 		Do(context.Background())
 	if err != nil {
@@ -112,11 +112,28 @@ func (r *Request) setBodyReader(body io.Reader) error {
 	}
 	r.Body = rc
 	if body != nil {
+		// Allow transports to read the body again, like http.NewRequest does
 		switch v := body.(type) {
 		case *strings.Reader:
 			r.ContentLength = int64(v.Len())
+			snapshot := *v
+			r.GetBody = func() (io.ReadCloser, error) {
+				r := snapshot
+				return io.NopCloser(&r), nil
+			}
+		case *bytes.Reader:
+			r.ContentLength = int64(v.Len())
+			snapshot := *v
+			r.GetBody = func() (io.ReadCloser, error) {
+				r := snapshot
+				return io.NopCloser(&r), nil
+			}
 		case *bytes.Buffer:
 			r.ContentLength = int64(v.Len())
+			buf := v.Bytes()
+			r.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(buf)), nil
+			}
 		}
 	}
 	return nil
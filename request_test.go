@@ -4,7 +4,10 @@
 
 package opensearch
 
-import "testing"
+import (
+	"io"
+	"testing"
+)
 
 var testReq *Request // used as a temporary variable to avoid compiler optimizations in tests/benchmarks
 
@@ -25,6 +28,39 @@ func TestRequestSetContentType(t *testing.T) {
 	}
 }
 
+func TestRequestSetBodyGetBody(t *testing.T) {
+	for _, gzip := range []bool{false, true} {
+		req, err := NewRequest("POST", "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := req.SetBody(map[string]interface{}{"query": "match_all"}, gzip); err != nil {
+			t.Fatal(err)
+		}
+		if req.GetBody == nil {
+			t.Fatalf("gzip=%v: expected GetBody to be set", gzip)
+		}
+		rc, err := req.GetBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		copied, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(copied) != string(body) {
+			t.Fatalf("gzip=%v: expected GetBody to return %q; got: %q", gzip, body, copied)
+		}
+		if want, have := int64(len(body)), req.ContentLength; want != have {
+			t.Fatalf("gzip=%v: expected ContentLength %d; got: %d", gzip, want, have)
+		}
+	}
+}
+
 func BenchmarkRequestSetBodyString(b *testing.B) {
 	req, err := NewRequest("GET", "/")
 	if err != nil {
@@ -26,16 +26,20 @@ type Response struct {
 	// DeprecationWarnings lists all deprecation warnings returned from
 	// Opensearch.
 	DeprecationWarnings []string
+	// Deprecations are the parsed DeprecationWarnings.
+	Deprecations []DeprecationWarning
 	// BodyReader is the body as a reader. Only available if streaming is enabled.
 	BodyReader io.ReadCloser
 }
 
-// newResponse creates a new response from the HTTP response.
-func (c *Client) newResponse(res *http.Response, maxBodySize int64, stream bool) (*Response, error) {
+// newResponse creates a new response from the HTTP response, with the
+// deprecation warnings parsed from its Warning header.
+func (c *Client) newResponse(res *http.Response, maxBodySize int64, stream bool, warnings []DeprecationWarning) (*Response, error) {
 	r := &Response{
 		StatusCode:          res.StatusCode,
 		Header:              res.Header,
 		DeprecationWarnings: res.Header["Warning"],
+		Deprecations:        warnings,
 	}
 	if stream {
 		r.BodyReader = res.Body
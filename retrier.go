@@ -29,7 +29,8 @@ type Retrier interface {
 	//
 	// Callers may also use this to inspect the HTTP request/response and
 	// the error that happened. Additional data can be passed through via
-	// the context.
+	// the context. The RetryBudget of the request and the time spent so far
+	// are available via RetryInfoFromContext.
 	Retry(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error)
 }
 
@@ -65,3 +66,94 @@ func (r *BackoffRetrier) Retry(ctx context.Context, retry int, req *http.Request
 	wait, goahead := r.backoff.Next(retry)
 	return wait, goahead, nil
 }
+
+// -- Retry budget --
+
+// RetryBudget limits the total effort spent on a single request,
+// including all of its retries. A zero value means no limit.
+type RetryBudget struct {
+	// MaxElapsedTime is the maximum time since the first attempt after
+	// which no more retries are started. A retry is also not started if
+	// waiting for it would exceed MaxElapsedTime.
+	MaxElapsedTime time.Duration
+	// MaxAttempts is the maximum number of attempts, including the first one.
+	MaxAttempts int
+}
+
+// RetryInfo describes the retry state of a request. It is passed to a
+// Retrier via the context and can be retrieved with RetryInfoFromContext.
+type RetryInfo struct {
+	// Budget is the RetryBudget in effect for the request.
+	Budget RetryBudget
+	// Start is the time when the first attempt started.
+	Start time.Time
+	// Attempts is the number of failed attempts so far.
+	Attempts int
+}
+
+// Elapsed returns the time since the first attempt.
+func (i *RetryInfo) Elapsed() time.Duration {
+	return time.Since(i.Start)
+}
+
+// Remaining returns the time left until MaxElapsedTime of the budget is
+// reached. The second return value is false if there is no time limit.
+func (i *RetryInfo) Remaining() (time.Duration, bool) {
+	if i.Budget.MaxElapsedTime <= 0 {
+		return 0, false
+	}
+	remaining := i.Budget.MaxElapsedTime - i.Elapsed()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// allows returns true if the budget allows another attempt after
+// waiting for the given time.
+func (i *RetryInfo) allows(wait time.Duration) bool {
+	if i.Budget.MaxAttempts > 0 && i.Attempts >= i.Budget.MaxAttempts {
+		return false
+	}
+	if remaining, ok := i.Remaining(); ok && wait >= remaining {
+		return false
+	}
+	return true
+}
+
+type retryInfoKey struct{}
+type retryBudgetKey struct{}
+
+// RetryInfoFromContext returns the RetryInfo of the request that is
+// being retried, or nil if there is none.
+func RetryInfoFromContext(ctx context.Context) *RetryInfo {
+	info, _ := ctx.Value(retryInfoKey{}).(*RetryInfo)
+	return info
+}
+
+// WithRetryBudget returns a context that overrides the client's
+// RetryBudget for all requests made with it.
+func WithRetryBudget(ctx context.Context, budget RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// retryBudgetFromContext returns the RetryBudget set with WithRetryBudget.
+func retryBudgetFromContext(ctx context.Context) (RetryBudget, bool) {
+	budget, ok := ctx.Value(retryBudgetKey{}).(RetryBudget)
+	return budget, ok
+}
+
+// sleepContext waits for the given duration, or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -178,3 +178,137 @@ func TestRetrierOnPerformRequest(t *testing.T) {
 		t.Errorf("requestRetrier: expected %d calls; got: %d", want, have)
 	}
 }
+
+func TestRetrierWaitHonoursContext(t *testing.T) {
+	fail := func(r *http.Request) (*http.Response, error) {
+		return nil, errors.New("request failed")
+	}
+	tr := &failingTransport{path: "/fail", fail: fail}
+	httpClient := &http.Client{Transport: tr}
+
+	client, err := NewClient(
+		SetURL("http://opensearch.svc:9200"),
+		SetHttpClient(httpClient),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(NewConstantBackoff(time.Minute))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.PerformRequest(ctx, PerformRequestOptions{
+		Method: "GET",
+		Path:   "/fail",
+	})
+	if !IsContextErr(err) {
+		t.Fatalf("expected context error; got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected PerformRequest to return when the context is done; took %v", elapsed)
+	}
+}
+
+func TestRetrierWithRetryBudgetMaxAttempts(t *testing.T) {
+	var numFailedReqs int64
+	fail := func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&numFailedReqs, 1)
+		return nil, errors.New("request failed")
+	}
+	tr := &failingTransport{path: "/fail", fail: fail}
+	httpClient := &http.Client{Transport: tr}
+
+	client, err := NewClient(
+		SetURL("http://opensearch.svc:9200"),
+		SetHttpClient(httpClient),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(NewBackoffRetrier(NewConstantBackoff(time.Millisecond))),
+		SetRetryBudget(RetryBudget{MaxAttempts: 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/fail",
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := int64(3), atomic.LoadInt64(&numFailedReqs); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+
+	// Override the budget per request
+	atomic.StoreInt64(&numFailedReqs, 0)
+	client.conns[0].MarkAsHealthy()
+	_, err = client.PerformRequest(WithRetryBudget(context.Background(), RetryBudget{MaxAttempts: 2}), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/fail",
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if want, have := int64(2), atomic.LoadInt64(&numFailedReqs); want != have {
+		t.Fatalf("expected %d requests; got: %d", want, have)
+	}
+}
+
+func TestRetrierWithRetryBudgetMaxElapsedTime(t *testing.T) {
+	var numFailedReqs int64
+	fail := func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt64(&numFailedReqs, 1)
+		return nil, errors.New("request failed")
+	}
+	tr := &failingTransport{path: "/fail", fail: fail}
+	httpClient := &http.Client{Transport: tr}
+
+	var infos []RetryInfo
+	retrier := RetrierFunc(func(ctx context.Context, retry int, req *http.Request, resp *http.Response, err error) (time.Duration, bool, error) {
+		if info := RetryInfoFromContext(ctx); info != nil {
+			infos = append(infos, *info)
+		}
+		return 40 * time.Millisecond, true, nil
+	})
+
+	client, err := NewClient(
+		SetURL("http://opensearch.svc:9200"),
+		SetHttpClient(httpClient),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetRetrier(retrier))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method:      "GET",
+		Path:        "/fail",
+		RetryBudget: &RetryBudget{MaxElapsedTime: 100 * time.Millisecond},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected PerformRequest to stop retrying after the budget; took %v", elapsed)
+	}
+	if n := atomic.LoadInt64(&numFailedReqs); n < 2 || n > 3 {
+		t.Fatalf("expected 2 or 3 requests; got: %d", n)
+	}
+	if len(infos) == 0 {
+		t.Fatal("expected Retrier to see the RetryInfo")
+	}
+	for i, info := range infos {
+		if want, have := 100*time.Millisecond, info.Budget.MaxElapsedTime; want != have {
+			t.Fatalf("#%d: expected MaxElapsedTime of %v; got: %v", i, want, have)
+		}
+		if want, have := i+1, info.Attempts; want != have {
+			t.Fatalf("#%d: expected %d attempts; got: %d", i, want, have)
+		}
+	}
+}
@@ -347,9 +347,24 @@ func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
 	nextScrollId := s.scrollId
 	s.mu.RUnlock()
 	if len(nextScrollId) == 0 {
-		return s.first(ctx)
+		return s.first(ctx, nil)
 	}
-	return s.next(ctx)
+	return s.next(ctx, nil)
+}
+
+// DoStream returns the next page of search results like Do, but calls fn
+// for each hit while the response is decoded, instead of keeping the
+// whole page in memory. The returned SearchResult has all the metadata
+// of the page, but no hits. It will return io.EOF as error if there are
+// no more search results.
+func (s *ScrollService) DoStream(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
+	s.mu.RLock()
+	nextScrollId := s.scrollId
+	s.mu.RUnlock()
+	if len(nextScrollId) == 0 {
+		return s.first(ctx, fn)
+	}
+	return s.next(ctx, fn)
 }
 
 // Clear cancels the current scroll operation. If you don't do this manually,
@@ -399,8 +414,9 @@ func (s *ScrollService) Clear(ctx context.Context) error {
 
 // -- First --
 
-// first takes the first page of search results.
-func (s *ScrollService) first(ctx context.Context) (*SearchResult, error) {
+// first takes the first page of search results. If fn is not nil, the
+// response is streamed, see DoStream.
+func (s *ScrollService) first(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
 	// Get URL and parameters for request
 	path, params, err := s.buildFirstURL()
 	if err != nil {
@@ -422,20 +438,41 @@ func (s *ScrollService) first(ctx context.Context) (*SearchResult, error) {
 		Retrier:         s.retrier,
 		Headers:         s.headers,
 		MaxResponseSize: s.maxResponseSize,
+		Stream:          fn != nil,
 	})
+	return s.decode(res, err, fn)
+}
+
+// decode decodes a page of search results, and remembers its scroll id.
+func (s *ScrollService) decode(res *Response, err error, fn SearchHitFunc) (*SearchResult, error) {
+	if res != nil && res.BodyReader != nil {
+		defer res.BodyReader.Close()
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Return operation response
-	ret := new(SearchResult)
-	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
-		return nil, err
+	var ret *SearchResult
+	var n int
+	if fn != nil {
+		ret, n, err = decodeSearchResultStream(s.client.decoder, res.BodyReader, fn)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ret = new(SearchResult)
+		if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+			return nil, err
+		}
+		if ret.Hits != nil {
+			n = len(ret.Hits.Hits)
+		}
 	}
 	s.mu.Lock()
 	s.scrollId = ret.ScrollId
 	s.mu.Unlock()
-	if ret.Hits == nil || len(ret.Hits.Hits) == 0 {
+	if n == 0 {
 		return ret, io.EOF
 	}
 	return ret, nil
@@ -548,7 +585,9 @@ func (s *ScrollService) bodyFirst() (interface{}, error) {
 
 // -- Next --
 
-func (s *ScrollService) next(ctx context.Context) (*SearchResult, error) {
+// next takes the next page of search results. If fn is not nil, the
+// response is streamed, see DoStream.
+func (s *ScrollService) next(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
 	// Get URL for request
 	path, params, err := s.buildNextURL()
 	if err != nil {
@@ -570,23 +609,9 @@ func (s *ScrollService) next(ctx context.Context) (*SearchResult, error) {
 		Retrier:         s.retrier,
 		Headers:         s.headers,
 		MaxResponseSize: s.maxResponseSize,
+		Stream:          fn != nil,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Return operation response
-	ret := new(SearchResult)
-	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
-		return nil, err
-	}
-	s.mu.Lock()
-	s.scrollId = ret.ScrollId
-	s.mu.Unlock()
-	if ret.Hits == nil || len(ret.Hits.Hits) == 0 {
-		return ret, io.EOF
-	}
-	return ret, nil
+	return s.decode(res, err, fn)
 }
 
 // buildNextURL builds the URL for the operation.
@@ -49,6 +49,9 @@ type SearchService struct {
 	restTotalHitsAsInt         *bool // rest_total_hits_as_int
 
 	ccsMinimizeRoundtrips *bool // ccs_minimize_roundtrips
+
+	hedge       *HedgePolicy
+	cancelTasks bool
 }
 
 // NewSearchService creates a new service for searching in Opensearch.
@@ -197,6 +200,23 @@ func (s *SearchService) Preference(preference string) *SearchService {
 	return s
 }
 
+// Hedge sends the request to a second node if the first one has not
+// answered in time, and uses the first response. See HedgePolicy.
+func (s *SearchService) Hedge(policy *HedgePolicy) *SearchService {
+	s.hedge = policy
+	return s
+}
+
+// CancelTasks, if enabled, cancels the tasks of the request in the cluster
+// when the context passed to Do is done before the response arrives.
+// The tasks are found by an X-Opaque-Id header unique to the request. An
+// opaque id set with WithOpaqueID is kept as its prefix, so other requests
+// sharing it are not cancelled.
+func (s *SearchService) CancelTasks(enabled bool) *SearchService {
+	s.cancelTasks = enabled
+	return s
+}
+
 // RequestCache indicates whether the cache should be used for this
 // request or not, defaults to index level setting.
 func (s *SearchService) RequestCache(requestCache bool) *SearchService {
@@ -608,6 +628,47 @@ func (s *SearchService) Validate() error {
 
 // Do executes the search and returns a SearchResult.
 func (s *SearchService) Do(ctx context.Context) (*SearchResult, error) {
+	res, err := s.perform(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return search results
+	ret := new(SearchResult)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		ret.Header = res.Header
+		return nil, err
+	}
+	ret.Header = res.Header
+	return ret, nil
+}
+
+// DoStream executes the search and calls fn for each hit, while the
+// response is decoded. Unlike Do, it does not keep the whole response
+// in memory, which is useful for large pages. The returned SearchResult
+// has all the metadata, e.g. the total number of hits and the
+// aggregations, but no hits.
+func (s *SearchService) DoStream(ctx context.Context, fn SearchHitFunc) (*SearchResult, error) {
+	res, err := s.perform(ctx, true)
+	if res != nil && res.BodyReader != nil {
+		defer res.BodyReader.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Return search results
+	ret, _, err := decodeSearchResultStream(s.client.decoder, res.BodyReader, fn)
+	if err != nil {
+		return nil, err
+	}
+	ret.Header = res.Header
+	return ret, nil
+}
+
+// perform executes the search request. If stream is true, the body of the
+// response must be read from BodyReader.
+func (s *SearchService) perform(ctx context.Context, stream bool) (*Response, error) {
 	// Check pre-conditions
 	if err := s.Validate(); err != nil {
 		return nil, err
@@ -630,26 +691,17 @@ func (s *SearchService) Do(ctx context.Context) (*SearchResult, error) {
 		}
 		body = src
 	}
-	res, err := s.client.PerformRequest(ctx, PerformRequestOptions{
+	return s.client.PerformRequest(ctx, PerformRequestOptions{
 		Method:          "POST",
 		Path:            path,
 		Params:          params,
 		Body:            body,
 		Headers:         s.headers,
 		MaxResponseSize: s.maxResponseSize,
+		Stream:          stream,
+		Hedge:           s.hedge,
+		CancelTasks:     s.cancelTasks,
 	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Return search results
-	ret := new(SearchResult)
-	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
-		ret.Header = res.Header
-		return nil, err
-	}
-	ret.Header = res.Header
-	return ret, nil
 }
 
 // SearchResult is the result of a search in Opensearch.
@@ -689,6 +741,17 @@ func (r *SearchResult) TotalHits() int64 {
 	return 0
 }
 
+// PointInTime returns the PointInTime to use in the next search when
+// paging through a Point in Time. Opensearch may return an updated
+// Point in Time id with every search, so callers should always continue
+// with the id of the most recent result.
+func (r *SearchResult) PointInTime(keepAlive string) *PointInTime {
+	if r == nil || r.PitId == "" {
+		return nil
+	}
+	return NewPointInTimeWithKeepAlive(r.PitId, keepAlive)
+}
+
 // Each is a utility function to iterate over all hits. It saves you from
 // checking for nil values. Notice that Each will ignore errors in
 // serializing JSON and hits with empty/nil _source will get an empty
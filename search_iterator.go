@@ -0,0 +1,277 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultSearchIteratorKeepAlive is the default time a Point in Time
+	// is kept alive between two pages of a SearchIterator.
+	DefaultSearchIteratorKeepAlive = "5m"
+
+	// DefaultSearchIteratorSize is the default number of hits fetched
+	// per page by a SearchIterator.
+	DefaultSearchIteratorSize = 1000
+
+	// DefaultSearchIteratorTiebreaker is the field used by default to break
+	// ties between hits with the same sort values.
+	DefaultSearchIteratorTiebreaker = "_id"
+
+	// closePointInTimeTimeout is the time the SearchIterator waits for
+	// the Point in Time to be closed when iteration ends.
+	closePointInTimeTimeout = 10 * time.Second
+)
+
+// SearchIterator pages through all hits of a query by opening a Point in
+// Time and using search_after with a tiebreaker sort. It is an alternative
+// to ScrollService for deep pagination.
+//
+// The Point in Time id returned with each page is used for the next page,
+// and the Point in Time is closed when the iteration ends, either because
+// all hits have been returned, the caller stops iterating, or the context
+// is cancelled.
+//
+// Example:
+//
+//	it := client.SearchIterator("index").Query(q).Sort("created", true)
+//	for hit, err := range it.Hits(ctx) {
+//	  if err != nil {
+//	    // Handle error
+//	  }
+//	  // Work with hit
+//	}
+type SearchIterator struct {
+	client *Client
+
+	headers http.Header // custom request-level HTTP headers
+
+	index          []string
+	keepAlive      string
+	size           int
+	routing        string
+	preference     string
+	query          Query
+	sorters        []Sorter
+	tiebreaker     Sorter
+	fetchSourceCtx *FetchSourceContext
+	trackTotalHits interface{}
+}
+
+// NewSearchIterator creates a new SearchIterator.
+func NewSearchIterator(client *Client) *SearchIterator {
+	return &SearchIterator{
+		client:         client,
+		keepAlive:      DefaultSearchIteratorKeepAlive,
+		size:           DefaultSearchIteratorSize,
+		tiebreaker:     NewFieldSort(DefaultSearchIteratorTiebreaker).Asc(),
+		trackTotalHits: false,
+	}
+}
+
+// Header adds a header to the requests.
+func (s *SearchIterator) Header(name string, value string) *SearchIterator {
+	if s.headers == nil {
+		s.headers = http.Header{}
+	}
+	s.headers.Add(name, value)
+	return s
+}
+
+// Headers specifies the headers of the requests.
+func (s *SearchIterator) Headers(headers http.Header) *SearchIterator {
+	s.headers = headers
+	return s
+}
+
+// Index sets the names of the indices to iterate over.
+func (s *SearchIterator) Index(index ...string) *SearchIterator {
+	s.index = append(s.index, index...)
+	return s
+}
+
+// KeepAlive sets the time the Point in Time is kept alive between two
+// pages, e.g. "5m" (the default).
+func (s *SearchIterator) KeepAlive(keepAlive string) *SearchIterator {
+	s.keepAlive = keepAlive
+	return s
+}
+
+// Size specifies the number of hits to fetch per page.
+func (s *SearchIterator) Size(size int) *SearchIterator {
+	s.size = size
+	return s
+}
+
+// Routing is a specific routing value used when opening the Point in Time.
+func (s *SearchIterator) Routing(routing string) *SearchIterator {
+	s.routing = routing
+	return s
+}
+
+// Preference specifies the node or shard used when opening the
+// Point in Time.
+func (s *SearchIterator) Preference(preference string) *SearchIterator {
+	s.preference = preference
+	return s
+}
+
+// Query sets the query to perform, e.g. MatchAllQuery.
+func (s *SearchIterator) Query(query Query) *SearchIterator {
+	s.query = query
+	return s
+}
+
+// Sort adds a sort order.
+func (s *SearchIterator) Sort(field string, ascending bool) *SearchIterator {
+	s.sorters = append(s.sorters, SortInfo{Field: field, Ascending: ascending})
+	return s
+}
+
+// SortBy adds one or more sorters.
+func (s *SearchIterator) SortBy(sorter ...Sorter) *SearchIterator {
+	s.sorters = append(s.sorters, sorter...)
+	return s
+}
+
+// Tiebreaker sets the sorter that is added last to make the sort order
+// unique. It defaults to sorting by "_id" ascending; pass a sorter on a
+// unique keyword field for better performance.
+func (s *SearchIterator) Tiebreaker(tiebreaker Sorter) *SearchIterator {
+	s.tiebreaker = tiebreaker
+	return s
+}
+
+// FetchSourceContext indicates how the _source should be fetched.
+func (s *SearchIterator) FetchSourceContext(fetchSourceContext *FetchSourceContext) *SearchIterator {
+	s.fetchSourceCtx = fetchSourceContext
+	return s
+}
+
+// TrackTotalHits controls if the total hit count for the query should be
+// tracked. It is disabled by default as the iterator does not need it.
+func (s *SearchIterator) TrackTotalHits(trackTotalHits interface{}) *SearchIterator {
+	s.trackTotalHits = trackTotalHits
+	return s
+}
+
+// Validate checks if the iterator is valid.
+func (s *SearchIterator) Validate() error {
+	var invalid []string
+	if len(s.index) == 0 {
+		invalid = append(invalid, "Index")
+	}
+	if s.keepAlive == "" {
+		invalid = append(invalid, "KeepAlive")
+	}
+	if s.size <= 0 {
+		invalid = append(invalid, "Size")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// openPointInTime opens the Point in Time to iterate over.
+func (s *SearchIterator) openPointInTime(ctx context.Context) (*OpenPointInTimeResponse, error) {
+	svc := s.client.OpenPointInTime(s.index...).
+		KeepAlive(s.keepAlive).
+		Headers(s.headers)
+	if s.routing != "" {
+		svc = svc.Routing(s.routing)
+	}
+	if s.preference != "" {
+		svc = svc.Preference(s.preference)
+	}
+	return svc.Do(ctx)
+}
+
+// closePointInTime closes the Point in Time. It is run even if the
+// caller's context has been cancelled, so the cluster can release the
+// resources as early as possible.
+func (s *SearchIterator) closePointInTime(ctx context.Context, pitId string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), closePointInTimeTimeout)
+	defer cancel()
+	if _, err := s.client.ClosePointInTime(pitId).Headers(s.headers).Do(ctx); err != nil {
+		s.client.log.ErrorContext(ctx, "opensearch: cannot close point in time", slog.Any("error", err))
+	}
+}
+
+// searchPage fetches the next page of hits after the given sort values.
+func (s *SearchIterator) searchPage(ctx context.Context, pitId string, searchAfter []interface{}) (*SearchResult, error) {
+	svc := s.client.Search().
+		Headers(s.headers).
+		PointInTime(NewPointInTimeWithKeepAlive(pitId, s.keepAlive)).
+		Size(s.size).
+		TrackTotalHits(s.trackTotalHits).
+		SortBy(s.sorters...)
+	if s.tiebreaker != nil {
+		svc = svc.SortBy(s.tiebreaker)
+	}
+	if s.query != nil {
+		svc = svc.Query(s.query)
+	}
+	if s.fetchSourceCtx != nil {
+		svc = svc.FetchSourceContext(s.fetchSourceCtx)
+	}
+	if len(searchAfter) > 0 {
+		svc = svc.SearchAfter(searchAfter...)
+	}
+	return svc.Do(ctx)
+}
+
+// Hits returns an iterator over all hits. Iteration stops at the first
+// error, which is yielded together with a nil hit.
+func (s *SearchIterator) Hits(ctx context.Context) iter.Seq2[*SearchHit, error] {
+	return func(yield func(*SearchHit, error) bool) {
+		if err := s.Validate(); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		pit, err := s.openPointInTime(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		pitId := pit.Id
+		defer func() { s.closePointInTime(ctx, pitId) }()
+
+		var searchAfter []interface{}
+		for {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+			res, err := s.searchPage(ctx, pitId, searchAfter)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if res.PitId != "" {
+				pitId = res.PitId
+			}
+			if res.Hits == nil || len(res.Hits.Hits) == 0 {
+				return
+			}
+			for _, hit := range res.Hits.Hits {
+				if !yield(hit, nil) {
+					return
+				}
+			}
+			searchAfter = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+			if len(res.Hits.Hits) < s.size || len(searchAfter) == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestSearchIteratorValidate(t *testing.T) {
+	client, err := NewSimpleClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for _, err := range client.SearchIterator().Hits(context.Background()) {
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		n++
+	}
+	if want, have := 1, n; want != have {
+		t.Fatalf("expected %d iterations; got: %d", want, have)
+	}
+}
+
+// fakePointInTimeServer simulates paging through numDocs documents
+// with a Point in Time and search_after.
+type fakePointInTimeServer struct {
+	mu       sync.Mutex
+	numDocs  int
+	pitSeq   int
+	openPits map[string]bool
+	searches int
+}
+
+func (s *fakePointInTimeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/tweets/_search/point_in_time":
+		if r.URL.Query().Get("keep_alive") == "" {
+			http.Error(w, `{"error":"missing keep_alive"}`, http.StatusBadRequest)
+			return
+		}
+		s.pitSeq++
+		id := fmt.Sprintf("pit-%d", s.pitSeq)
+		s.openPits[id] = true
+		fmt.Fprintf(w, `{"pit_id":%q,"creation_time":1}`, id)
+	case r.Method == "DELETE" && r.URL.Path == "/_search/point_in_time":
+		var body struct {
+			PitId []string `json:"pit_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, id := range body.PitId {
+			delete(s.openPits, id)
+		}
+		fmt.Fprint(w, `{"pits":[]}`)
+	case r.URL.Path == "/_search":
+		var body struct {
+			Size        int   `json:"size"`
+			SearchAfter []int `json:"search_after"`
+			Pit         struct {
+				Id string `json:"id"`
+			} `json:"pit"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.openPits[body.Pit.Id] {
+			http.Error(w, `{"error":"unknown pit"}`, http.StatusNotFound)
+			return
+		}
+		s.searches++
+
+		// Opensearch may return a new Point in Time id with every page
+		delete(s.openPits, body.Pit.Id)
+		s.pitSeq++
+		pitId := fmt.Sprintf("pit-%d", s.pitSeq)
+		s.openPits[pitId] = true
+
+		from := 0
+		if len(body.SearchAfter) > 0 {
+			from = body.SearchAfter[0] + 1
+		}
+		var hits []*SearchHit
+		for i := from; i < s.numDocs && len(hits) < body.Size; i++ {
+			hits = append(hits, &SearchHit{Id: fmt.Sprint(i), Sort: []interface{}{i}})
+		}
+		_ = json.NewEncoder(w).Encode(&SearchResult{
+			PitId: pitId,
+			Hits:  &SearchHits{Hits: hits},
+		})
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":"unexpected %s %s"}`, r.Method, r.URL.Path), http.StatusBadRequest)
+	}
+}
+
+func TestSearchIteratorHits(t *testing.T) {
+	fake := &fakePointInTimeServer{numDocs: 25, openPits: make(map[string]bool)}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for hit, err := range client.SearchIterator("tweets").Size(10).Hits(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, hit.Id)
+	}
+	if want, have := 25, len(ids); want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+	for i, id := range ids {
+		if want := fmt.Sprint(i); want != id {
+			t.Fatalf("expected hit #%d to have id %q; got: %q", i, want, id)
+		}
+	}
+	if want, have := 3, fake.searches; want != have {
+		t.Fatalf("expected %d searches; got: %d", want, have)
+	}
+	if len(fake.openPits) > 0 {
+		t.Fatalf("expected all Points in Time to be closed; got: %v", fake.openPits)
+	}
+}
+
+func TestSearchIteratorStopEarly(t *testing.T) {
+	fake := &fakePointInTimeServer{numDocs: 25, openPits: make(map[string]bool)}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	for _, err := range client.SearchIterator("tweets").Size(10).Hits(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+		if n == 12 {
+			break
+		}
+	}
+	if want, have := 2, fake.searches; want != have {
+		t.Fatalf("expected %d searches; got: %d", want, have)
+	}
+	if len(fake.openPits) > 0 {
+		t.Fatalf("expected all Points in Time to be closed; got: %v", fake.openPits)
+	}
+}
+
+func TestSearchIteratorContextCancelled(t *testing.T) {
+	fake := &fakePointInTimeServer{numDocs: 25, openPits: make(map[string]bool)}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client, err := NewSimpleClient(SetURL(ts.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var n int
+	var lastErr error
+	for _, err := range client.SearchIterator("tweets").Size(10).Hits(ctx) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		n++
+		if n == 10 {
+			cancel()
+		}
+	}
+	if !IsContextErr(lastErr) {
+		t.Fatalf("expected context error; got: %v", lastErr)
+	}
+	if len(fake.openPits) > 0 {
+		t.Fatalf("expected all Points in Time to be closed; got: %v", fake.openPits)
+	}
+}
+
+func TestSearchIteratorIntegration(t *testing.T) {
+	client := setupTestClientAndCreateIndexAndAddDocs(t)
+
+	var n int
+	it := client.SearchIterator(testIndexName).Size(1).Sort("user", true)
+	for hit, err := range it.Hits(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hit.Id == "" {
+			t.Fatal("expected hit to have an id")
+		}
+		n++
+	}
+	if want, have := 3, n; want != have {
+		t.Fatalf("expected %d hits; got: %d", want, have)
+	}
+}
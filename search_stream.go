@@ -0,0 +1,159 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// SearchHitFunc is called for each hit of a streamed search result, see
+// e.g. SearchService.DoStream. The hit must not be used after the func
+// returns, unless it is kept by the func. If it returns an error,
+// streaming stops and the error is returned.
+type SearchHitFunc func(hit *SearchHit) error
+
+// decodeSearchResultStream decodes a search result from r token by token,
+// and calls fn for each hit in hits.hits instead of keeping the hits in
+// memory. The metadata, e.g. the total number of hits, the aggregations
+// and the scroll id, are returned in the SearchResult, with Hits.Hits
+// left empty. It also returns the number of hits passed to fn.
+func decodeSearchResultStream(decoder Decoder, r io.Reader, fn SearchHitFunc) (*SearchResult, int, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, 0, err
+	}
+
+	// Everything but the hits is small, so collect it and decode it at the end
+	fields := make(map[string]json.RawMessage)
+	var hits *SearchHits
+	var n int
+	for dec.More() {
+		key, err := decodeKey(dec)
+		if err != nil {
+			return nil, n, err
+		}
+		if key != "hits" {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, n, err
+			}
+			fields[key] = raw
+			continue
+		}
+		if hits, err = decodeSearchHitsStream(decoder, dec, fn, &n); err != nil {
+			return nil, n, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, n, err
+	}
+
+	ret := new(SearchResult)
+	if len(fields) > 0 {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, n, err
+		}
+		if err := decoder.Decode(data, ret); err != nil {
+			return nil, n, err
+		}
+	}
+	ret.Hits = hits
+	return ret, n, nil
+}
+
+// decodeSearchHitsStream decodes the hits object of a search result, and
+// calls fn for each hit. n is incremented for each hit.
+func decodeSearchHitsStream(decoder Decoder, dec *json.Decoder, fn SearchHitFunc, n *int) (*SearchHits, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil // "hits": null
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("opensearch: unexpected token %v in hits", tok)
+	}
+
+	hits := new(SearchHits)
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		key, err := decodeKey(dec)
+		if err != nil {
+			return nil, err
+		}
+		if key != "hits" {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			fields[key] = raw
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return nil, err
+		}
+		for dec.More() {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			hit := new(SearchHit)
+			if err := decoder.Decode(raw, hit); err != nil {
+				return nil, err
+			}
+			*n++
+			if err := fn(hit); err != nil {
+				return nil, err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(data, hits); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// decodeKey reads the key of the next member of an object.
+func decodeKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("opensearch: unexpected token %v, expected key", tok)
+	}
+	return key, nil
+}
+
+// expectDelim reads the next token and returns an error if it is not
+// the given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("opensearch: unexpected token %v, expected %v", tok, delim)
+	}
+	return nil
+}
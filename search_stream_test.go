@@ -0,0 +1,182 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const streamSearchResult = `{
+	"took": 12,
+	"timed_out": false,
+	"_scroll_id": "scroll-1",
+	"_shards": {"total": 1, "successful": 1, "skipped": 0, "failed": 0},
+	"hits": {
+		"total": {"value": 3, "relation": "eq"},
+		"max_score": 1.5,
+		"hits": [
+			{"_index": "test", "_id": "1", "_score": 1.5, "_source": {"user": "olivere"}},
+			{"_index": "test", "_id": "2", "_score": 1.0, "_source": {"user": "sandrae"}},
+			{"_index": "test", "_id": "3", "_score": 0.5, "_source": {"user": "olivere"}}
+		]
+	},
+	"aggregations": {"users": {"buckets": [{"key": "olivere", "doc_count": 2}, {"key": "sandrae", "doc_count": 1}]}}
+}`
+
+func TestDecodeSearchResultStream(t *testing.T) {
+	var ids []string
+	ret, n, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(streamSearchResult), func(hit *SearchHit) error {
+		ids = append(ids, hit.Id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, n; want != have {
+		t.Fatalf("want %d hits, have %d", want, have)
+	}
+	if want, have := "1,2,3", strings.Join(ids, ","); want != have {
+		t.Fatalf("want ids %q, have %q", want, have)
+	}
+	if want, have := int64(12), ret.TookInMillis; want != have {
+		t.Fatalf("want took %d, have %d", want, have)
+	}
+	if want, have := "scroll-1", ret.ScrollId; want != have {
+		t.Fatalf("want scroll id %q, have %q", want, have)
+	}
+	if want, have := int64(3), ret.TotalHits(); want != have {
+		t.Fatalf("want %d total hits, have %d", want, have)
+	}
+	if ret.Hits.MaxScore == nil || *ret.Hits.MaxScore != 1.5 {
+		t.Fatalf("want max score 1.5, have %v", ret.Hits.MaxScore)
+	}
+	if want, have := 0, len(ret.Hits.Hits); want != have {
+		t.Fatalf("want %d hits in result, have %d", want, have)
+	}
+	agg, found := ret.Aggregations.Terms("users")
+	if !found {
+		t.Fatal("expected aggregation")
+	}
+	if want, have := 2, len(agg.Buckets); want != have {
+		t.Fatalf("want %d buckets, have %d", want, have)
+	}
+	if want, have := 1, ret.Shards.Successful; want != have {
+		t.Fatalf("want %d successful shards, have %d", want, have)
+	}
+}
+
+func TestDecodeSearchResultStreamStops(t *testing.T) {
+	errStop := errors.New("stop")
+	var n int
+	_, _, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(streamSearchResult), func(hit *SearchHit) error {
+		n++
+		if n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("want %v, have %v", errStop, err)
+	}
+	if want, have := 2, n; want != have {
+		t.Fatalf("want %d hits, have %d", want, have)
+	}
+}
+
+func TestDecodeSearchResultStreamWithoutHits(t *testing.T) {
+	for _, body := range []string{`{"took": 1}`, `{"took": 1, "hits": null}`, `{"took": 1, "hits": {"total": {"value": 0, "relation": "eq"}, "hits": []}}`} {
+		ret, n, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(body), func(hit *SearchHit) error {
+			t.Fatalf("unexpected hit %v", hit)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if want, have := 0, n; want != have {
+			t.Fatalf("%s: want %d hits, have %d", body, want, have)
+		}
+		if want, have := int64(1), ret.TookInMillis; want != have {
+			t.Fatalf("%s: want took %d, have %d", body, want, have)
+		}
+	}
+
+	if _, _, err := decodeSearchResultStream(&DefaultDecoder{}, strings.NewReader(`{"hits": {"hits": [`), func(*SearchHit) error { return nil }); err == nil {
+		t.Fatal("expected error for truncated response")
+	}
+}
+
+func TestSearchServiceDoStream(t *testing.T) {
+	pages := []string{streamSearchResult, `{"_scroll_id": "scroll-2", "hits": {"total": {"value": 3, "relation": "eq"}, "hits": []}}`}
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/missing/_search" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "index_not_found_exception"}, "status": 404}`))
+			return
+		}
+		page := pages[requests%len(pages)]
+		requests++
+		w.Write([]byte(page))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	res, err := client.Search("test").DoStream(context.Background(), func(hit *SearchHit) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, n; want != have {
+		t.Fatalf("want %d hits, have %d", want, have)
+	}
+	if want, have := int64(3), res.TotalHits(); want != have {
+		t.Fatalf("want %d total hits, have %d", want, have)
+	}
+	if res.Header == nil {
+		t.Fatal("expected header")
+	}
+
+	_, err = client.Search("missing").DoStream(context.Background(), func(hit *SearchHit) error { return nil })
+	if !IsNotFound(err) {
+		t.Fatalf("expected not found, have %v", err)
+	}
+
+	// Scrolling
+	requests = 0
+	n = 0
+	scroll := client.Scroll("test")
+	if _, err := scroll.DoStream(context.Background(), func(hit *SearchHit) error {
+		n++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 3, n; want != have {
+		t.Fatalf("want %d hits, have %d", want, have)
+	}
+	if want, have := "scroll-1", scroll.scrollId; want != have {
+		t.Fatalf("want scroll id %q, have %q", want, have)
+	}
+	if _, err := scroll.DoStream(context.Background(), func(hit *SearchHit) error { return nil }); err != io.EOF {
+		t.Fatalf("want %v, have %v", io.EOF, err)
+	}
+	if want, have := "scroll-2", scroll.scrollId; want != have {
+		t.Fatalf("want scroll id %q, have %q", want, have)
+	}
+}
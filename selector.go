@@ -0,0 +1,170 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// Selector picks the connection to use for the next request.
+//
+// Select is called with the connections that are currently alive; it is
+// never called with an empty list. Implementations must be safe for
+// concurrent use.
+type Selector interface {
+	Select(conns []Connection) (Connection, error)
+}
+
+// SelectorFunc is an adapter to allow the use of ordinary functions as
+// a Selector.
+type SelectorFunc func([]Connection) (Connection, error)
+
+// Select calls f.
+func (f SelectorFunc) Select(conns []Connection) (Connection, error) {
+	return f(conns)
+}
+
+// errNoConnection is returned by a Selector when no connection is passed.
+var errNoConnection = errors.Wrap(ErrNoClient, "no connection to select from")
+
+// -- RoundRobinSelector --
+
+// RoundRobinSelector picks the connections in turn. It is the default.
+type RoundRobinSelector struct {
+	mu    sync.Mutex
+	index int
+}
+
+// NewRoundRobinSelector creates a new RoundRobinSelector.
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{index: -1}
+}
+
+// Select returns the connection after the previously selected one.
+func (s *RoundRobinSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, errNoConnection
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	if s.index >= len(conns) {
+		s.index = 0
+	}
+	return conns[s.index], nil
+}
+
+// -- RandomSelector --
+
+// RandomSelector picks a random connection.
+type RandomSelector struct{}
+
+// NewRandomSelector creates a new RandomSelector.
+func NewRandomSelector() *RandomSelector {
+	return &RandomSelector{}
+}
+
+// Select returns a random connection.
+func (s *RandomSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, errNoConnection
+	}
+	return conns[rand.IntN(len(conns))], nil
+}
+
+// -- LeastInFlightSelector --
+
+// LeastInFlightSelector picks the connection with the fewest requests
+// in flight. Ties are broken in round-robin order.
+type LeastInFlightSelector struct {
+	mu     sync.Mutex
+	offset int
+}
+
+// NewLeastInFlightSelector creates a new LeastInFlightSelector.
+func NewLeastInFlightSelector() *LeastInFlightSelector {
+	return &LeastInFlightSelector{}
+}
+
+// Select returns the connection with the fewest requests in flight.
+func (s *LeastInFlightSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, errNoConnection
+	}
+	s.mu.Lock()
+	s.offset++
+	if s.offset >= len(conns) {
+		s.offset = 0
+	}
+	offset := s.offset
+	s.mu.Unlock()
+
+	var best Connection
+	var bestInFlight int64
+	for i := range conns {
+		conn := conns[(offset+i)%len(conns)]
+		if n := conn.InFlight(); best == nil || n < bestInFlight {
+			best, bestInFlight = conn, n
+		}
+	}
+	return best, nil
+}
+
+// -- LatencyWeightedSelector --
+
+// LatencyWeightedSelector picks a random connection, weighted by the
+// inverse of its moving average latency. A node that answers twice as
+// fast as another one receives twice the share of requests.
+//
+// Connections without latency samples are treated like the fastest
+// connection, so that new nodes receive traffic right away.
+type LatencyWeightedSelector struct{}
+
+// NewLatencyWeightedSelector creates a new LatencyWeightedSelector.
+func NewLatencyWeightedSelector() *LatencyWeightedSelector {
+	return &LatencyWeightedSelector{}
+}
+
+// Select returns a random connection, weighted by latency.
+func (s *LatencyWeightedSelector) Select(conns []Connection) (Connection, error) {
+	if len(conns) == 0 {
+		return nil, errNoConnection
+	}
+
+	latencies := make([]time.Duration, len(conns))
+	var fastest time.Duration
+	for i, conn := range conns {
+		latencies[i] = conn.Latency()
+		if l := latencies[i]; l > 0 && (fastest == 0 || l < fastest) {
+			fastest = l
+		}
+	}
+	if fastest == 0 {
+		// No samples yet
+		return conns[rand.IntN(len(conns))], nil
+	}
+
+	weights := make([]float64, len(conns))
+	var total float64
+	for i, l := range latencies {
+		if l <= 0 {
+			l = fastest
+		}
+		weights[i] = 1 / float64(l)
+		total += weights[i]
+	}
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return conns[i], nil
+		}
+	}
+	return conns[len(conns)-1], nil
+}
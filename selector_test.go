@@ -0,0 +1,201 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testConnection is a Connection with fixed statistics.
+type testConnection struct {
+	url      string
+	inFlight int64
+	latency  time.Duration
+}
+
+func (c *testConnection) NodeID() string         { return c.url }
+func (c *testConnection) URL() string            { return c.url }
+func (c *testConnection) IsDead() bool           { return false }
+func (c *testConnection) InFlight() int64        { return c.inFlight }
+func (c *testConnection) Latency() time.Duration { return c.latency }
+
+func selectN(t *testing.T, selector Selector, conns []Connection, n int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		conn, err := selector.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[conn.URL()]++
+	}
+	return counts
+}
+
+func TestRoundRobinSelector(t *testing.T) {
+	conns := []Connection{
+		&testConnection{url: "http://127.0.0.1:9200"},
+		&testConnection{url: "http://127.0.0.1:9201"},
+		&testConnection{url: "http://127.0.0.1:9202"},
+	}
+	selector := NewRoundRobinSelector()
+	for i := 0; i < 6; i++ {
+		conn, err := selector.Select(conns)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := conns[i%3].URL(), conn.URL(); want != have {
+			t.Fatalf("#%d: expected %s; got: %s", i, want, have)
+		}
+	}
+}
+
+func TestRandomSelector(t *testing.T) {
+	conns := []Connection{
+		&testConnection{url: "http://127.0.0.1:9200"},
+		&testConnection{url: "http://127.0.0.1:9201"},
+	}
+	counts := selectN(t, NewRandomSelector(), conns, 1000)
+	for _, conn := range conns {
+		if counts[conn.URL()] == 0 {
+			t.Fatalf("expected %s to be selected at least once", conn.URL())
+		}
+	}
+}
+
+func TestLeastInFlightSelector(t *testing.T) {
+	conns := []Connection{
+		&testConnection{url: "http://127.0.0.1:9200", inFlight: 5},
+		&testConnection{url: "http://127.0.0.1:9201", inFlight: 1},
+		&testConnection{url: "http://127.0.0.1:9202", inFlight: 3},
+	}
+	counts := selectN(t, NewLeastInFlightSelector(), conns, 10)
+	if want, have := 10, counts["http://127.0.0.1:9201"]; want != have {
+		t.Fatalf("expected %d; got: %d (%v)", want, have, counts)
+	}
+
+	// Ties are broken in turn
+	conns = []Connection{
+		&testConnection{url: "http://127.0.0.1:9200"},
+		&testConnection{url: "http://127.0.0.1:9201"},
+	}
+	counts = selectN(t, NewLeastInFlightSelector(), conns, 10)
+	if want, have := 5, counts["http://127.0.0.1:9200"]; want != have {
+		t.Fatalf("expected %d; got: %d (%v)", want, have, counts)
+	}
+}
+
+func TestLatencyWeightedSelector(t *testing.T) {
+	conns := []Connection{
+		&testConnection{url: "http://127.0.0.1:9200", latency: 10 * time.Millisecond},
+		&testConnection{url: "http://127.0.0.1:9201", latency: 90 * time.Millisecond},
+	}
+	counts := selectN(t, NewLatencyWeightedSelector(), conns, 10000)
+	fast, slow := counts["http://127.0.0.1:9200"], counts["http://127.0.0.1:9201"]
+	if slow == 0 {
+		t.Fatal("expected slow node to receive some traffic")
+	}
+	// The fast node should get ~90% of the requests
+	if ratio := float64(fast) / float64(fast+slow); ratio < 0.85 || ratio > 0.95 {
+		t.Fatalf("expected fast node to get ~90%% of requests; got: %.2f (%v)", ratio, counts)
+	}
+}
+
+func TestLatencyWeightedSelectorWithoutSamples(t *testing.T) {
+	conns := []Connection{
+		&testConnection{url: "http://127.0.0.1:9200", latency: 10 * time.Millisecond},
+		&testConnection{url: "http://127.0.0.1:9201"},
+	}
+	counts := selectN(t, NewLatencyWeightedSelector(), conns, 1000)
+	if counts["http://127.0.0.1:9201"] < 300 {
+		t.Fatalf("expected new node to be treated like the fastest one; got: %v", counts)
+	}
+}
+
+func TestClientSetSelector(t *testing.T) {
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetSelector(NewLeastInFlightSelector()),
+		SetURL("http://opensearch.svc:9200", "http://opensearch.svc:9201"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.conns[0].beginRequest()
+	for i := 0; i < 3; i++ {
+		c, err := client.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, have := client.conns[1].URL(), c.URL(); want != have {
+			t.Fatalf("expected %s; got: %s", want, have)
+		}
+	}
+	client.conns[0].endRequest(time.Millisecond, http.StatusOK, false, nil)
+	if want, have := int64(0), client.conns[0].InFlight(); want != have {
+		t.Fatalf("expected %d in flight; got: %d", want, have)
+	}
+	if want, have := time.Millisecond, client.conns[0].Latency(); want != have {
+		t.Fatalf("expected latency of %v; got: %v", want, have)
+	}
+}
+
+func TestLatencyWeightedSelectorIgnoresFastErrors(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer healthy.Close()
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"type":"unavailable_shards_exception","reason":"no shards"},"status":503}`))
+	}))
+	defer unavailable.Close()
+
+	client, err := NewClient(
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetSelector(NewLatencyWeightedSelector()),
+		SetURL(healthy.URL, unavailable.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed int
+	for i := 0; i < 100; i++ {
+		_, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"})
+		if IsStatusCode(err, http.StatusServiceUnavailable) {
+			failed++
+		}
+	}
+
+	// Before, the fast errors made the unavailable node look ~50x faster,
+	// so it received nearly all requests; now it is treated like a node
+	// without samples
+	if failed > 70 {
+		t.Fatalf("expected the unavailable node not to be preferred; got %d of 100 requests", failed)
+	}
+	stats := client.ConnectionStats()
+	for _, st := range stats {
+		if st.URL != unavailable.URL {
+			continue
+		}
+		if want, have := int64(0), st.Successes; want != have {
+			t.Fatalf("expected %d successes; got: %d", want, have)
+		}
+		if want, have := int64(failed), st.Failures; want != have {
+			t.Fatalf("expected %d failures; got: %d", want, have)
+		}
+	}
+	if want, have := time.Duration(0), client.conns[1].Latency(); want != have {
+		t.Fatalf("expected no latency for the unavailable node; got: %v", have)
+	}
+}
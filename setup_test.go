@@ -335,16 +335,16 @@ func setupTestClient(t logger, options ...ClientOptionFunc) (client *Client) {
 
 	// Log deprecations during tests
 	if loglevel := *logDeprecations; loglevel != "off" {
-		client.deprecationlog = func(req *http.Request, res *http.Response) {
-			for _, warning := range res.Header["Warning"] {
-				if !*logTypesRemoval && strings.Contains(warning, "[types removal]") {
+		client.deprecationHandler = func(ctx context.Context, d *Deprecation) {
+			for _, warning := range d.Warnings {
+				if !*logTypesRemoval && strings.Contains(warning.Text, "[types removal]") {
 					continue
 				}
 				switch loglevel {
 				default:
-					t.Logf("[%s] Deprecation warning: %s", req.URL, warning)
+					t.Logf("[%s %s] Deprecation warning: %s", d.Method, d.Path, warning.Text)
 				case "fail", "error":
-					t.Errorf("[%s] Deprecation warning: %s", req.URL, warning)
+					t.Errorf("[%s %s] Deprecation warning: %s", d.Method, d.Path, warning.Text)
 				}
 			}
 		}
@@ -0,0 +1,101 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// cancelTasksTimeout is the time allowed to find and cancel the tasks of
+// a request after the caller's context is done.
+const cancelTasksTimeout = 10 * time.Second
+
+// performCancellable performs a request and, if the context is done
+// before the response arrives, cancels the tasks the request has started
+// in the cluster. The tasks are found by the X-Opaque-Id header of the
+// request, which is unique to the request: If the caller has set an
+// opaque id, e.g. with WithOpaqueID, a unique suffix is appended, so that
+// other requests with the same opaque id are not cancelled.
+func (c *Client) performCancellable(ctx context.Context, opt PerformRequestOptions, perform PerformRequestFunc) (*Response, error) {
+	if ctx.Err() != nil {
+		return perform(ctx, opt)
+	}
+	opaqueID := opt.Headers.Get(HeaderOpaqueID)
+	if opaqueID == "" {
+		opaqueID, _ = OpaqueIDFromContext(ctx)
+	}
+	if opaqueID == "" {
+		opaqueID = newOpaqueID()
+	} else {
+		opaqueID += "/" + randomHex(4)
+	}
+	// Headers given explicitly take precedence over the context
+	headers := opt.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(HeaderOpaqueID, opaqueID)
+	opt.Headers = headers
+
+	res, err := perform(ctx, opt)
+	if err != nil && ctx.Err() != nil {
+		// The caller has given up already, so don't make it wait any longer
+		go c.cancelTasks(opaqueID)
+	}
+	return res, err
+}
+
+// cancelTasks cancels the tasks started with the given opaque id.
+// Child tasks are cancelled with their parent. The requests to the
+// Tasks API are not related to the cancelled request, e.g. they are not
+// sent with its opaque id or trace context.
+func (c *Client) cancelTasks(opaqueID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTasksTimeout)
+	defer cancel()
+
+	tasks, err := c.TasksByOpaqueID(ctx, opaqueID)
+	if err != nil {
+		c.log.WarnContext(ctx, "opensearch: cannot find tasks to cancel",
+			slog.String("opaque_id", opaqueID),
+			slog.Any("error", err))
+		return
+	}
+	for _, task := range tasks {
+		if task.ParentTaskId != "" || !task.Cancellable || task.Cancelled {
+			continue
+		}
+		_, err := c.TasksCancel().TaskIdFromNodeAndId(task.Node, task.Id).Do(ctx)
+		if err != nil && !IsNotFound(err) {
+			c.log.WarnContext(ctx, "opensearch: cannot cancel task",
+				slog.String("opaque_id", opaqueID),
+				slog.String("node", task.Node),
+				slog.Int64("task", task.Id),
+				slog.Any("error", err))
+			continue
+		}
+		c.log.DebugContext(ctx, "opensearch: cancelled task",
+			slog.String("opaque_id", opaqueID),
+			slog.String("node", task.Node),
+			slog.Int64("task", task.Id),
+			slog.String("action", task.Action))
+	}
+}
+
+// newOpaqueID returns a random opaque id.
+func newOpaqueID() string {
+	return "opensearch-" + randomHex(16)
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
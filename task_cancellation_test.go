@@ -0,0 +1,161 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// taskServer simulates a cluster that runs searches until the client
+// goes away, and records the tasks cancelled via the Tasks API.
+type taskServer struct {
+	siblingID string // opaque id of another search running in the cluster, if any
+
+	mu        sync.Mutex
+	opaqueID  string      // opaque id of the last search
+	cancelled []string    // ids of the cancelled tasks
+	cleanup   http.Header // headers of the last request to the Tasks API
+}
+
+func (s *taskServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/_search"):
+		s.mu.Lock()
+		s.opaqueID = r.Header.Get(HeaderOpaqueID)
+		s.mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	case r.URL.Path == "/_tasks":
+		s.mu.Lock()
+		opaqueID := s.opaqueID
+		s.cleanup = r.Header.Clone()
+		s.mu.Unlock()
+		fmt.Fprintf(w, `{"nodes":{"node1":{"tasks":{
+			"node1:5":{"node":"node1","id":5,"action":"indices:data/read/search","cancellable":true,"headers":{"X-Opaque-Id":%q}},
+			"node1:6":{"node":"node1","id":6,"action":"indices:data/read/search[phase/query]","cancellable":true,"parent_task_id":"node1:5","headers":{"X-Opaque-Id":%q}},
+			"node1:7":{"node":"node1","id":7,"action":"indices:data/read/search","cancellable":true,"headers":{"X-Opaque-Id":"other"}},
+			"node1:8":{"node":"node1","id":8,"action":"indices:data/read/search","cancellable":true,"headers":{"X-Opaque-Id":%q}}
+		}}}}`, opaqueID, opaqueID, s.siblingID)
+	case strings.HasPrefix(r.URL.Path, "/_tasks/") && strings.HasSuffix(r.URL.Path, "/_cancel"):
+		s.mu.Lock()
+		s.cancelled = append(s.cancelled, strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/_tasks/"), "/_cancel"))
+		s.mu.Unlock()
+		w.Write([]byte(`{"nodes":{}}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{}`))
+	}
+}
+
+func (s *taskServer) waitForCancelled(n int) []string {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		cancelled := s.cancelled
+		s.mu.Unlock()
+		if len(cancelled) >= n {
+			return cancelled
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancelled
+}
+
+func TestSearchCancelTasks(t *testing.T) {
+	handler := &taskServer{}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Search("test").CancelTasks(true).Do(ctx)
+	if !IsContextErr(err) {
+		t.Fatalf("expected context error, have %v", err)
+	}
+
+	// Only the parent task with the opaque id of the search is cancelled
+	cancelled := handler.waitForCancelled(1)
+	if want, have := []string{"node1:5"}, cancelled; len(have) != 1 || want[0] != have[0] {
+		t.Fatalf("want cancelled tasks %v, have %v", want, have)
+	}
+	if !strings.HasPrefix(handler.opaqueID, "opensearch-") {
+		t.Fatalf("expected generated opaque id, have %q", handler.opaqueID)
+	}
+}
+
+func TestSearchCancelTasksWithOpaqueID(t *testing.T) {
+	handler := &taskServer{siblingID: "import-42"}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithTraceParent(WithOpaqueID(context.Background(), "import-42"), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = client.Search("test").CancelTasks(true).Do(ctx)
+	if !IsContextErr(err) {
+		t.Fatalf("expected context error, have %v", err)
+	}
+	// Only the search is cancelled, not another one sharing the opaque id
+	cancelled := handler.waitForCancelled(1)
+	time.Sleep(50 * time.Millisecond)
+	if want, have := []string{"node1:5"}, handler.waitForCancelled(1); len(have) != 1 || want[0] != have[0] {
+		t.Fatalf("want cancelled tasks %v, have %v (%v)", want, have, cancelled)
+	}
+	if !strings.HasPrefix(handler.opaqueID, "import-42/") {
+		t.Fatalf("want opaque id with prefix %q, have %q", "import-42/", handler.opaqueID)
+	}
+
+	// The Tasks API is not called in the context of the search
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	for _, name := range []string{HeaderOpaqueID, HeaderTraceParent} {
+		if v := handler.cleanup.Get(name); v != "" {
+			t.Fatalf("want no %s header when cancelling, have %q", name, v)
+		}
+	}
+}
+
+func TestSearchWithoutCancelTasks(t *testing.T) {
+	handler := &taskServer{}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Search("test").Do(ctx)
+	if !IsContextErr(err) {
+		t.Fatalf("expected context error, have %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if want, have := 0, len(handler.waitForCancelled(0)); want != have {
+		t.Fatalf("want %d cancelled tasks, have %d", want, have)
+	}
+	if want, have := "", handler.opaqueID; want != have {
+		t.Fatalf("want no opaque id, have %q", have)
+	}
+}
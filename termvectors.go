@@ -337,6 +337,9 @@ func (s *TermvectorsService) Do(ctx context.Context) (*TermvectorsResponse, erro
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
 
 	// Get URL for request
 	path, params, err := s.buildURL()
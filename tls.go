@@ -0,0 +1,249 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+)
+
+// TLSOptions specifies how to set up TLS to connect to Opensearch,
+// e.g. with a custom CA bundle and a client certificate for mutual TLS.
+// See NewTLSConfig.
+type TLSOptions struct {
+	// CAFile is the path to a PEM-encoded bundle of CA certificates used
+	// to verify the server. If empty, the system roots are used.
+	CAFile string
+
+	// CertFile and KeyFile are the paths to a PEM-encoded client
+	// certificate and its private key, used for mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName is used to verify the hostname of the server. If empty,
+	// the host of the URL is used. With a CAFile, it is required if the
+	// config is used outside of SetTLSConfig and the host is an IP address
+	// or the dialer does not set the server name, e.g. with tls.Client.
+	ServerName string
+
+	// MinVersion is the minimum TLS version, e.g. tls.VersionTLS13.
+	// It is tls.VersionTLS12 by default.
+	MinVersion uint16
+
+	// InsecureSkipVerify disables the verification of the server
+	// certificate. Use this for testing only.
+	InsecureSkipVerify bool
+}
+
+// NewTLSConfig returns a tls.Config for the given options.
+//
+// The CA bundle and the client certificate are read from disk on creation
+// and reloaded when the files change, e.g. after a certificate renewal.
+// Long-lived clients pick up the new certificates with the next TLS
+// handshake, i.e. when a new connection is opened. If a rotated file
+// cannot be loaded, e.g. because the certificate has been written but
+// the key has not, the previous certificates are used.
+func NewTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("opensearch: both a client certificate and a key file are required for mutual TLS")
+	}
+
+	cfg := &tls.Config{
+		ServerName:         opts.ServerName,
+		MinVersion:         opts.MinVersion,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+	if cfg.MinVersion == 0 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+
+	if opts.CertFile != "" {
+		certs := &certificateReloader{certFile: opts.CertFile, keyFile: opts.KeyFile}
+		if _, err := certs.certificate(); err != nil {
+			return nil, err
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.certificate()
+		}
+	}
+
+	if opts.CAFile != "" && !opts.InsecureSkipVerify {
+		roots := &certPoolReloader{caFile: opts.CAFile}
+		if _, err := roots.pool(); err != nil {
+			return nil, err
+		}
+		// The roots of a tls.Config cannot be changed after it is in use,
+		// so we verify the server ourselves with the current CA bundle.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			pool, err := roots.pool()
+			if err != nil {
+				return err
+			}
+			return verifyServerCertificate(cs, pool, opts.ServerName)
+		}
+	}
+
+	return cfg, nil
+}
+
+// newTLSTransport returns a transport that connects with the given TLS
+// config. If the config verifies the server itself, see NewTLSConfig, the
+// dialed host is used to verify the hostname if the handshake has no
+// server name, e.g. for IP addresses, which are not sent with SNI.
+func newTLSTransport(cfg *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg
+	if !cfg.InsecureSkipVerify || cfg.VerifyConnection == nil {
+		return transport
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		// The transport may have added protocols to its config by now
+		conf := transport.TLSClientConfig.Clone()
+		if conf.ServerName == "" {
+			conf.ServerName = host
+		}
+		serverName, verify := conf.ServerName, conf.VerifyConnection
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if cs.ServerName == "" {
+				cs.ServerName = serverName
+			}
+			return verify(cs)
+		}
+		return (&tls.Dialer{NetDialer: dialer, Config: conf}).DialContext(ctx, network, addr)
+	}
+	return transport
+}
+
+// verifyServerCertificate verifies the certificate chain and the hostname
+// of the server, as crypto/tls does when InsecureSkipVerify is false. The
+// hostname is the server name of the connection, or serverName if the
+// dialer did not set one. Like crypto/tls, it refuses to skip the hostname
+// verification if both are empty.
+func verifyServerCertificate(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("opensearch: server did not present a certificate")
+	}
+	if cs.ServerName != "" {
+		serverName = cs.ServerName
+	}
+	if serverName == "" {
+		return errors.New("opensearch: cannot verify the hostname of the server without a server name")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       serverName,
+	})
+	return err
+}
+
+// fileStamp identifies a version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(name string) (fileStamp, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// certificateReloader loads a client certificate and its key, and
+// reloads them when one of the files changes.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.Mutex
+	cert     *tls.Certificate
+	certStat fileStamp
+	keyStat  fileStamp
+}
+
+func (r *certificateReloader) certificate() (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certStat, certErr := statFile(r.certFile)
+	keyStat, keyErr := statFile(r.keyFile)
+	if certErr == nil && keyErr == nil && r.cert != nil && certStat == r.certStat && keyStat == r.keyStat {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// Keep using the previous certificate, e.g. while being rotated
+			return r.cert, nil
+		}
+		return nil, errors.Wrap(err, "opensearch: unable to load client certificate")
+	}
+	r.cert, r.certStat, r.keyStat = &cert, certStat, keyStat
+	return r.cert, nil
+}
+
+// certPoolReloader loads a bundle of CA certificates, and reloads it
+// when the file changes.
+type certPoolReloader struct {
+	caFile string
+
+	mu     sync.Mutex
+	roots  *x509.CertPool
+	caStat fileStamp
+}
+
+func (r *certPoolReloader) pool() (*x509.CertPool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	caStat, err := statFile(r.caFile)
+	if err == nil && r.roots != nil && caStat == r.caStat {
+		return r.roots, nil
+	}
+
+	roots, err := loadCertPool(r.caFile)
+	if err != nil {
+		if r.roots != nil {
+			// Keep using the previous CA bundle, e.g. while being rotated
+			return r.roots, nil
+		}
+		return nil, err
+	}
+	r.roots, r.caStat = roots, caStat
+	return r.roots, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "opensearch: unable to read CA file")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf("opensearch: no certificates found in CA file %s", caFile)
+	}
+	return pool, nil
+}
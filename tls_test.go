@@ -0,0 +1,338 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disaster37/opensearch/v2/config"
+)
+
+// testCA is a certificate authority to issue certificates in tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a PEM-encoded certificate and key signed by the CA.
+func (ca *testCA) issue(t *testing.T, name string, serial int64, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestFile writes the file with a modification time in the future,
+// so that a rewrite within the same second is detected.
+func writeTestFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newMutualTLSServer starts a server that requires client certificates
+// signed by clientCA, and reports the common name of the client.
+func newMutualTLSServer(t *testing.T, serverCA, clientCA *testCA) *httptest.Server {
+	t.Helper()
+	certPEM, keyPEM := serverCA.issue(t, "server", 2, x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA.cert)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"client":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	ts.StartTLS()
+	return ts
+}
+
+func performClientNameRequest(t *testing.T, client *Client) string {
+	t.Helper()
+	res, err := client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Client string `json:"client"`
+	}
+	if err := client.decoder.Decode(res.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.Client
+}
+
+func TestNewTLSConfigRequiresCertAndKey(t *testing.T) {
+	_, err := NewTLSConfig(TLSOptions{CertFile: "client.pem"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestNewTLSConfigWithMissingCAFile(t *testing.T) {
+	_, err := NewTLSConfig(TLSOptions{CAFile: filepath.Join(t.TempDir(), "ca.pem")})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestSetTLSConfigWithMutualTLS(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	ts := newMutualTLSServer(t, ca, ca)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	now := time.Now()
+	writeTestFile(t, caFile, ca.pem, now)
+	certPEM, keyPEM := ca.issue(t, "client-1", 3, x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM, now)
+	writeTestFile(t, keyFile, keyPEM, now)
+
+	tlsConfig, err := NewTLSConfig(TLSOptions{
+		CAFile:   caFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "client-1", performClientNameRequest(t, client); want != have {
+		t.Fatalf("expected client %q; got: %q", want, have)
+	}
+
+	// Rotate the client certificate; the new one is used for new connections
+	certPEM, keyPEM = ca.issue(t, "client-2", 4, x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM, now.Add(time.Minute))
+	writeTestFile(t, keyFile, keyPEM, now.Add(time.Minute))
+	client.c.(*http.Client).CloseIdleConnections()
+
+	if want, have := "client-2", performClientNameRequest(t, client); want != have {
+		t.Fatalf("expected client %q; got: %q", want, have)
+	}
+}
+
+func TestSetTLSConfigReloadsCAFile(t *testing.T) {
+	oldCA := newTestCA(t, "old-ca")
+	newCA := newTestCA(t, "new-ca")
+	ts := newMutualTLSServer(t, newCA, oldCA)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	now := time.Now()
+	writeTestFile(t, caFile, oldCA.pem, now)
+	certPEM, keyPEM := oldCA.issue(t, "client", 3, x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM, now)
+	writeTestFile(t, keyFile, keyPEM, now)
+
+	tlsConfig, err := NewTLSConfig(TLSOptions{
+		CAFile:   caFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(
+		SetURL(ts.URL),
+		SetSniff(false),
+		SetHealthcheck(false),
+		SetTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server certificate is not signed by the old CA
+	_, err = client.PerformRequest(context.Background(), PerformRequestOptions{
+		Method: "GET",
+		Path:   "/",
+	})
+	if err == nil {
+		t.Fatal("expected certificate verification to fail")
+	}
+
+	client.conns[0].MarkAsAlive()
+
+	writeTestFile(t, caFile, newCA.pem, now.Add(time.Minute))
+	if want, have := "client", performClientNameRequest(t, client); want != have {
+		t.Fatalf("expected client %q; got: %q", want, have)
+	}
+}
+
+func TestNewTLSConfigRequiresServerName(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	certPEM, keyPEM := ca.issue(t, "server", 2, x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeTestFile(t, caFile, ca.pem, time.Now())
+	handshake := func(opts TLSOptions) error {
+		cfg, err := NewTLSConfig(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := tls.Client(raw, cfg)
+		defer conn.Close()
+		return conn.Handshake()
+	}
+
+	// A dialer that does not set the server name must not skip the hostname verification
+	if err := handshake(TLSOptions{CAFile: caFile}); err == nil {
+		t.Fatal("expected handshake to fail without a server name")
+	}
+	if err := handshake(TLSOptions{CAFile: caFile, ServerName: "localhost"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := handshake(TLSOptions{CAFile: caFile, ServerName: "example.com"}); err == nil {
+		t.Fatal("expected handshake to fail with the wrong server name")
+	}
+}
+
+func TestNewClientFromConfigWithTLS(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	ts := newMutualTLSServer(t, ca, ca)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	now := time.Now()
+	writeTestFile(t, caFile, ca.pem, now)
+	certPEM, keyPEM := ca.issue(t, "client", 3, x509.ExtKeyUsageClientAuth)
+	writeTestFile(t, certFile, certPEM, now)
+	writeTestFile(t, keyFile, keyPEM, now)
+
+	cfg, err := config.Parse(ts.URL + "?sniff=false&healthcheck=false&tls_ca_file=" + caFile + "&tls_cert_file=" + certFile + "&tls_key_file=" + keyFile + "&tls_min_version=1.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClientFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "client", performClientNameRequest(t, client); want != have {
+		t.Fatalf("expected client %q; got: %q", want, have)
+	}
+}
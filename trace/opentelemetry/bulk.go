@@ -0,0 +1,81 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opentelemetry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// bulkPrefixLen is the number of bytes of a line that countBulkItems
+// looks at to find out whether it is a delete action.
+const bulkPrefixLen = 64
+
+// countBulkItems returns the number of actions in the body of a bulk
+// request, or -1 if the body cannot be read without consuming it.
+func countBulkItems(req *http.Request) int64 {
+	if req.GetBody == nil {
+		return -1
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return -1
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return -1
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	// Every action is on a line of its own, followed by a line with
+	// the document, except for delete actions
+	var (
+		n            int64
+		expectSource bool
+		prefix       []byte
+	)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadSlice('\n')
+		// Copy the start of the line, as reading on overwrites it
+		line = bytes.TrimSpace(line)
+		prefix = append(prefix[:0], line[:min(len(line), bulkPrefixLen)]...)
+		for err == bufio.ErrBufferFull {
+			// Skip the rest of long lines, e.g. large documents
+			_, err = br.ReadSlice('\n')
+		}
+		if len(prefix) > 0 {
+			if expectSource {
+				expectSource = false
+			} else {
+				n++
+				expectSource = !isDeleteAction(prefix)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				return -1
+			}
+			return n
+		}
+	}
+}
+
+// isDeleteAction returns true if the line is a delete action,
+// e.g. {"delete":{"_index":"tweets","_id":"1"}}.
+func isDeleteAction(line []byte) bool {
+	line = bytes.TrimPrefix(line, []byte("{"))
+	line = bytes.TrimLeft(line, " \t")
+	return bytes.HasPrefix(line, []byte(`"delete"`))
+}
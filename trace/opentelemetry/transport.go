@@ -6,15 +6,45 @@ package opentelemetry
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/disaster37/opensearch/v2/internal/operation"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Transport for tracing Elastic operations.
+const (
+	// instrumentationName is the name of the tracer and the meter.
+	instrumentationName = "github.com/disaster37/opensearch/v2/trace/opentelemetry"
+
+	// bulkItemsMetricName is the name of the histogram of the number of
+	// items per bulk request.
+	bulkItemsMetricName = "opensearch.client.bulk.items"
+)
+
+// Transport for tracing Opensearch operations.
+//
+// Spans are named after the API operation, e.g. search, bulk or
+// ism.put_policy, and carry the database semantic conventions, e.g.
+// db.system, db.operation.name, db.collection.name (the index, if any)
+// and server.address (the node).
+//
+// The duration of each request is recorded in the db.client.operation.duration
+// histogram, and the number of items of each bulk request in the
+// opensearch.client.bulk.items histogram.
 type Transport struct {
-	rt http.RoundTripper
+	rt             http.RoundTripper
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+
+	tracer    trace.Tracer
+	duration  metric.Float64Histogram
+	bulkItems metric.Int64Histogram
 }
 
 // Option signature for specifying options, e.g. WithRoundTripper.
@@ -29,27 +59,82 @@ func WithRoundTripper(rt http.RoundTripper) Option {
 	}
 }
 
-// NewTransport specifies a transport that will trace Elastic
-// and report back via OpenTracing.
+// WithTracerProvider specifies the trace.TracerProvider to create spans
+// with. If it is nil (default), the global TracerProvider is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(t *Transport) {
+		t.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider specifies the metric.MeterProvider to record metrics
+// with. If it is nil (default), the global MeterProvider is used.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(t *Transport) {
+		t.meterProvider = mp
+	}
+}
+
+// NewTransport specifies a transport that will trace Opensearch
+// and report back via OpenTelemetry.
 func NewTransport(opts ...Option) *Transport {
 	t := &Transport{}
 	for _, o := range opts {
 		o(t)
 	}
+	if t.tracerProvider == nil {
+		t.tracerProvider = otel.GetTracerProvider()
+	}
+	if t.meterProvider == nil {
+		t.meterProvider = otel.GetMeterProvider()
+	}
+	t.tracer = t.tracerProvider.Tracer(instrumentationName)
+
+	meter := t.meterProvider.Meter(instrumentationName)
+	var err error
+	t.duration, err = meter.Float64Histogram(
+		semconv.DBClientOperationDurationName,
+		metric.WithUnit(semconv.DBClientOperationDurationUnit),
+		metric.WithDescription(semconv.DBClientOperationDurationDescription),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
+	t.bulkItems, err = meter.Int64Histogram(
+		bulkItemsMetricName,
+		metric.WithUnit("{item}"),
+		metric.WithDescription("Number of items in bulk requests."),
+	)
+	if err != nil {
+		otel.Handle(err)
+	}
 	return t
 }
 
-// RoundTrip captures the request and starts an OpenTracing span
-// for Elastic PerformRequest operation.
+// RoundTrip captures the request and starts an OpenTelemetry span
+// named after the Opensearch API operation.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	ctx, span := otel.Tracer("Elastic").Start(req.Context(), "PerformRequest")
-	defer span.End()
+	op := operation.FromRequest(req.Method, req.URL.Path)
 
-	req = req.WithContext(ctx)
+	// See Database (https://opentelemetry.io/docs/specs/semconv/database/database-spans/)
+	attrs := []attribute.KeyValue{
+		semconv.DBSystemOpensearch,
+		semconv.DBOperationName(op.Name),
+		semconv.ServerAddress(req.URL.Hostname()),
+	}
+	if port, err := strconv.Atoi(req.URL.Port()); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	// Metrics do not include the index to keep the cardinality low
+	metricAttrs := attrs
 
+	spanAttrs := append([]attribute.KeyValue(nil), attrs...)
+	if op.Index != "" {
+		spanAttrs = append(spanAttrs, semconv.DBCollectionName(op.Index))
+	}
 	// See General (https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/span-general.md)
 	// and HTTP (https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/trace/semantic_conventions/http.md)
-	span.SetAttributes(
+	spanAttrs = append(spanAttrs,
 		attribute.String("code.namespace", "github.com/disaster37/opensearch/v2"),
 		attribute.String("code.function", "PerformRequest"),
 		attribute.String("http.url", req.URL.Redacted()),
@@ -60,6 +145,20 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		attribute.String("http.user_agent", req.UserAgent()),
 	)
 
+	ctx, span := t.tracer.Start(req.Context(), op.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(spanAttrs...),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+
+	var bulkItems int64 = -1
+	if op.Name == "bulk" && t.bulkItems != nil {
+		bulkItems = countBulkItems(req)
+	}
+
+	start := time.Now()
 	var (
 		resp *http.Response
 		err  error
@@ -69,12 +168,28 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	} else {
 		resp, err = http.DefaultTransport.RoundTrip(req)
 	}
+	elapsed := time.Since(start)
+
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
+		metricAttrs = append(metricAttrs, semconv.ErrorTypeOther)
 	}
 	if resp != nil {
 		span.SetAttributes(attribute.Int64("http.status_code", int64(resp.StatusCode)))
+		metricAttrs = append(metricAttrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+		// Client errors like 404 are expected by e.g. the Exists API
+		if resp.StatusCode >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+			metricAttrs = append(metricAttrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+		}
+	}
+
+	if t.duration != nil {
+		t.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(metricAttrs...))
+	}
+	if bulkItems >= 0 {
+		t.bulkItems.Record(ctx, bulkItems, metric.WithAttributes(metricAttrs...))
 	}
 
 	return resp, err
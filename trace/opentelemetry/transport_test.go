@@ -0,0 +1,347 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opentelemetry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/disaster37/opensearch/v2"
+)
+
+// recorder records spans and metrics for tests.
+type recorder struct {
+	mu      sync.Mutex
+	spans   []*recordedSpan
+	metrics map[string][]recordedMeasurement
+}
+
+type recordedSpan struct {
+	tracenoop.Span
+	rec    *recorder
+	name   string
+	kind   trace.SpanKind
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	ended  bool
+}
+
+type recordedMeasurement struct {
+	value float64
+	attrs attribute.Set
+}
+
+func newRecorder() *recorder {
+	return &recorder{metrics: make(map[string][]recordedMeasurement)}
+}
+
+// -- Tracing --
+
+type recordingTracerProvider struct {
+	tracenoop.TracerProvider
+	rec *recorder
+}
+
+func (tp recordingTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{rec: tp.rec}
+}
+
+type recordingTracer struct {
+	tracenoop.Tracer
+	rec *recorder
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	span := &recordedSpan{
+		rec:   t.rec,
+		name:  name,
+		kind:  cfg.SpanKind(),
+		attrs: make(map[attribute.Key]attribute.Value),
+	}
+	span.SetAttributes(cfg.Attributes()...)
+	t.rec.mu.Lock()
+	t.rec.spans = append(t.rec.spans, span)
+	t.rec.mu.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	for _, attr := range kv {
+		s.attrs[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.ended = true
+}
+
+// -- Metrics --
+
+type recordingMeterProvider struct {
+	metricnoop.MeterProvider
+	rec *recorder
+}
+
+func (mp recordingMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return recordingMeter{rec: mp.rec}
+}
+
+type recordingMeter struct {
+	metricnoop.Meter
+	rec *recorder
+}
+
+func (m recordingMeter) Float64Histogram(name string, _ ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	return recordingFloat64Histogram{rec: m.rec, name: name}, nil
+}
+
+func (m recordingMeter) Int64Histogram(name string, _ ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	return recordingInt64Histogram{rec: m.rec, name: name}, nil
+}
+
+type recordingFloat64Histogram struct {
+	metricnoop.Float64Histogram
+	rec  *recorder
+	name string
+}
+
+func (h recordingFloat64Histogram) Record(_ context.Context, value float64, opts ...metric.RecordOption) {
+	h.rec.record(h.name, value, metric.NewRecordConfig(opts).Attributes())
+}
+
+type recordingInt64Histogram struct {
+	metricnoop.Int64Histogram
+	rec  *recorder
+	name string
+}
+
+func (h recordingInt64Histogram) Record(_ context.Context, value int64, opts ...metric.RecordOption) {
+	h.rec.record(h.name, float64(value), metric.NewRecordConfig(opts).Attributes())
+}
+
+func (r *recorder) record(name string, value float64, attrs attribute.Set) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = append(r.metrics[name], recordedMeasurement{value: value, attrs: attrs})
+}
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/_bulk":
+			w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+		case "/_plugins/_ism/policies/hot-warm":
+			w.Write([]byte(`{"_id":"hot-warm"}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"unexpected"}`))
+		}
+	}))
+	defer ts.Close()
+
+	rec := newRecorder()
+	tr := NewTransport(
+		WithTracerProvider(recordingTracerProvider{rec: rec}),
+		WithMeterProvider(recordingMeterProvider{rec: rec}),
+	)
+	client, err := opensearch.NewClient(
+		opensearch.SetURL(ts.URL),
+		opensearch.SetHttpClient(&http.Client{Transport: tr}),
+		opensearch.SetHealthcheck(false),
+		opensearch.SetSniff(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bulk with three items
+	_, err = client.Bulk().Add(
+		opensearch.NewBulkIndexRequest().Index("tweets").Id("1").Doc(map[string]string{"user": "olivere"}),
+		opensearch.NewBulkDeleteRequest().Index("tweets").Id("2"),
+		opensearch.NewBulkUpdateRequest().Index("tweets").Id("3").Doc(map[string]string{"user": "sandrae"}),
+	).Do(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Plugin API
+	_, err = client.PerformRequest(context.Background(), opensearch.PerformRequestOptions{
+		Method: "GET",
+		Path:   "/_plugins/_ism/policies/hot-warm",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Server error
+	_, _ = client.Search("tweets").Do(context.Background())
+
+	if want, have := 3, len(rec.spans); want != have {
+		t.Fatalf("expected %d spans; got: %d", want, have)
+	}
+	expected := []struct {
+		Name   string
+		Index  string
+		Status codes.Code
+	}{
+		{"bulk", "", codes.Unset},
+		{"ism.get_policy", "", codes.Unset},
+		{"search", "tweets", codes.Error},
+	}
+	for i, span := range rec.spans {
+		if want, have := expected[i].Name, span.name; want != have {
+			t.Errorf("span #%d: expected name %q; got: %q", i, want, have)
+		}
+		if want, have := trace.SpanKindClient, span.kind; want != have {
+			t.Errorf("span #%d: expected kind %v; got: %v", i, want, have)
+		}
+		if want, have := "opensearch", span.attrs["db.system"].AsString(); want != have {
+			t.Errorf("span #%d: expected db.system %q; got: %q", i, want, have)
+		}
+		if want, have := expected[i].Name, span.attrs["db.operation.name"].AsString(); want != have {
+			t.Errorf("span #%d: expected db.operation.name %q; got: %q", i, want, have)
+		}
+		if want, have := expected[i].Index, span.attrs["db.collection.name"].AsString(); want != have {
+			t.Errorf("span #%d: expected db.collection.name %q; got: %q", i, want, have)
+		}
+		if want, have := "127.0.0.1", span.attrs["server.address"].AsString(); want != have {
+			t.Errorf("span #%d: expected server.address %q; got: %q", i, want, have)
+		}
+		if want, have := expected[i].Status, span.status; want != have {
+			t.Errorf("span #%d: expected status %v; got: %v", i, want, have)
+		}
+		if !span.ended {
+			t.Errorf("span #%d: expected span to be ended", i)
+		}
+	}
+
+	durations := rec.metrics["db.client.operation.duration"]
+	if want, have := 3, len(durations); want != have {
+		t.Fatalf("expected %d durations; got: %d", want, have)
+	}
+	for i, m := range durations {
+		if v, _ := m.attrs.Value("db.operation.name"); v.AsString() != expected[i].Name {
+			t.Errorf("duration #%d: expected db.operation.name %q; got: %q", i, expected[i].Name, v.AsString())
+		}
+		if m.attrs.HasValue("db.collection.name") {
+			t.Errorf("duration #%d: expected no db.collection.name", i)
+		}
+	}
+	if v, _ := durations[2].attrs.Value("error.type"); v.AsString() != "500" {
+		t.Errorf("expected error.type %q; got: %q", "500", v.AsString())
+	}
+
+	items := rec.metrics[bulkItemsMetricName]
+	if want, have := 1, len(items); want != have {
+		t.Fatalf("expected %d bulk item measurements; got: %d", want, have)
+	}
+	if want, have := float64(3), items[0].value; want != have {
+		t.Fatalf("expected %v bulk items; got: %v", want, have)
+	}
+}
+
+func TestTransportBulkItemsWithGzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+	}))
+	defer ts.Close()
+
+	rec := newRecorder()
+	tr := NewTransport(
+		WithTracerProvider(recordingTracerProvider{rec: rec}),
+		WithMeterProvider(recordingMeterProvider{rec: rec}),
+	)
+	client, err := opensearch.NewClient(
+		opensearch.SetURL(ts.URL),
+		opensearch.SetHttpClient(&http.Client{Transport: tr}),
+		opensearch.SetHealthcheck(false),
+		opensearch.SetSniff(false),
+		opensearch.SetGzip(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bulk := client.Bulk()
+	for i := 0; i < 5; i++ {
+		bulk.Add(opensearch.NewBulkDeleteRequest().Index("tweets").Id("1"))
+	}
+	if _, err := bulk.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	items := rec.metrics[bulkItemsMetricName]
+	if want, have := 1, len(items); want != have {
+		t.Fatalf("expected %d bulk item measurements; got: %d", want, have)
+	}
+	if want, have := float64(5), items[0].value; want != have {
+		t.Fatalf("expected %v bulk items; got: %v", want, have)
+	}
+}
+
+func TestTransportBulkItemsWithLongLines(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"took":1,"errors":false,"items":[]}`))
+	}))
+	defer ts.Close()
+
+	rec := newRecorder()
+	tr := NewTransport(
+		WithTracerProvider(recordingTracerProvider{rec: rec}),
+		WithMeterProvider(recordingMeterProvider{rec: rec}),
+	)
+	client, err := opensearch.NewClient(
+		opensearch.SetURL(ts.URL),
+		opensearch.SetHttpClient(&http.Client{Transport: tr}),
+		opensearch.SetHealthcheck(false),
+		opensearch.SetSniff(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Lines longer than the read buffer
+	long := strings.Repeat("x", 10000)
+	bulk := client.Bulk()
+	bulk.Add(opensearch.NewBulkDeleteRequest().Index("tweets").Id(long))
+	bulk.Add(opensearch.NewBulkIndexRequest().Index("tweets").Id("1").Doc(map[string]string{"message": long}))
+	bulk.Add(opensearch.NewBulkDeleteRequest().Index("tweets").Id("2"))
+	if _, err := bulk.Do(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	items := rec.metrics[bulkItemsMetricName]
+	if want, have := 1, len(items); want != have {
+		t.Fatalf("expected %d bulk item measurements; got: %d", want, have)
+	}
+	if want, have := float64(3), items[0].value; want != have {
+		t.Fatalf("expected %v bulk items; got: %v", want, have)
+	}
+}
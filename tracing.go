@@ -0,0 +1,99 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// HeaderOpaqueID is the header that Opensearch uses to correlate
+	// requests with tasks, slow logs and deprecation logs.
+	HeaderOpaqueID = "X-Opaque-Id"
+	// HeaderTraceParent is the W3C Trace Context header.
+	HeaderTraceParent = "traceparent"
+)
+
+type opaqueIDKey struct{}
+type traceParentKey struct{}
+
+// WithOpaqueID returns a context that sends the opaque id in the
+// X-Opaque-Id header of all requests made with it. Opensearch attaches
+// the id to the tasks started by the request and to its slow log entries.
+func WithOpaqueID(ctx context.Context, opaqueID string) context.Context {
+	return context.WithValue(ctx, opaqueIDKey{}, opaqueID)
+}
+
+// OpaqueIDFromContext returns the opaque id set with WithOpaqueID.
+func OpaqueIDFromContext(ctx context.Context) (string, bool) {
+	opaqueID, ok := ctx.Value(opaqueIDKey{}).(string)
+	return opaqueID, ok && opaqueID != ""
+}
+
+// WithTraceParent returns a context that sends the W3C trace context,
+// e.g. "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", in the
+// traceparent header of all requests made with it. The value is sent
+// as is.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the trace context set with WithTraceParent.
+func TraceParentFromContext(ctx context.Context) (string, bool) {
+	traceParent, ok := ctx.Value(traceParentKey{}).(string)
+	return traceParent, ok && traceParent != ""
+}
+
+// setContextHeaders sets the X-Opaque-Id and traceparent headers from
+// the context, unless they have been set on the request explicitly.
+func setContextHeaders(ctx context.Context, header http.Header, explicit http.Header) {
+	if opaqueID, ok := OpaqueIDFromContext(ctx); ok && explicit.Get(HeaderOpaqueID) == "" {
+		header.Set(HeaderOpaqueID, opaqueID)
+	}
+	if traceParent, ok := TraceParentFromContext(ctx); ok && explicit.Get(HeaderTraceParent) == "" {
+		header.Set(HeaderTraceParent, traceParent)
+	}
+}
+
+// TasksByOpaqueID returns the tasks running in the cluster that have
+// been started by requests with the given opaque id, e.g. via
+// WithOpaqueID, ordered by their start time.
+func (c *Client) TasksByOpaqueID(ctx context.Context, opaqueID string) ([]*TaskInfo, error) {
+	res, err := c.TasksList().Detailed(true).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []*TaskInfo
+	for _, node := range res.Nodes {
+		if node == nil {
+			continue
+		}
+		for _, task := range node.Tasks {
+			if task != nil && taskOpaqueID(task) == opaqueID {
+				tasks = append(tasks, task)
+			}
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if tasks[i].StartTimeInMillis != tasks[j].StartTimeInMillis {
+			return tasks[i].StartTimeInMillis < tasks[j].StartTimeInMillis
+		}
+		return tasks[i].Id < tasks[j].Id
+	})
+	return tasks, nil
+}
+
+// taskOpaqueID returns the X-Opaque-Id header of the task.
+func taskOpaqueID(task *TaskInfo) string {
+	for key, value := range task.Headers {
+		if strings.EqualFold(key, HeaderOpaqueID) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package opensearch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextHeaders(t *testing.T) {
+	var header http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false),
+		SetHeaders(http.Header{HeaderOpaqueID: []string{"default"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	traceParent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	ctx := WithTraceParent(WithOpaqueID(context.Background(), "import-42"), traceParent)
+
+	if _, err := client.PerformRequest(ctx, PerformRequestOptions{Method: "GET", Path: "/"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := []string{"import-42"}, header.Values(HeaderOpaqueID); len(have) != 1 || want[0] != have[0] {
+		t.Fatalf("want %v, have %v", want, have)
+	}
+	if want, have := traceParent, header.Get(HeaderTraceParent); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+
+	// Headers of the request take precedence over the context
+	_, err = client.PerformRequest(ctx, PerformRequestOptions{
+		Method:  "GET",
+		Path:    "/",
+		Headers: http.Header{HeaderOpaqueID: []string{"explicit"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "explicit", header.Get(HeaderOpaqueID); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+
+	// Without values in the context, nothing changes
+	if _, err := client.PerformRequest(context.Background(), PerformRequestOptions{Method: "GET", Path: "/"}); err != nil {
+		t.Fatal(err)
+	}
+	if want, have := "default", header.Get(HeaderOpaqueID); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+	if want, have := "", header.Get(HeaderTraceParent); want != have {
+		t.Fatalf("want %q, have %q", want, have)
+	}
+}
+
+func TestTasksByOpaqueID(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want, have := "/_tasks", r.URL.Path; want != have {
+			t.Errorf("want path %q, have %q", want, have)
+		}
+		if want, have := "true", r.URL.Query().Get("detailed"); want != have {
+			t.Errorf("want detailed=%q, have %q", want, have)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"nodes": {
+				"node1": {
+					"name": "node1",
+					"tasks": {
+						"node1:3": {"node": "node1", "id": 3, "action": "indices:data/read/search", "start_time_in_millis": 300, "headers": {"X-Opaque-Id": "import-42"}},
+						"node1:4": {"node": "node1", "id": 4, "action": "indices:data/read/search", "start_time_in_millis": 100, "headers": {"X-Opaque-Id": "other"}}
+					}
+				},
+				"node2": {
+					"name": "node2",
+					"tasks": {
+						"node2:7": {"node": "node2", "id": 7, "action": "indices:data/write/reindex", "start_time_in_millis": 200, "headers": {"x-opaque-id": "import-42"}},
+						"node2:8": {"node": "node2", "id": 8, "action": "cluster:monitor/tasks/lists", "start_time_in_millis": 400, "headers": {}}
+					}
+				}
+			}
+		}`))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(SetURL(ts.URL), SetSniff(false), SetHealthcheck(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := client.TasksByOpaqueID(context.Background(), "import-42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, have := 2, len(tasks); want != have {
+		t.Fatalf("want %d tasks, have %d", want, have)
+	}
+	if want, have := int64(7), tasks[0].Id; want != have {
+		t.Fatalf("want task %d, have %d", want, have)
+	}
+	if want, have := int64(3), tasks[1].Id; want != have {
+		t.Fatalf("want task %d, have %d", want, have)
+	}
+}
@@ -365,6 +365,10 @@ func (s *UpdateService) body() (interface{}, error) {
 
 // Do executes the update operation.
 func (s *UpdateService) Do(ctx context.Context) (*UpdateResponse, error) {
+	if err := s.client.checkMappingTypes(ctx, s.typ); err != nil {
+		return nil, err
+	}
+
 	path, params, err := s.url()
 	if err != nil {
 		return nil, err
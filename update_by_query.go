@@ -74,6 +74,7 @@ type UpdateByQueryService struct {
 	versionType            *bool
 	waitForActiveShards    string
 	waitForCompletion      *bool
+	cancelTasks            bool
 }
 
 // NewUpdateByQueryService creates a new UpdateByQueryService.
@@ -379,6 +380,16 @@ func (s *UpdateByQueryService) Slices(slices interface{}) *UpdateByQueryService
 	return s
 }
 
+// CancelTasks, if enabled, cancels the tasks of the request in the cluster
+// when the context passed to Do is done before the response arrives.
+// The tasks are found by an X-Opaque-Id header unique to the request. An
+// opaque id set with WithOpaqueID is kept as its prefix, so other requests
+// sharing it are not cancelled.
+func (s *UpdateByQueryService) CancelTasks(enabled bool) *UpdateByQueryService {
+	s.cancelTasks = enabled
+	return s
+}
+
 // Sort is a list of <field>:<direction> pairs.
 func (s *UpdateByQueryService) Sort(sort ...string) *UpdateByQueryService {
 	s.sort = append(s.sort, sort...)
@@ -720,6 +731,7 @@ func (s *UpdateByQueryService) Do(ctx context.Context) (*BulkIndexByScrollRespon
 		Body:         body,
 		Headers:      s.headers,
 		IgnoreErrors: []int{http.StatusConflict},
+		CancelTasks:  s.cancelTasks,
 	})
 	if err != nil {
 		return nil, err